@@ -0,0 +1,262 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/front-matter/commonmeta/doiutils"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/spf13/cobra"
+)
+
+// apiKeyPrefix starts all API keys, to tell them apart from auth tokens
+const apiKeyPrefix = "cm_"
+
+// ensures that the ApiKey struct satisfy the models.Model interface
+var _ models.Model = (*ApiKey)(nil)
+
+// ApiKey grants a partner system write access to the works with the pids of
+// its prefixes. Only the SHA-256 hash of the key is stored.
+type ApiKey struct {
+	models.BaseModel
+
+	Name     string                  `db:"name" json:"name"`
+	Key      string                  `db:"key" json:"-"`
+	Prefixes types.JsonArray[string] `db:"prefixes" json:"prefixes"`
+}
+
+func (m *ApiKey) TableName() string {
+	return "api_keys"
+}
+
+// CreateApiKey creates an API key for the prefixes and returns it. The key
+// can't be retrieved later.
+func CreateApiKey(dao *daos.Dao, name string, prefixes []string) (string, error) {
+	existing, err := FindApiKeyByName(dao, name)
+	if err != nil {
+		return "", err
+	} else if existing != nil {
+		return "", fmt.Errorf("API key %s exists already", name)
+	}
+	key := apiKeyPrefix + security.RandomString(40)
+	apiKey := &ApiKey{Name: name, Key: security.SHA256(key), Prefixes: prefixes}
+	if err := dao.Save(apiKey); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// find an API key by its name
+func FindApiKeyByName(dao *daos.Dao, name string) (*ApiKey, error) {
+	return findApiKey(dao, dbx.HashExp{"name": name})
+}
+
+// find an API key by the key sent by a client
+func FindApiKey(dao *daos.Dao, key string) (*ApiKey, error) {
+	return findApiKey(dao, dbx.HashExp{"key": security.SHA256(key)})
+}
+
+func findApiKey(dao *daos.Dao, exp dbx.Expression) (*ApiKey, error) {
+	apiKey := &ApiKey{}
+
+	err := dao.ModelQuery(&ApiKey{}).
+		AndWhere(exp).
+		Limit(1).
+		One(apiKey)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+// ProtectUserPrefixesOnCreate rejects users signing up with prefixes
+func ProtectUserPrefixesOnCreate(e *core.RecordCreateEvent) error {
+	return protectUserPrefixes(e.HttpContext, nil, e.Record)
+}
+
+// ProtectUserPrefixesOnUpdate rejects changes of the prefixes of users by
+// anyone but admins, as users may update their own record by default
+func ProtectUserPrefixesOnUpdate(e *core.RecordUpdateEvent) error {
+	return protectUserPrefixes(e.HttpContext, e.Record.OriginalCopy(), e.Record)
+}
+
+// protectUserPrefixes returns a forbidden error unless the prefixes of a user
+// are unchanged, or changed by an admin
+func protectUserPrefixes(c echo.Context, original *models.Record, record *models.Record) error {
+	if admin, _ := c.Get(apis.ContextAdminKey).(*models.Admin); admin != nil {
+		return nil
+	}
+	if slices.Equal(userPrefixes(original), userPrefixes(record)) {
+		return nil
+	}
+	return apis.NewForbiddenError("Only admins can change the prefixes of users.", nil)
+}
+
+// userPrefixes returns the prefixes of a user, nil if there are none
+func userPrefixes(record *models.Record) []string {
+	var prefixes []string
+	if record != nil {
+		if err := record.UnmarshalJSONField("prefixes", &prefixes); err != nil {
+			return nil
+		}
+	}
+	return prefixes
+}
+
+// allowsPid reports whether one of the prefixes grants write access to a
+// pid: * for all pids, a DOI prefix such as 10.5555 for its DOIs, or a URL
+// for the pids below it, e.g. https://blog.example.org/
+func allowsPid(prefixes []string, pid string) bool {
+	pid = strings.ToLower(pid)
+	doiPrefix, isDoi := doiutils.ValidatePrefix(pid)
+	for _, p := range prefixes {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case p == "*":
+			return true
+		case strings.HasPrefix(p, "10."), strings.HasPrefix(p, "https://doi.org/10."):
+			if isDoi && strings.TrimPrefix(p, "https://doi.org/") == doiPrefix {
+				return true
+			}
+		case strings.HasPrefix(p, "https://"), strings.HasPrefix(p, "http://"):
+			p = strings.TrimSuffix(p, "/")
+			if pid == p || strings.HasPrefix(pid, p+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writePrefixesKey is the context key of the prefixes a client may write
+const writePrefixesKey = "writePrefixes"
+
+// writePrefixes returns the prefixes of the pids a client may write: all
+// pids for admins, the prefixes of the user for auth tokens, and those of
+// the API key for API keys. Anonymous clients get nil.
+func writePrefixes(c echo.Context, dao *daos.Dao) ([]string, error) {
+	if admin, _ := c.Get(apis.ContextAdminKey).(*models.Admin); admin != nil {
+		return []string{"*"}, nil
+	}
+	if record, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); record != nil {
+		if prefixes := userPrefixes(record); prefixes != nil {
+			return prefixes, nil
+		}
+		// users without prefixes may not write any works
+		return []string{}, nil
+	}
+	key := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, nil
+	}
+	apiKey, err := FindApiKey(dao, key)
+	if err != nil || apiKey == nil {
+		return nil, err
+	}
+	if apiKey.Prefixes == nil {
+		return []string{}, nil
+	}
+	return apiKey.Prefixes, nil
+}
+
+// RequireWriteAuth is a middleware rejecting clients without admin or auth
+// token or API key. The prefixes the client may write are stored in the
+// context, see canWrite.
+func RequireWriteAuth(dao *daos.Dao) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			prefixes, err := writePrefixes(c, dao)
+			if err != nil {
+				return err
+			}
+			if prefixes == nil {
+				c.Response().Header().Set("WWW-Authenticate", "Bearer")
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+			}
+			c.Set(writePrefixesKey, prefixes)
+			return next(c)
+		}
+	}
+}
+
+// canWrite reports whether the client may write the work with a pid
+func canWrite(c echo.Context, pid string) bool {
+	prefixes, _ := c.Get(writePrefixesKey).([]string)
+	return allowsPid(prefixes, pid)
+}
+
+// NewApiKeyCommand returns the apikey command of the command line interface,
+// which creates and revokes the API keys of partner systems
+func NewApiKeyCommand(app core.App) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage the API keys of partner systems writing works",
+	}
+
+	var prefixes []string
+	createCmd := &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create an API key granting write access to the works of the prefixes",
+		Args:  cobra.ExactArgs(1),
+
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(prefixes) == 0 {
+				return errors.New("at least one --prefix is required")
+			}
+			if err := RunMigrations(app); err != nil {
+				return err
+			}
+			key, err := CreateApiKey(app.Dao(), args[0], prefixes)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("API key %s for %s, it is only shown once:\n%s\n", args[0], strings.Join(prefixes, ", "), key)
+			return nil
+		},
+	}
+	createCmd.Flags().StringArrayVar(&prefixes, "prefix", nil, "DOI prefix such as 10.5555, URL such as https://blog.example.org/, or * for all works")
+	cmd.AddCommand(createCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "revoke [name]",
+		Short: "Revoke an API key",
+		Args:  cobra.ExactArgs(1),
+
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := RunMigrations(app); err != nil {
+				return err
+			}
+			apiKey, err := FindApiKeyByName(app.Dao(), args[0])
+			if err != nil {
+				return err
+			} else if apiKey == nil {
+				return fmt.Errorf("API key %s not found", args[0])
+			}
+			if err := app.Dao().Delete(apiKey); err != nil {
+				return err
+			}
+			cmd.Printf("Revoked API key %s\n", args[0])
+			return nil
+		},
+	})
+	return cmd
+}
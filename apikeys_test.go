@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func TestAllowsPid(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		prefixes []string
+		pid      string
+		want     bool
+	}
+	testCases := []testCase{
+		{prefixes: []string{"*"}, pid: "https://doi.org/10.5555/12345678", want: true},
+		{prefixes: []string{"10.5555"}, pid: "https://doi.org/10.5555/12345678", want: true},
+		{prefixes: []string{"https://doi.org/10.5555"}, pid: "https://doi.org/10.5555/ABC", want: true},
+		{prefixes: []string{"10.5555"}, pid: "https://doi.org/10.55555/12345678", want: false},
+		{prefixes: []string{"10.1234", "10.5555"}, pid: "https://doi.org/10.5555/12345678", want: true},
+		{prefixes: []string{"https://blog.example.org/"}, pid: "https://blog.example.org/posts/1", want: true},
+		{prefixes: []string{"https://blog.example.org"}, pid: "https://blog.example.org.evil.com/posts/1", want: false},
+		{prefixes: []string{"https://blog.example.org"}, pid: "https://doi.org/10.5555/12345678", want: false},
+		{prefixes: []string{"10.5555"}, pid: "https://blog.example.org/posts/1", want: false},
+		{prefixes: []string{"blog.example.org"}, pid: "https://blog.example.org/posts/1", want: false},
+		{prefixes: []string{}, pid: "https://doi.org/10.5555/12345678", want: false},
+		{prefixes: nil, pid: "https://doi.org/10.5555/12345678", want: false},
+	}
+	for _, tc := range testCases {
+		got := allowsPid(tc.prefixes, tc.pid)
+		if tc.want != got {
+			t.Errorf("allowsPid(%v, %v): want %v, got %v", tc.prefixes, tc.pid, tc.want, got)
+		}
+	}
+}
+
+func TestRequireWriteAuth(t *testing.T) {
+	t.Parallel()
+
+	users := &models.Collection{Name: "users", Type: models.CollectionTypeAuth, Schema: schema.NewSchema(
+		&schema.SchemaField{Name: "prefixes", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{}},
+	)}
+	user := models.NewRecord(users)
+	user.Set("prefixes", `["10.5555"]`)
+
+	type testCase struct {
+		name          string
+		admin         *models.Admin
+		record        *models.Record
+		authorization string
+		wantStatus    int
+		wantWrite     bool
+	}
+	testCases := []testCase{
+		{name: "admin", admin: &models.Admin{}, wantStatus: http.StatusOK, wantWrite: true},
+		{name: "user with prefix", record: user, wantStatus: http.StatusOK, wantWrite: true},
+		{name: "user without prefixes", record: models.NewRecord(users), wantStatus: http.StatusOK, wantWrite: false},
+		{name: "anonymous", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", authorization: "Bearer token", wantStatus: http.StatusUnauthorized},
+	}
+	e := echo.New()
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPut, "/10.5555/12345678", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if tc.admin != nil {
+			c.Set(apis.ContextAdminKey, tc.admin)
+		}
+		if tc.record != nil {
+			c.Set(apis.ContextAuthRecordKey, tc.record)
+		}
+		var gotWrite bool
+		handler := RequireWriteAuth(nil)(func(c echo.Context) error {
+			gotWrite = canWrite(c, "https://doi.org/10.5555/12345678")
+			return c.NoContent(http.StatusOK)
+		})
+		if err := handler(c); err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantStatus != rec.Code {
+			t.Errorf("RequireWriteAuth(%v): want status %v, got %v", tc.name, tc.wantStatus, rec.Code)
+		}
+		if tc.wantWrite != gotWrite {
+			t.Errorf("RequireWriteAuth(%v): want canWrite %v, got %v", tc.name, tc.wantWrite, gotWrite)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("RequireWriteAuth(%v): want WWW-Authenticate Bearer, got %v", tc.name, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestProtectUserPrefixes(t *testing.T) {
+	t.Parallel()
+
+	users := &models.Collection{Name: "users", Type: models.CollectionTypeAuth, Schema: schema.NewSchema(
+		&schema.SchemaField{Name: "name", Type: schema.FieldTypeText, Options: &schema.TextOptions{}},
+		&schema.SchemaField{Name: "prefixes", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{}},
+	)}
+	type testCase struct {
+		name     string
+		admin    bool
+		original map[string]any
+		update   map[string]any
+		wantErr  bool
+	}
+	testCases := []testCase{
+		{name: "user grants themselves all prefixes", original: map[string]any{"name": "a"}, update: map[string]any{"prefixes": `["*"]`}, wantErr: true},
+		{name: "user adds a prefix", original: map[string]any{"prefixes": `["10.5555"]`}, update: map[string]any{"prefixes": `["10.5555","10.1234"]`}, wantErr: true},
+		{name: "user removes their prefixes", original: map[string]any{"prefixes": `["10.5555"]`}, update: map[string]any{"prefixes": `[]`}, wantErr: true},
+		{name: "user changes their name", original: map[string]any{"name": "a", "prefixes": `["10.5555"]`}, update: map[string]any{"name": "b"}, wantErr: false},
+		{name: "user sends their prefixes unchanged", original: map[string]any{"prefixes": `["10.5555"]`}, update: map[string]any{"prefixes": `[ "10.5555" ]`}, wantErr: false},
+		{name: "admin grants prefixes", admin: true, original: map[string]any{"name": "a"}, update: map[string]any{"prefixes": `["10.5555"]`}, wantErr: false},
+		{name: "user signs up with prefixes", update: map[string]any{"prefixes": `["*"]`}, wantErr: true},
+		{name: "user signs up", update: map[string]any{"name": "a"}, wantErr: false},
+	}
+	e := echo.New()
+	for _, tc := range testCases {
+		c := e.NewContext(httptest.NewRequest(http.MethodPatch, "/api/collections/users/records/abc", nil), httptest.NewRecorder())
+		if tc.admin {
+			c.Set(apis.ContextAdminKey, &models.Admin{})
+		}
+		record := models.NewRecord(users)
+		var err error
+		if tc.original != nil {
+			record.Load(tc.original)
+			record.Load(tc.update)
+			err = ProtectUserPrefixesOnUpdate(&core.RecordUpdateEvent{HttpContext: c, Record: record})
+		} else {
+			record.Load(tc.update)
+			err = ProtectUserPrefixesOnCreate(&core.RecordCreateEvent{HttpContext: c, Record: record})
+		}
+		if tc.wantErr != (err != nil) {
+			t.Errorf("ProtectUserPrefixes(%v): want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,809 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/dateutils"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CMToBibtexMappings maps commonmeta types to BibTeX entry types
+var CMToBibtexMappings = map[string]string{
+	"Article":            "article",
+	"JournalArticle":     "article",
+	"ProceedingsArticle": "inproceedings",
+	"Proceedings":        "proceedings",
+	"Book":               "book",
+	"BookChapter":        "incollection",
+	"BookSection":        "incollection",
+	"Report":             "techreport",
+	"Dissertation":       "phdthesis",
+	"Manuscript":         "unpublished",
+}
+
+var bibtexMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+// stop words ignored when generating citation keys
+var bibtexStopWords = []string{"a", "an", "and", "at", "for", "from", "in", "of", "on", "the", "to", "with"}
+
+// bibtexField is a single field of a BibTeX entry. Values are already escaped,
+// month macros are written without braces.
+type bibtexField struct {
+	Name  string
+	Value string
+	Raw   bool
+}
+
+// WriteBibtex converts commonmeta metadata to a BibTeX entry.
+func WriteBibtex(data commonmeta.Data) (string, error) {
+	if data.ID == "" {
+		return "", invalidWork("missing id")
+	}
+	entryType := CMToBibtexMappings[data.Type]
+	if entryType == "" {
+		entryType = "misc"
+	}
+
+	fields := make([]bibtexField, 0)
+	add := func(name string, value string) {
+		if value != "" {
+			fields = append(fields, bibtexField{Name: name, Value: value})
+		}
+	}
+
+	names := make([]string, 0)
+	for _, c := range authors(data) {
+		if c.FamilyName != "" {
+			name := LatexEscape(c.FamilyName)
+			if c.GivenName != "" {
+				name += ", " + LatexEscape(c.GivenName)
+			}
+			names = append(names, name)
+		} else if c.Name != "" {
+			// protect organization names from being parsed as personal names
+			names = append(names, "{"+LatexEscape(c.Name)+"}")
+		}
+	}
+	add("author", strings.Join(names, " and "))
+	add("title", LatexEscape(mainTitle(data)))
+
+	container := LatexEscape(data.Container.Title)
+	switch entryType {
+	case "article":
+		add("journal", container)
+	case "inproceedings", "incollection":
+		add("booktitle", container)
+	case "techreport":
+		add("institution", LatexEscape(data.Publisher.Name))
+	case "phdthesis":
+		add("school", LatexEscape(data.Publisher.Name))
+	}
+	if entryType != "techreport" && entryType != "phdthesis" {
+		add("publisher", LatexEscape(data.Publisher.Name))
+	}
+	add("volume", LatexEscape(data.Container.Volume))
+	add("number", LatexEscape(data.Container.Issue))
+	if pages := data.Container.Pages(); pages != "" {
+		add("pages", strings.Replace(pages, "-", "--", 1))
+	}
+
+	date := publicationDate(data)
+	add("year", publicationYear(data))
+	if len(date) >= 7 {
+		month, err := strconv.Atoi(date[5:7])
+		if err == nil && month >= 1 && month <= 12 {
+			fields = append(fields, bibtexField{Name: "month", Value: bibtexMonths[month-1], Raw: true})
+		}
+	}
+	add("version", LatexEscape(data.Version))
+	// DOIs and URLs are written verbatim, as they are usually typeset with \url or \doi
+	add("doi", doiFromPid(data.ID))
+	add("url", data.URL)
+	add("abstract", LatexEscape(abstract(data)))
+	k := keywords(data)
+	for i := range k {
+		k[i] = LatexEscape(k[i])
+	}
+	add("keywords", strings.Join(k, ", "))
+	add("language", data.Language)
+	add("copyright", data.License.URL)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "@%s{%s,\n", entryType, CitationKey(data))
+	for i, f := range fields {
+		if f.Raw {
+			fmt.Fprintf(&b, "    %s = %s", f.Name, f.Value)
+		} else {
+			fmt.Fprintf(&b, "    %s = {%s}", f.Name, f.Value)
+		}
+		if i < len(fields)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// CitationKey generates a citation key from the family name of the first author,
+// the publication year and the first significant word of the title, e.g.
+// fenner2023commonmeta. Falls back to the DOI or pid if no author or title is
+// known, with characters not allowed in citation keys replaced by underscores,
+// e.g. 10.5555_12345678.
+func CitationKey(data commonmeta.Data) string {
+	var name string
+	if a := authors(data); len(a) > 0 {
+		if a[0].FamilyName != "" {
+			name = asciiKey(a[0].FamilyName)
+		} else if words := strings.Fields(a[0].Name); len(words) > 0 {
+			name = asciiKey(words[0])
+		}
+	}
+	var word string
+	for _, w := range strings.Fields(stripTags(mainTitle(data))) {
+		w = asciiKey(w)
+		if w != "" && !slices.Contains(bibtexStopWords, w) {
+			word = w
+			break
+		}
+	}
+	if name != "" && word != "" {
+		return name + publicationYear(data) + word
+	}
+
+	id := doiFromPid(data.ID)
+	if id == "" {
+		id = strings.TrimSuffix(data.ID, "/")
+		if i := strings.LastIndex(id, "/"); i >= 0 {
+			id = id[i+1:]
+		}
+	}
+	return strings.ToLower(citationKeyRegexp.ReplaceAllString(id, "_"))
+}
+
+var citationKeyRegexp = regexp.MustCompile(`[^A-Za-z0-9_:-]`)
+
+// asciiKey lowercases a string and removes diacritics and everything but
+// letters and digits, so that it can be used in a citation key
+func asciiKey(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+var latexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// HTML markup used in titles and abstracts, and the matching LaTeX commands
+var latexTags = map[string]string{
+	"i":      `\textit{`,
+	"em":     `\emph{`,
+	"b":      `\textbf{`,
+	"strong": `\textbf{`,
+	"sub":    `\textsubscript{`,
+	"sup":    `\textsuperscript{`,
+	"sc":     `\textsc{`,
+}
+
+var tagRegexp = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)[^>]*>`)
+
+// LatexEscape escapes characters with a special meaning in LaTeX, converts
+// basic HTML markup into LaTeX commands and removes all other markup
+func LatexEscape(s string) string {
+	var b strings.Builder
+	last, open := 0, 0
+	for _, m := range tagRegexp.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(latexReplacer.Replace(html.UnescapeString(s[last:m[0]])))
+		last = m[1]
+		closing := s[m[2]:m[3]] == "/"
+		if cmd, ok := latexTags[strings.ToLower(s[m[4]:m[5]])]; ok {
+			if !closing {
+				b.WriteString(cmd)
+				open++
+			} else if open > 0 {
+				// ignore closing tags without opening tag to keep braces balanced
+				b.WriteString("}")
+				open--
+			}
+		}
+	}
+	b.WriteString(latexReplacer.Replace(html.UnescapeString(s[last:])))
+	b.WriteString(strings.Repeat("}", open))
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// stripTags removes HTML markup from a string and decodes HTML entities
+func stripTags(s string) string {
+	return strings.TrimSpace(html.UnescapeString(tagRegexp.ReplaceAllString(s, "")))
+}
+
+// BibtexToCMMappings maps BibTeX and BibLaTeX entry types to commonmeta types
+var BibtexToCMMappings = map[string]string{
+	"article":       "JournalArticle",
+	"book":          "Book",
+	"booklet":       "Book",
+	"inbook":        "BookChapter",
+	"incollection":  "BookChapter",
+	"inproceedings": "ProceedingsArticle",
+	"conference":    "ProceedingsArticle",
+	"proceedings":   "Proceedings",
+	"manual":        "Document",
+	"mastersthesis": "Dissertation",
+	"phdthesis":     "Dissertation",
+	"thesis":        "Dissertation",
+	"techreport":    "Report",
+	"report":        "Report",
+	"unpublished":   "Document",
+	"online":        "WebPage",
+	"electronic":    "WebPage",
+	"www":           "WebPage",
+	"software":      "Software",
+	"dataset":       "Dataset",
+	"misc":          "Other",
+}
+
+// bibtexEntry is an entry of a BibTeX file, with lowercase type and field
+// names and field values with macros expanded
+type bibtexEntry struct {
+	Type   string
+	Key    string
+	Fields map[string]string
+}
+
+// ReadBibtexAll reads the entries of a BibTeX file. @string macros are
+// expanded, @comment and @preamble entries are skipped.
+func ReadBibtexAll(b []byte) ([]ReadResult, error) {
+	p := &bibtexParser{s: string(b), macros: make(map[string]string)}
+	for i, m := range bibtexMonths {
+		p.macros[m] = strconv.Itoa(i + 1)
+	}
+	results := make([]ReadResult, 0)
+	for {
+		start := strings.IndexByte(p.s[p.pos:], '@')
+		if start < 0 {
+			return results, nil
+		}
+		p.pos += start
+		start = p.pos
+		entry, err := p.entry()
+		raw := []byte(strings.TrimSpace(p.s[start:p.pos]))
+		if err != nil {
+			// continue with the next entry starting on a new line
+			if next := strings.Index(p.s[start:], "\n@"); next >= 0 {
+				p.pos = start + next + 1
+			} else {
+				p.pos = len(p.s)
+			}
+			raw = []byte(strings.TrimSpace(p.s[start:p.pos]))
+			results = append(results, ReadResult{Raw: raw, Err: err})
+			continue
+		}
+		if entry != nil {
+			results = append(results, ReadResult{Raw: raw, Key: entry.Key, Data: readBibtexEntry(*entry)})
+		}
+	}
+}
+
+// bibtexParser parses BibTeX files, keeping the @string macros defined so far
+type bibtexParser struct {
+	s      string
+	pos    int
+	macros map[string]string
+}
+
+// entry parses the entry at the current position, returning nil for
+// @comment, @preamble and @string entries
+func (p *bibtexParser) entry() (*bibtexEntry, error) {
+	p.pos++ // @
+	entryType := strings.ToLower(p.name())
+	p.skipSpace()
+	if p.pos >= len(p.s) || (p.s[p.pos] != '{' && p.s[p.pos] != '(') {
+		// an @ in the text between entries, e.g. in an email address
+		return nil, nil
+	}
+	closing := byte('}')
+	if p.s[p.pos] == '(' {
+		closing = ')'
+	}
+	p.pos++
+	switch entryType {
+	case "comment", "preamble":
+		p.pos--
+		_, err := p.group(p.s[p.pos], closing)
+		return nil, err
+	case "string":
+		name, value, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		p.macros[name] = value
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != closing {
+			return nil, fmt.Errorf("bibtex: unterminated @string %s", name)
+		}
+		p.pos++
+		return nil, nil
+	}
+
+	entry := &bibtexEntry{Type: entryType, Fields: make(map[string]string)}
+	end := strings.IndexAny(p.s[p.pos:], ","+string(closing))
+	if end < 0 {
+		return nil, fmt.Errorf("bibtex: unterminated @%s entry", entryType)
+	}
+	entry.Key = strings.TrimSpace(p.s[p.pos : p.pos+end])
+	p.pos += end
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("bibtex: unterminated entry %s", entry.Key)
+		}
+		switch p.s[p.pos] {
+		case closing:
+			p.pos++
+			return entry, nil
+		case ',':
+			p.pos++
+			continue
+		}
+		name, value, err := p.field()
+		if err != nil {
+			return nil, fmt.Errorf("%w in entry %s", err, entry.Key)
+		}
+		entry.Fields[name] = value
+	}
+}
+
+// field parses a field such as title = {Commonmeta} # " and " # macro
+func (p *bibtexParser) field() (string, string, error) {
+	p.skipSpace()
+	name := strings.ToLower(p.name())
+	p.skipSpace()
+	if name == "" || p.pos >= len(p.s) || p.s[p.pos] != '=' {
+		return "", "", fmt.Errorf("bibtex: expected field name and =")
+	}
+	p.pos++
+	var value strings.Builder
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return "", "", fmt.Errorf("bibtex: missing value of field %s", name)
+		}
+		switch c := p.s[p.pos]; c {
+		case '{':
+			part, err := p.group('{', '}')
+			if err != nil {
+				return "", "", err
+			}
+			value.WriteString(part)
+		case '"':
+			part, err := p.group('"', '"')
+			if err != nil {
+				return "", "", err
+			}
+			value.WriteString(part)
+		default:
+			part := p.name()
+			if part == "" {
+				return "", "", fmt.Errorf("bibtex: invalid value of field %s", name)
+			}
+			if macro, ok := p.macros[strings.ToLower(part)]; ok {
+				part = macro
+			}
+			value.WriteString(part)
+		}
+		p.skipSpace()
+		if p.pos < len(p.s) && p.s[p.pos] == '#' {
+			p.pos++
+			continue
+		}
+		return name, value.String(), nil
+	}
+}
+
+// group returns the content of a value delimited by braces or quotes, with
+// nested braces kept as they are
+func (p *bibtexParser) group(open byte, closing byte) (string, error) {
+	start := p.pos + 1
+	depth := 0
+	for i := start; i < len(p.s); i++ {
+		switch c := p.s[i]; {
+		case c == '\\':
+			i++
+		case c == '{' && closing != '{':
+			depth++
+		case depth > 0 && c == '}':
+			depth--
+		case depth == 0 && c == closing:
+			p.pos = i + 1
+			return p.s[start:i], nil
+		}
+	}
+	return "", fmt.Errorf("bibtex: unbalanced %c", open)
+}
+
+// name reads an entry type, field name, macro name or number
+func (p *bibtexParser) name() string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t\r\n{}()\",=#%@", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *bibtexParser) skipSpace() {
+	for p.pos < len(p.s) && strings.ContainsRune(" \t\r\n", rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// readBibtexEntry converts a BibTeX entry to commonmeta
+func readBibtexEntry(entry bibtexEntry) commonmeta.Data {
+	f := func(name string) string {
+		return LatexUnescape(entry.Fields[name])
+	}
+	data := commonmeta.Data{
+		ID:   workPid(latexVerbatim(entry.Fields["doi"]), latexVerbatim(entry.Fields["url"])),
+		Type: BibtexToCMMappings[entry.Type],
+		URL:  latexVerbatim(entry.Fields["url"]),
+	}
+	if data.Type == "" {
+		data.Type = "Other"
+	}
+	for _, role := range []string{"author", "editor"} {
+		for _, name := range splitBibtexNames(entry.Fields[role]) {
+			data.Contributors = append(data.Contributors, bibtexContributor(name, strings.ToUpper(role[:1])+role[1:]))
+		}
+	}
+	if title := f("title"); title != "" {
+		data.Titles = append(data.Titles, commonmeta.Title{Title: title})
+	}
+	if subtitle := f("subtitle"); subtitle != "" {
+		data.Titles = append(data.Titles, commonmeta.Title{Title: subtitle, Type: "Subtitle"})
+	}
+
+	container := commonmeta.Container{Volume: f("volume"), Issue: f("number")}
+	if container.Issue == "" {
+		container.Issue = f("issue")
+	}
+	container.FirstPage, container.LastPage = pageRange(f("pages"))
+	if journal := f("journal"); journal != "" {
+		container.Type, container.Title = "Journal", journal
+	} else if journal := f("journaltitle"); journal != "" {
+		container.Type, container.Title = "Journal", journal
+	} else if booktitle := f("booktitle"); booktitle != "" {
+		container.Type, container.Title = "Book", booktitle
+		if data.Type == "ProceedingsArticle" {
+			container.Type = "Proceedings"
+		}
+	} else if series := f("series"); series != "" {
+		container.Type, container.Title = "Series", series
+	}
+	if issn := f("issn"); issn != "" {
+		container.Identifier, container.IdentifierType = issn, "ISSN"
+	}
+	data.Container = container
+
+	for _, name := range []string{"publisher", "institution", "school", "organization"} {
+		if publisher := f(name); publisher != "" {
+			data.Publisher = commonmeta.Publisher{Name: publisher}
+			break
+		}
+	}
+	data.Date.Published = bibtexDate(entry)
+	if isbn := f("isbn"); isbn != "" {
+		data.Identifiers = append(data.Identifiers, commonmeta.Identifier{Identifier: isbn, IdentifierType: "ISBN"})
+	}
+	if abstract := f("abstract"); abstract != "" {
+		data.Descriptions = append(data.Descriptions, commonmeta.Description{Description: abstract, Type: "Abstract"})
+	}
+	if subjects := subjectsFromKeywords(f("keywords")); len(subjects) > 0 {
+		data.Subjects = subjects
+	}
+	data.Language = f("language")
+	data.Version = f("version")
+	data.License = licenseFromURL(latexVerbatim(entry.Fields["copyright"]))
+	return data
+}
+
+// bibtexDate returns the publication date of an entry from the BibLaTeX date
+// field, or from the year, month and day fields
+func bibtexDate(entry bibtexEntry) string {
+	if date, _, _ := strings.Cut(LatexUnescape(entry.Fields["date"]), "/"); date != "" {
+		return date
+	}
+	year, err := strconv.Atoi(LatexUnescape(entry.Fields["year"]))
+	if err != nil {
+		return ""
+	}
+	parts := []int{year}
+	month := strings.ToLower(LatexUnescape(entry.Fields["month"]))
+	if i := slices.Index(bibtexMonths, month[:min(len(month), 3)]); i >= 0 && !unicode.IsDigit(rune(month[0])) {
+		parts = append(parts, i+1)
+	} else if m, err := strconv.Atoi(month); err == nil && m >= 1 && m <= 12 {
+		parts = append(parts, m)
+	}
+	if day, err := strconv.Atoi(LatexUnescape(entry.Fields["day"])); err == nil && len(parts) == 2 && day >= 1 && day <= 31 {
+		parts = append(parts, day)
+	}
+	return dateutils.GetDateFromParts(parts...)
+}
+
+// splitBibtexNames splits a list of names separated by "and", ignoring "and"
+// inside braces. The "others" of truncated lists is dropped.
+func splitBibtexNames(s string) []string {
+	names := make([]string, 0)
+	words := bibtexWords(s)
+	start := 0
+	for i := 0; i <= len(words); i++ {
+		if i < len(words) && !strings.EqualFold(words[i], "and") {
+			continue
+		}
+		if name := strings.Join(words[start:i], " "); name != "" && name != "others" {
+			names = append(names, name)
+		}
+		start = i + 1
+	}
+	return names
+}
+
+// bibtexWords splits a string at whitespace outside braces
+func bibtexWords(s string) []string {
+	words := make([]string, 0)
+	depth, start := 0, -1
+	for i, r := range s {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+		case depth == 0 && unicode.IsSpace(r):
+			if start >= 0 {
+				words = append(words, s[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// bibtexContributor parses a BibTeX name in one of the forms "First von
+// Last", "von Last, First" or "von Last, Jr, First". Names in braces are
+// organizations.
+func bibtexContributor(name string, role string) commonmeta.Contributor {
+	c := commonmeta.Contributor{Type: "Person", ContributorRoles: []string{role}}
+	if strings.HasPrefix(name, "{") && strings.HasSuffix(name, "}") && len(bibtexWords(name)) == 1 {
+		c.Type, c.Name = "Organization", LatexUnescape(name)
+		return c
+	}
+	parts := make([]string, 0)
+	depth, start := 0, 0
+	for i, r := range name {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, name[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, name[start:])
+	if len(parts) > 1 {
+		c.FamilyName = LatexUnescape(parts[0])
+		c.GivenName = LatexUnescape(parts[len(parts)-1])
+		return c
+	}
+	words := bibtexWords(name)
+	// the von part starts with a lowercase word and belongs to the last name
+	last := len(words) - 1
+	for i, w := range words[:last] {
+		if r := []rune(strings.TrimLeft(w, "{\\")); len(r) > 0 && unicode.IsLower(r[0]) {
+			last = i
+			break
+		}
+	}
+	c.GivenName = LatexUnescape(strings.Join(words[:last], " "))
+	c.FamilyName = LatexUnescape(strings.Join(words[last:], " "))
+	return c
+}
+
+// LaTeX accent commands and the matching Unicode combining characters
+var latexAccents = map[string]rune{
+	"'": '\u0301', "`": '\u0300', "^": '\u0302', `"`: '\u0308', "~": '\u0303',
+	"=": '\u0304', ".": '\u0307', "u": '\u0306', "v": '\u030c', "H": '\u030b',
+	"c": '\u0327', "k": '\u0328', "r": '\u030a', "d": '\u0323', "b": '\u0331',
+}
+
+// LaTeX commands for special characters
+var latexSymbols = map[string]string{
+	"ss": "ß", "o": "ø", "O": "Ø", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ",
+	"aa": "å", "AA": "Å", "l": "ł", "L": "Ł", "i": "ı", "j": "ȷ",
+	"textbackslash": `\`, "textasciitilde": "~", "textasciicircum": "^",
+	"textendash": "–", "textemdash": "—", "textquoteright": "’", "textquoteleft": "‘",
+	"&": "&", "%": "%", "$": "$", "#": "#", "_": "_", "{": "{", "}": "}",
+	" ": " ", ",": " ", "\\": " ", "/": "",
+}
+
+// LaTeX formatting commands and the matching HTML markup, the inverse of latexTags
+var latexTagCommands = map[string]string{
+	"textit":          "i",
+	"emph":            "i",
+	"textbf":          "b",
+	"textsubscript":   "sub",
+	"textsuperscript": "sup",
+	"textsc":          "sc",
+}
+
+// LatexUnescape converts the LaTeX markup of BibTeX values to Unicode text:
+// accents and special characters are decoded, basic formatting commands
+// become HTML markup and the braces protecting capitalization are removed
+func LatexUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '\\':
+			out, n := latexCommand(s[i+1:])
+			b.WriteString(out)
+			i += 1 + n
+		case c == '{' || c == '}' || c == '$':
+			i++
+		case c == '~':
+			b.WriteByte(' ')
+			i++
+		case strings.HasPrefix(s[i:], "---"):
+			b.WriteString("—")
+			i += 3
+		case strings.HasPrefix(s[i:], "--"):
+			b.WriteString("–")
+			i += 2
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return norm.NFC.String(strings.Join(strings.Fields(b.String()), " "))
+}
+
+// latexCommand decodes the command at the start of s, following the
+// backslash. It returns the text and the number of bytes consumed.
+func latexCommand(s string) (string, int) {
+	if s == "" {
+		return "", 0
+	}
+	n := 1
+	if isASCIILetter(s[0]) {
+		for n < len(s) && isASCIILetter(s[n]) {
+			n++
+		}
+	}
+	name := s[:n]
+	if mark, ok := latexAccents[name]; ok {
+		arg, m := latexArgument(s[n:], isASCIILetter(name[0]))
+		r := []rune(LatexUnescape(arg))
+		if len(r) == 0 {
+			return "", n + m
+		}
+		// dotless i and j carry accents in LaTeX
+		switch r[0] {
+		case 'ı':
+			r[0] = 'i'
+		case 'ȷ':
+			r[0] = 'j'
+		}
+		return string(r[0]) + string(mark) + string(r[1:]), n + m
+	}
+	if tag, ok := latexTagCommands[name]; ok {
+		arg, m := latexArgument(s[n:], true)
+		return "<" + tag + ">" + LatexUnescape(arg) + "</" + tag + ">", n + m
+	}
+	if symbol, ok := latexSymbols[name]; ok {
+		if isASCIILetter(name[0]) {
+			// letter commands end with an empty group or swallow the following spaces
+			if strings.HasPrefix(s[n:], "{}") {
+				n += 2
+			} else {
+				for n < len(s) && s[n] == ' ' {
+					n++
+				}
+			}
+		}
+		return symbol, n
+	}
+	if isASCIILetter(name[0]) && strings.HasPrefix(strings.TrimLeft(s[n:], " "), "{") {
+		// unknown commands with an argument, e.g. \url or \textrm, keep the argument
+		arg, m := latexArgument(s[n:], true)
+		return LatexUnescape(arg), n + m
+	}
+	return "", n
+}
+
+// latexArgument returns the argument of a command, a group in braces, a
+// command or a single character. Letter commands skip the spaces before
+// their argument.
+func latexArgument(s string, skipSpace bool) (string, int) {
+	n := 0
+	if skipSpace {
+		for n < len(s) && s[n] == ' ' {
+			n++
+		}
+	}
+	if n >= len(s) {
+		return "", n
+	}
+	switch s[n] {
+	case '{':
+		depth := 0
+		for i := n; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return s[n+1 : i], i + 1
+				}
+			}
+		}
+		return s[n+1:], len(s)
+	case '\\':
+		m := n + 2
+		m = min(m, len(s))
+		if isASCIILetter(s[m-1]) {
+			for m < len(s) && isASCIILetter(s[m]) {
+				m++
+			}
+			arg := s[n:m]
+			// control words swallow the following spaces
+			for m < len(s) && s[m] == ' ' {
+				m++
+			}
+			return arg, m
+		}
+		return s[n:m], m
+	}
+	_, size := utf8.DecodeRuneInString(s[n:])
+	return s[n : n+size], n + size
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// latexVerbatim returns a DOI or URL field as it is, removing braces and the
+// backslashes escaping special characters
+func latexVerbatim(s string) string {
+	s = strings.NewReplacer("{", "", "}", "", `\_`, "_", `\%`, "%", `\&`, "&", `\#`, "#", `\$`, "$", `\~`, "~").Replace(s)
+	return strings.TrimSpace(s)
+}
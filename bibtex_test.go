@@ -0,0 +1,209 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+func TestWriteBibtex(t *testing.T) {
+	t.Parallel()
+
+	data := commonmeta.Data{
+		ID:   "https://doi.org/10.7554/elife.01567",
+		Type: "JournalArticle",
+		Contributors: []commonmeta.Contributor{
+			{Type: "Person", GivenName: "Martial", FamilyName: "Sankar", ContributorRoles: []string{"Author"}},
+			{Type: "Person", GivenName: "Kaisa", FamilyName: "Nieminen", ContributorRoles: []string{"Author"}},
+			{Type: "Organization", Name: "eLife Sciences & Co", ContributorRoles: []string{"Author"}},
+		},
+		Titles:    []commonmeta.Title{{Title: "Automated quantitative histology reveals vascular morphodynamics during <i>Arabidopsis</i> hypocotyl secondary growth"}},
+		Container: commonmeta.Container{Title: "eLife", Volume: "3", FirstPage: "e01567"},
+		Publisher: commonmeta.Publisher{Name: "eLife Sciences Publications, Ltd"},
+		Date:      commonmeta.Date{Published: "2014-02-11"},
+		URL:       "https://elifesciences.org/articles/01567",
+	}
+	want := `@article{sankar2014automated,
+    author = {Sankar, Martial and Nieminen, Kaisa and {eLife Sciences \& Co}},
+    title = {Automated quantitative histology reveals vascular morphodynamics during \textit{Arabidopsis} hypocotyl secondary growth},
+    journal = {eLife},
+    publisher = {eLife Sciences Publications, Ltd},
+    volume = {3},
+    pages = {e01567},
+    year = {2014},
+    month = feb,
+    doi = {10.7554/elife.01567},
+    url = {https://elifesciences.org/articles/01567}
+}
+`
+	got, err := WriteBibtex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != got {
+		t.Errorf("Write BibTeX: want\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestCitationKey(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		data commonmeta.Data
+		want string
+	}
+
+	testCases := []testCase{
+		{data: commonmeta.Data{
+			ID:           "https://doi.org/10.5555/12345678",
+			Contributors: []commonmeta.Contributor{{GivenName: "José", FamilyName: "Müller-Núñez", ContributorRoles: []string{"Author"}}},
+			Titles:       []commonmeta.Title{{Title: "The Art of Metadata"}},
+			Date:         commonmeta.Date{Published: "2023"},
+		}, want: "mullernunez2023art"},
+		{data: commonmeta.Data{
+			ID:     "https://doi.org/10.5555/12345678",
+			Titles: []commonmeta.Title{{Title: "Anonymous"}},
+		}, want: "10_5555_12345678"},
+		{data: commonmeta.Data{
+			ID: "https://blog.front-matter.io/posts/commonmeta/",
+		}, want: "commonmeta"},
+		{data: commonmeta.Data{
+			ID: "https://doi.org/10.1002/(SICI)1097-4571(199806)49:8<693::AID-ASI4>3.0.CO;2-0",
+		}, want: "10_1002__sici_1097-4571_199806_49:8_693::aid-asi4_3_0_co_2-0"},
+	}
+	for _, tc := range testCases {
+		got := CitationKey(tc.data)
+		if tc.want != got {
+			t.Errorf("Citation key(%v): want %v, got %v", tc.data.ID, tc.want, got)
+		}
+	}
+}
+
+func TestLatexEscape(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input string
+		want  string
+	}
+
+	testCases := []testCase{
+		{input: "50% of R&D costs $5 #1", want: `50\% of R\&D costs \$5 \#1`},
+		{input: "H<sub>2</sub>O and CO<sub>2", want: `H\textsubscript{2}O and CO\textsubscript{2}`},
+		{input: "<p>snake_case &amp; {braces}</p>", want: `snake\_case \& \{braces\}`},
+	}
+	for _, tc := range testCases {
+		got := LatexEscape(tc.input)
+		if tc.want != got {
+			t.Errorf("LaTeX escape(%v): want %v, got %v", tc.input, tc.want, got)
+		}
+	}
+}
+
+func TestReadBibtexAll(t *testing.T) {
+	t.Parallel()
+
+	input := `% exported by a reference manager, questions to admin@example.org
+@string{elife = "eLife"}
+@article{sankar2014automated,
+    author = {Sankar, Martial and Kaisa Nieminen and {eLife Sciences \& Co} and others},
+    title = {Automated quantitative histology during \textit{Arabidopsis} hypocotyl secondary growth},
+    journal = elife # " Sciences",
+    volume = {3},
+    pages = {e01567--e01570},
+    year = {2014},
+    month = feb,
+    doi = {10.7554/elife.01567}
+}
+@comment{ignored}
+@book{broken, title = {unbalanced
+@misc(post,
+    title = "A {B}log post",
+    url = {http://Example.org/posts/1/#comments},
+    date = {2021-05-06}
+)
+`
+	results, err := ReadBibtexAll([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Read BibTeX: want 3 entries, got %v", len(results))
+	}
+
+	article := results[0].Data
+	if article.ID != "https://doi.org/10.7554/elife.01567" || article.Type != "JournalArticle" {
+		t.Errorf("Read BibTeX: want eLife article, got %v %v", article.ID, article.Type)
+	}
+	if len(article.Contributors) != 3 || article.Contributors[1].FamilyName != "Nieminen" || article.Contributors[2].Name != "eLife Sciences & Co" {
+		t.Errorf("Read BibTeX: want 3 authors, got %v", article.Contributors)
+	}
+	if want := "Automated quantitative histology during <i>Arabidopsis</i> hypocotyl secondary growth"; mainTitle(article) != want {
+		t.Errorf("Read BibTeX: want %v, got %v", want, mainTitle(article))
+	}
+	if c := article.Container; c.Title != "eLife Sciences" || c.Type != "Journal" || c.FirstPage != "e01567" || c.LastPage != "e01570" {
+		t.Errorf("Read BibTeX: want container eLife Sciences, got %v", c)
+	}
+	if article.Date.Published != "2014-02" {
+		t.Errorf("Read BibTeX: want date 2014-02, got %v", article.Date.Published)
+	}
+
+	if results[1].Err == nil || !strings.HasPrefix(string(results[1].Raw), "@book{broken") {
+		t.Errorf("Read BibTeX: want error for unbalanced entry, got %v", results[1].Err)
+	}
+
+	// entries without DOI get a pid from their URL
+	post := results[2].Data
+	if post.ID != "https://example.org/posts/1" || post.Type != "Other" || mainTitle(post) != "A Blog post" || post.Date.Published != "2021-05-06" {
+		t.Errorf("Read BibTeX: want blog post with pid from URL, got %v %v %v %v", post.ID, post.Type, mainTitle(post), post.Date.Published)
+	}
+}
+
+func TestBibtexContributor(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input string
+		want  commonmeta.Contributor
+	}
+
+	testCases := []testCase{
+		{input: "Fenner, Martin", want: commonmeta.Contributor{Type: "Person", GivenName: "Martin", FamilyName: "Fenner"}},
+		{input: "Martin Fenner", want: commonmeta.Contributor{Type: "Person", GivenName: "Martin", FamilyName: "Fenner"}},
+		{input: "Ludwig van Beethoven", want: commonmeta.Contributor{Type: "Person", GivenName: "Ludwig", FamilyName: "van Beethoven"}},
+		{input: "King, Jr, Martin Luther", want: commonmeta.Contributor{Type: "Person", GivenName: "Martin Luther", FamilyName: "King"}},
+		{input: "{Barnes and Noble, Inc.}", want: commonmeta.Contributor{Type: "Organization", Name: "Barnes and Noble, Inc."}},
+		{input: `M{\"u}ller, J{\"o}rg`, want: commonmeta.Contributor{Type: "Person", GivenName: "Jörg", FamilyName: "Müller"}},
+	}
+	for _, tc := range testCases {
+		got := bibtexContributor(tc.input, "Author")
+		if got.Type != tc.want.Type || got.Name != tc.want.Name || got.GivenName != tc.want.GivenName || got.FamilyName != tc.want.FamilyName {
+			t.Errorf("BibTeX contributor(%v): want %v, got %v", tc.input, tc.want, got)
+		}
+	}
+}
+
+func TestLatexUnescape(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input string
+		want  string
+	}
+
+	testCases := []testCase{
+		{input: `50\% of R\&D costs \$5 \#1`, want: "50% of R&D costs $5 #1"},
+		{input: `H\textsubscript{2}O and \emph{E. coli}`, want: "H<sub>2</sub>O and <i>E. coli</i>"},
+		{input: `The {DNA} of {\"U}ber-Caf\'{e}s`, want: "The DNA of Über-Cafés"},
+		{input: `Gar{\c c}on, na\"\i ve \ss{}e, \o re and \v{S}koda`, want: "Garçon, naïve ße, øre and Škoda"},
+		{input: `pages 1--10, a pause---then~more`, want: "pages 1–10, a pause—then more"},
+		{input: `snake\_case \textbackslash{} \unknown{kept} \alpha`, want: `snake_case \ kept`},
+	}
+	for _, tc := range testCases {
+		got := LatexUnescape(tc.input)
+		if tc.want != got {
+			t.Errorf("LaTeX unescape(%v): want %v, got %v", tc.input, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"gopkg.in/yaml.v3"
+)
+
+// CFFVersion is the version of the Citation File Format written
+const CFFVersion = "1.2.0"
+
+// CFF represents a CITATION.cff file
+type CFF struct {
+	CFFVersion     string          `yaml:"cff-version"`
+	Message        string          `yaml:"message"`
+	Type           string          `yaml:"type"`
+	Title          string          `yaml:"title"`
+	Version        string          `yaml:"version,omitempty"`
+	DOI            string          `yaml:"doi,omitempty"`
+	DateReleased   string          `yaml:"date-released,omitempty"`
+	Authors        []CFFAuthor     `yaml:"authors"`
+	Abstract       string          `yaml:"abstract,omitempty"`
+	Keywords       []string        `yaml:"keywords,omitempty"`
+	License        string          `yaml:"license,omitempty"`
+	LicenseURL     string          `yaml:"license-url,omitempty"`
+	RepositoryCode string          `yaml:"repository-code,omitempty"`
+	URL            string          `yaml:"url,omitempty"`
+	Identifiers    []CFFIdentifier `yaml:"identifiers,omitempty"`
+}
+
+// CFFAuthor is a person or entity
+type CFFAuthor struct {
+	FamilyNames string `yaml:"family-names,omitempty"`
+	GivenNames  string `yaml:"given-names,omitempty"`
+	Name        string `yaml:"name,omitempty"`
+	ORCID       string `yaml:"orcid,omitempty"`
+	Affiliation string `yaml:"affiliation,omitempty"`
+}
+
+// CFFIdentifier is an identifier of type doi, url, swh or other
+type CFFIdentifier struct {
+	Type        string `yaml:"type"`
+	Value       string `yaml:"value"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// WriteCFF converts commonmeta metadata of a software work to a CITATION.cff
+// file in Citation File Format 1.2.0. Title and at least one author are required.
+func WriteCFF(data commonmeta.Data) (string, error) {
+	c := CFF{
+		CFFVersion:     CFFVersion,
+		Message:        "If you use this software, please cite it using the metadata from this file.",
+		Type:           "software",
+		Title:          stripTags(mainTitle(data)),
+		Version:        data.Version,
+		DOI:            doiFromPid(data.ID),
+		Abstract:       strings.Join(strings.Fields(stripTags(abstract(data))), " "),
+		Keywords:       keywords(data),
+		License:        data.License.ID,
+		RepositoryCode: codeRepository(data),
+		URL:            data.URL,
+	}
+	if c.License == "" {
+		c.LicenseURL = data.License.URL
+	}
+	// CFF dates must be full dates
+	if d := publicationDate(data); dayRegexp.MatchString(d) {
+		c.DateReleased = d
+	} else if len(d) >= 10 && dayRegexp.MatchString(d[:10]) {
+		c.DateReleased = d[:10]
+	}
+	for _, a := range authors(data) {
+		author := CFFAuthor{}
+		if a.Type == "Organization" || a.FamilyName == "" {
+			author.Name = a.Name
+		} else {
+			author.FamilyNames = a.FamilyName
+			author.GivenNames = a.GivenName
+		}
+		if strings.HasPrefix(a.ID, "https://orcid.org/") {
+			author.ORCID = a.ID
+		}
+		affiliations := make([]string, 0)
+		for _, v := range a.Affiliations {
+			if v != nil && v.Name != "" {
+				affiliations = append(affiliations, v.Name)
+			}
+		}
+		author.Affiliation = strings.Join(affiliations, "; ")
+		if author.Name != "" || author.FamilyNames != "" {
+			c.Authors = append(c.Authors, author)
+		}
+	}
+	if c.Title == "" {
+		return "", fmt.Errorf("missing title")
+	}
+	if len(c.Authors) == 0 {
+		return "", fmt.Errorf("missing authors")
+	}
+
+	if c.DOI == "" && data.ID != "" {
+		c.Identifiers = append(c.Identifiers, CFFIdentifier{Type: "url", Value: data.ID})
+	}
+	for _, i := range data.Identifiers {
+		if i.Identifier != "" && i.Identifier != data.ID {
+			c.Identifiers = append(c.Identifiers, cffIdentifier(i.Identifier, i.IdentifierType))
+		}
+	}
+	for _, r := range data.Relations {
+		if r.ID != "" && r.ID != c.RepositoryCode {
+			identifier := cffIdentifier(r.ID, "")
+			identifier.Description = r.Type
+			c.Identifiers = append(c.Identifiers, identifier)
+		}
+	}
+
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// cffIdentifier returns a CFF identifier, recognizing DOIs, Software Heritage
+// identifiers and URLs
+func cffIdentifier(id string, identifierType string) CFFIdentifier {
+	switch {
+	case doiFromPid(id) != "":
+		return CFFIdentifier{Type: "doi", Value: doiFromPid(id)}
+	case identifierType == "DOI":
+		return CFFIdentifier{Type: "doi", Value: id}
+	case strings.HasPrefix(id, "swh:"):
+		return CFFIdentifier{Type: "swh", Value: id}
+	case isIRI(id):
+		return CFFIdentifier{Type: "url", Value: id}
+	default:
+		return CFFIdentifier{Type: "other", Value: id}
+	}
+}
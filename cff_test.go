@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+func TestWriteCFF(t *testing.T) {
+	t.Parallel()
+
+	want := `cff-version: 1.2.0
+message: If you use this software, please cite it using the metadata from this file.
+type: software
+title: 'commonmeta: convert scholarly metadata'
+version: v0.3.18
+doi: 10.5281/zenodo.8340374
+date-released: "2024-05-10"
+authors:
+  - family-names: Fenner
+    given-names: Martin
+    orcid: https://orcid.org/0000-0003-1419-2405
+    affiliation: Front Matter
+abstract: Library for conversions to/from the Commonmeta scholarly metadata format
+keywords:
+  - scholarly metadata
+license: MIT
+repository-code: https://github.com/front-matter/commonmeta/tree/v0.3.18
+url: https://zenodo.org/records/11176215
+identifiers:
+  - type: swh
+    value: swh:1:dir:d198bc9d7a6bcf6db04f476d29314f157507d505
+  - type: doi
+    value: 10.5281/zenodo.8340373
+    description: IsVersionOf
+`
+	got, err := WriteCFF(softwareData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != got {
+		t.Errorf("Write CFF: want\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestWriteCFFMissingAuthors(t *testing.T) {
+	t.Parallel()
+
+	data := commonmeta.Data{ID: "https://doi.org/10.5281/zenodo.8340374", Type: "Software", Titles: []commonmeta.Title{{Title: "commonmeta"}}}
+	_, err := WriteCFF(data)
+	if err == nil || err.Error() != "missing authors" {
+		t.Errorf("Write CFF: want missing authors, got %v", err)
+	}
+}
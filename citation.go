@@ -0,0 +1,999 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/csl"
+)
+
+// CSL styles and locales bundled with the server, so that citations can be
+// formatted offline. Both are published under a CC BY-SA 3.0 license by the
+// Citation Style Language project.
+//
+//go:embed resources/styles/*.csl resources/locales/*.xml
+var cslResources embed.FS
+
+// DefaultStyle and DefaultLocale are used when a request doesn't specify a style or locale
+const (
+	DefaultStyle  = "apa"
+	DefaultLocale = "en-US"
+)
+
+// StyleAliases maps short names to the file names of the bundled CSL styles
+var StyleAliases = map[string]string{
+	"chicago": "chicago-author-date",
+	"harvard": "harvard-cite-them-right",
+	"mla":     "modern-language-association",
+}
+
+// primary dialects of the languages of the bundled locales
+var localeDialects = map[string]string{
+	"de": "de-DE",
+	"en": "en-US",
+	"es": "es-ES",
+	"fr": "fr-FR",
+}
+
+// Styles returns the names of the bundled CSL styles
+func Styles() []string {
+	entries, _ := cslResources.ReadDir("resources/styles")
+	styles := make([]string, 0, len(entries))
+	for _, e := range entries {
+		styles = append(styles, strings.TrimSuffix(e.Name(), ".csl"))
+	}
+	return styles
+}
+
+// Locales returns the names of the bundled CSL locales
+func Locales() []string {
+	entries, _ := cslResources.ReadDir("resources/locales")
+	locales := make([]string, 0, len(entries))
+	for _, e := range entries {
+		locales = append(locales, strings.TrimSuffix(strings.TrimPrefix(e.Name(), "locales-"), ".xml"))
+	}
+	return locales
+}
+
+// WriteCitation formats commonmeta metadata as a plain text bibliography entry,
+// using one of the bundled CSL styles and locales. Unknown locales fall back to
+// en-US, unknown styles return an error.
+func WriteCitation(data commonmeta.Data, style string, locale string) (string, error) {
+	if style == "" {
+		style = DefaultStyle
+	}
+	if alias, ok := StyleAliases[style]; ok {
+		style = alias
+	}
+	if !slices.Contains(Styles(), style) {
+		return "", fmt.Errorf("style %s not available, use one of %s", style, strings.Join(Styles(), ", "))
+	}
+	b, err := cslResources.ReadFile(path.Join("resources/styles", style+".csl"))
+	if err != nil {
+		return "", err
+	}
+	var root cslNode
+	if err := xml.Unmarshal(b, &root); err != nil {
+		return "", err
+	}
+	bibliography := root.child("bibliography")
+	if bibliography == nil || bibliography.child("layout") == nil {
+		return "", fmt.Errorf("style %s has no bibliography", style)
+	}
+
+	// CSL JSON is the input format of CSL styles
+	item, err := csl.Convert(data)
+	if err != nil {
+		return "", err
+	}
+	j, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	var vars map[string]interface{}
+	if err := json.Unmarshal(j, &vars); err != nil {
+		return "", err
+	}
+	vars["citation-number"] = "1"
+
+	p := &citeproc{
+		style:      &root,
+		macros:     map[string]*cslNode{},
+		item:       vars,
+		suppressed: map[string]bool{},
+		options:    map[string]string{},
+	}
+	for _, m := range root.children("macro") {
+		p.macros[m.attr("name")] = m
+	}
+	if locale == "" {
+		locale = root.attr("default-locale")
+	}
+	p.loadLocales(locale)
+	// name options are inherited from the style and bibliography elements
+	for _, n := range []*cslNode{&root, bibliography} {
+		for _, a := range n.Attrs {
+			p.options[a.Name.Local] = a.Value
+		}
+	}
+
+	out := p.render(bibliography.child("layout"))
+	return p.cleanup(out.text), nil
+}
+
+// cslNode is a generic element of a CSL style or locale
+type cslNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []*cslNode `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+// attr returns the value of an attribute. The last value wins, so that attributes
+// can be overridden by appending them.
+func (n *cslNode) attr(name string) string {
+	value := ""
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			value = a.Value
+		}
+	}
+	return value
+}
+
+// lookupAttr returns the value of an attribute and whether it is set, as some
+// attributes can be set to an empty string
+func (n *cslNode) lookupAttr(name string) (string, bool) {
+	for i := len(n.Attrs) - 1; i >= 0; i-- {
+		if n.Attrs[i].Name.Local == name {
+			return n.Attrs[i].Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *cslNode) child(name string) *cslNode {
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *cslNode) children(name string) []*cslNode {
+	nodes := make([]*cslNode, 0)
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// cslLocale holds the terms and date formats of a locale
+type cslLocale struct {
+	terms              map[string]string
+	dates              map[string]*cslNode
+	punctuationInQuote string
+}
+
+func newCSLLocale(n *cslNode) *cslLocale {
+	l := &cslLocale{terms: map[string]string{}, dates: map[string]*cslNode{}}
+	if o := n.child("style-options"); o != nil {
+		l.punctuationInQuote = o.attr("punctuation-in-quote")
+	}
+	for _, d := range n.children("date") {
+		l.dates[d.attr("form")] = d
+	}
+	if terms := n.child("terms"); terms != nil {
+		for _, t := range terms.children("term") {
+			form := t.attr("form")
+			if form == "" {
+				form = "long"
+			}
+			key := t.attr("name") + "/" + form
+			single, multiple := t.child("single"), t.child("multiple")
+			if single != nil || multiple != nil {
+				if single != nil {
+					l.terms[key+"/single"] = single.Text
+				}
+				if multiple != nil {
+					l.terms[key+"/multiple"] = multiple.Text
+				}
+			} else {
+				l.terms[key+"/single"] = t.Text
+				l.terms[key+"/multiple"] = t.Text
+			}
+		}
+	}
+	return l
+}
+
+// citeproc renders a single item with a CSL style
+type citeproc struct {
+	style      *cslNode
+	macros     map[string]*cslNode
+	locales    []*cslLocale
+	lang       string
+	item       map[string]interface{}
+	suppressed map[string]bool
+	options    map[string]string
+}
+
+// cslOutput is the rendered output of an element. called and rendered track
+// whether variables were called and non-empty, to suppress empty groups.
+type cslOutput struct {
+	text     string
+	called   bool
+	rendered bool
+}
+
+// loadLocales builds the locale fallback chain for a locale, starting with the
+// locales defined in the style, followed by the bundled locale files and en-US.
+func (p *citeproc) loadLocales(locale string) {
+	language, _, _ := strings.Cut(locale, "-")
+	if !slices.Contains(Locales(), locale) {
+		// use the primary dialect for other dialects of a language, e.g. de-DE for de-AT
+		locale = localeDialects[language]
+	}
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	p.lang = locale
+	language, _, _ = strings.Cut(locale, "-")
+	for _, lang := range []string{locale, language, ""} {
+		for _, n := range p.style.children("locale") {
+			if n.attr("lang") == lang {
+				p.locales = append(p.locales, newCSLLocale(n))
+			}
+		}
+	}
+	for _, lang := range []string{locale, DefaultLocale} {
+		b, err := cslResources.ReadFile(path.Join("resources/locales", "locales-"+lang+".xml"))
+		if err != nil {
+			continue
+		}
+		var n cslNode
+		if err := xml.Unmarshal(b, &n); err == nil {
+			p.locales = append(p.locales, newCSLLocale(&n))
+		}
+		if lang == DefaultLocale {
+			break
+		}
+	}
+}
+
+// term looks up a term in the locale fallback chain, falling back to the
+// long form if the requested form isn't defined
+func (p *citeproc) term(name string, form string, plural bool) string {
+	number := "single"
+	if plural {
+		number = "multiple"
+	}
+	forms := []string{form, "long"}
+	switch form {
+	case "verb-short":
+		forms = []string{"verb-short", "verb", "long"}
+	case "symbol":
+		forms = []string{"symbol", "short", "long"}
+	case "", "long":
+		forms = []string{"long"}
+	}
+	for _, f := range forms {
+		for _, l := range p.locales {
+			if t, ok := l.terms[name+"/"+f+"/"+number]; ok {
+				return t
+			}
+		}
+	}
+	return ""
+}
+
+func (p *citeproc) dateFormat(form string) *cslNode {
+	for _, l := range p.locales {
+		if d, ok := l.dates[form]; ok {
+			return d
+		}
+	}
+	return nil
+}
+
+func (p *citeproc) punctuationInQuote() bool {
+	for _, l := range p.locales {
+		if l.punctuationInQuote != "" {
+			return l.punctuationInQuote == "true"
+		}
+	}
+	return false
+}
+
+// variable returns a standard or number variable as string
+func (p *citeproc) variable(name string, form string) string {
+	if p.suppressed[name] {
+		return ""
+	}
+	if form == "short" {
+		if s := p.variable(name+"-short", ""); s != "" {
+			return s
+		}
+	}
+	if name == "page-first" {
+		pages := strings.FieldsFunc(p.variable("page", ""), func(r rune) bool { return strings.ContainsRune("-–,&", r) })
+		if len(pages) == 0 {
+			return ""
+		}
+		return strings.TrimSpace(pages[0])
+	}
+	v, ok := p.item[name]
+	if !ok || v == nil {
+		return ""
+	}
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+	s = stripTags(s)
+	if name == "page" && p.options["page-range-format"] != "" {
+		s = strings.Replace(s, "-", p.term("page-range-delimiter", "", false), 1)
+	}
+	return s
+}
+
+// names returns a name variable as list of names
+func (p *citeproc) names(name string) []map[string]string {
+	list := make([]map[string]string, 0)
+	if p.suppressed[name] {
+		return list
+	}
+	v, ok := p.item[name].([]interface{})
+	if !ok {
+		return list
+	}
+	for _, n := range v {
+		m, ok := n.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := map[string]string{}
+		for k, val := range m {
+			if s, ok := val.(string); ok {
+				name[k] = s
+			}
+		}
+		list = append(list, name)
+	}
+	return list
+}
+
+// date returns a date variable as year, month and day, with zero for missing parts
+func (p *citeproc) date(name string) []int {
+	parts := []int{0, 0, 0}
+	if p.suppressed[name] {
+		return parts
+	}
+	m, ok := p.item[name].(map[string]interface{})
+	if !ok {
+		return parts
+	}
+	dp, ok := m["date-parts"].([]interface{})
+	if !ok || len(dp) == 0 {
+		return parts
+	}
+	first, ok := dp[0].([]interface{})
+	if !ok {
+		return parts
+	}
+	for i := 0; i < len(first) && i < 3; i++ {
+		if f, ok := first[i].(float64); ok {
+			parts[i] = int(f)
+		}
+	}
+	return parts
+}
+
+func (p *citeproc) hasVariable(name string) bool {
+	if slices.Contains([]string{"locator", "first-reference-note-number"}, name) {
+		return false
+	}
+	return p.variable(name, "") != "" || len(p.names(name)) > 0 || p.date(name)[0] != 0
+}
+
+// render renders an element of a CSL style
+func (p *citeproc) render(n *cslNode) cslOutput {
+	var out cslOutput
+	switch n.XMLName.Local {
+	case "layout":
+		// the first field is aligned separately, e.g. the citation number in numeric styles
+		if p.options["second-field-align"] != "" && len(n.Nodes) > 1 {
+			first := p.render(n.Nodes[0])
+			out = p.renderChildren(n.Nodes[1:], n.attr("delimiter"))
+			out.text = strings.TrimSpace(first.text + " " + out.text)
+		} else {
+			out = p.renderChildren(n.Nodes, n.attr("delimiter"))
+		}
+	case "group":
+		out = p.renderChildren(n.Nodes, n.attr("delimiter"))
+		if out.called && !out.rendered {
+			return cslOutput{called: true}
+		}
+	case "choose":
+		for _, branch := range n.Nodes {
+			if branch.XMLName.Local == "else" || p.test(branch) {
+				return p.renderChildren(branch.Nodes, "")
+			}
+		}
+		return out
+	case "text":
+		out = p.renderText(n)
+	case "number":
+		v := n.attr("variable")
+		s := p.variable(v, "")
+		if s != "" && isNumeric(s) {
+			s = p.formatNumber(s, n.attr("form"))
+		}
+		out = cslOutput{text: s, called: true, rendered: s != ""}
+	case "label":
+		out.text = p.renderLabel(n, n.attr("variable"), -1)
+	case "names":
+		out = p.renderNames(n, nil)
+	case "date":
+		out = p.renderDate(n)
+	}
+	out.text = p.format(n, out.text)
+	return out
+}
+
+func (p *citeproc) renderChildren(nodes []*cslNode, delimiter string) cslOutput {
+	var out cslOutput
+	parts := make([]string, 0)
+	for _, c := range nodes {
+		o := p.render(c)
+		out.called = out.called || o.called
+		out.rendered = out.rendered || o.rendered
+		if o.text != "" {
+			parts = append(parts, o.text)
+		}
+	}
+	out.text = strings.Join(parts, delimiter)
+	return out
+}
+
+func (p *citeproc) renderText(n *cslNode) cslOutput {
+	if v := n.attr("variable"); v != "" {
+		s := p.variable(v, n.attr("form"))
+		return cslOutput{text: s, called: true, rendered: s != ""}
+	}
+	if m := n.attr("macro"); m != "" {
+		if macro, ok := p.macros[m]; ok {
+			return p.renderChildren(macro.Nodes, "")
+		}
+		return cslOutput{}
+	}
+	if t := n.attr("term"); t != "" {
+		return cslOutput{text: p.term(t, n.attr("form"), n.attr("plural") == "true")}
+	}
+	return cslOutput{text: n.attr("value")}
+}
+
+// renderLabel renders the term for a variable. count is the number of names
+// for name variables, or -1 for number variables.
+func (p *citeproc) renderLabel(n *cslNode, variable string, count int) string {
+	var plural bool
+	if count >= 0 {
+		plural = count > 1
+	} else {
+		v := p.variable(variable, "")
+		if v == "" {
+			return ""
+		}
+		plural = pluralRegexp.MatchString(v)
+	}
+	switch n.attr("plural") {
+	case "always":
+		plural = true
+	case "never":
+		plural = false
+	}
+	switch variable {
+	case "number-of-pages":
+		variable = "page"
+	case "number-of-volumes":
+		variable = "volume"
+	}
+	return p.term(variable, n.attr("form"), plural)
+}
+
+func (p *citeproc) formatNumber(s string, form string) string {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return s
+	}
+	switch form {
+	case "ordinal", "long-ordinal":
+		return s + p.ordinal(n)
+	case "roman":
+		return romanNumeral(n)
+	}
+	return s
+}
+
+// ordinal returns the ordinal suffix of a number, e.g. st for 1 in English
+func (p *citeproc) ordinal(n int) string {
+	var s string
+	if n%100 >= 11 && n%100 <= 13 {
+		s = p.term(fmt.Sprintf("ordinal-%02d", n%100), "", false)
+	} else if n%10 != 0 {
+		s = p.term(fmt.Sprintf("ordinal-%02d", n%10), "", false)
+	}
+	if s == "" {
+		s = p.term("ordinal", "", false)
+	}
+	return s
+}
+
+// renderNames renders name variables. parent is the names element of a substitute,
+// whose name, et-al and label children are inherited by names elements without children.
+func (p *citeproc) renderNames(n *cslNode, parent *cslNode) cslOutput {
+	out := cslOutput{called: true}
+	options := n
+	if parent != nil && len(n.Nodes) == 0 {
+		options = parent
+	}
+	nameNode, etAlNode, labelNode := options.child("name"), options.child("et-al"), n.child("label")
+	variables := strings.Fields(n.attr("variable"))
+
+	// editor and translator are combined if identical
+	if slices.Contains(variables, "editor") && slices.Contains(variables, "translator") {
+		e, _ := json.Marshal(p.names("editor"))
+		t, _ := json.Marshal(p.names("translator"))
+		if len(p.names("editor")) > 0 && string(e) == string(t) {
+			p.item["editortranslator"] = p.item["editor"]
+			variables = slices.DeleteFunc(variables, func(v string) bool { return v == "translator" })
+			variables[slices.Index(variables, "editor")] = "editortranslator"
+		}
+	}
+
+	parts := make([]string, 0)
+	for _, v := range variables {
+		names := p.names(v)
+		if len(names) == 0 {
+			continue
+		}
+		s := p.format(nameNode, p.formatNames(names, nameNode, etAlNode))
+		// labels are rendered before or after the names, depending on their position
+		if labelNode != nil {
+			label := p.format(labelNode, p.renderLabel(labelNode, v, len(names)))
+			if nameNode != nil && slices.Index(n.Nodes, labelNode) < slices.Index(n.Nodes, nameNode) {
+				s = label + s
+			} else {
+				s += label
+			}
+		}
+		parts = append(parts, s)
+	}
+	if len(parts) > 0 {
+		delimiter := n.attr("delimiter")
+		if delimiter == "" {
+			delimiter = p.options["names-delimiter"]
+		}
+		out.text = strings.Join(parts, delimiter)
+		out.rendered = true
+		return out
+	}
+
+	// substitute if all name variables are empty
+	if sub := n.child("substitute"); sub != nil && parent == nil {
+		for _, c := range sub.Nodes {
+			var o cslOutput
+			if c.XMLName.Local == "names" {
+				o = p.renderNames(c, n)
+				o.text = p.format(c, o.text)
+			} else {
+				o = p.render(c)
+			}
+			if o.text != "" {
+				// variables used in a substitution are suppressed in the rest of the output
+				for _, v := range p.variables(c, map[string]bool{}) {
+					if p.hasVariable(v) {
+						p.suppressed[v] = true
+					}
+				}
+				o.called, o.rendered = true, true
+				return o
+			}
+		}
+	}
+	return out
+}
+
+// variables returns all variables called by an element, including macros
+func (p *citeproc) variables(n *cslNode, seen map[string]bool) []string {
+	vars := strings.Fields(n.attr("variable"))
+	if m := n.attr("macro"); m != "" && !seen[m] {
+		seen[m] = true
+		if macro, ok := p.macros[m]; ok {
+			vars = append(vars, p.variables(macro, seen)...)
+		}
+	}
+	for _, c := range n.Nodes {
+		vars = append(vars, p.variables(c, seen)...)
+	}
+	return vars
+}
+
+// lookupNameOption returns a name option from a name element, or inherited from the style
+func (p *citeproc) lookupNameOption(n *cslNode, name string, inherited string) (string, bool) {
+	if n != nil {
+		if v, ok := n.lookupAttr(name); ok {
+			return v, true
+		}
+	}
+	v, ok := p.options[inherited]
+	return v, ok
+}
+
+// nameOption returns a name option, or the fallback if the option isn't set
+func (p *citeproc) nameOption(n *cslNode, name string, inherited string, fallback string) string {
+	if v, ok := p.lookupNameOption(n, name, inherited); ok {
+		return v
+	}
+	return fallback
+}
+
+func (p *citeproc) formatNames(names []map[string]string, n *cslNode, etAlNode *cslNode) string {
+	form := p.nameOption(n, "form", "name-form", "long")
+	delimiter := p.nameOption(n, "delimiter", "name-delimiter", ", ")
+	if form == "count" {
+		return strconv.Itoa(len(names))
+	}
+
+	etAlMin, _ := strconv.Atoi(p.nameOption(n, "et-al-min", "et-al-min", "0"))
+	etAlUseFirst, _ := strconv.Atoi(p.nameOption(n, "et-al-use-first", "et-al-use-first", "0"))
+	etAlUseLast := p.nameOption(n, "et-al-use-last", "et-al-use-last", "false") == "true"
+	truncated := etAlMin > 0 && etAlUseFirst > 0 && len(names) >= etAlMin && etAlUseFirst < len(names)
+
+	shown := names
+	if truncated {
+		shown = names[:etAlUseFirst]
+	}
+	formatted := make([]string, len(shown))
+	inverted := make([]bool, len(shown))
+	for i, name := range shown {
+		formatted[i], inverted[i] = p.formatName(name, i, n, form)
+	}
+
+	if truncated {
+		if etAlUseLast && etAlUseFirst < len(names)-1 {
+			last, _ := p.formatName(names[len(names)-1], len(names)-1, n, form)
+			return strings.Join(formatted, delimiter) + delimiter + "… " + last
+		}
+		term := "et-al"
+		if etAlNode != nil && etAlNode.attr("term") != "" {
+			term = etAlNode.attr("term")
+		}
+		etAl := p.term(term, "", false)
+		if etAlNode != nil {
+			etAl = p.format(etAlNode, etAl)
+		}
+		precedes := p.nameOption(n, "delimiter-precedes-et-al", "delimiter-precedes-et-al", "contextual")
+		if precedes == "always" || (precedes == "contextual" && len(formatted) > 1) || (precedes == "after-inverted-name" && inverted[len(inverted)-1]) {
+			return strings.Join(formatted, delimiter) + delimiter + etAl
+		}
+		return strings.Join(formatted, delimiter) + " " + etAl
+	}
+
+	if len(formatted) == 1 {
+		return formatted[0]
+	}
+	and := p.nameOption(n, "and", "and", "")
+	var andTerm string
+	switch and {
+	case "text":
+		andTerm = p.term("and", "", false)
+	case "symbol":
+		andTerm = "&"
+	}
+	if andTerm == "" {
+		return strings.Join(formatted, delimiter)
+	}
+	precedes := p.nameOption(n, "delimiter-precedes-last", "delimiter-precedes-last", "contextual")
+	head := strings.Join(formatted[:len(formatted)-1], delimiter)
+	last := formatted[len(formatted)-1]
+	if precedes == "always" || (precedes == "contextual" && len(formatted) > 2) || (precedes == "after-inverted-name" && inverted[len(inverted)-2]) {
+		return head + delimiter + andTerm + " " + last
+	}
+	return head + " " + andTerm + " " + last
+}
+
+// formatName formats a single name, and returns whether it was inverted
+func (p *citeproc) formatName(name map[string]string, index int, n *cslNode, form string) (string, bool) {
+	if name["literal"] != "" || name["family"] == "" {
+		return name["literal"], false
+	}
+	family := name["family"]
+	if form == "short" {
+		return family, false
+	}
+	given := name["given"]
+	initializeWith, ok := p.lookupNameOption(n, "initialize-with", "initialize-with")
+	if ok && p.nameOption(n, "initialize", "initialize", "true") != "false" {
+		given = p.initialize(given, initializeWith)
+	}
+	order := p.nameOption(n, "name-as-sort-order", "name-as-sort-order", "")
+	if given == "" {
+		return family, false
+	}
+	if order == "all" || (order == "first" && index == 0) {
+		return family + p.nameOption(n, "sort-separator", "sort-separator", ", ") + given, true
+	}
+	return given + " " + family, false
+}
+
+// initialize reduces given names to initials, e.g. Jean-Paul Maria to J.-P. M.
+func (p *citeproc) initialize(given string, with string) string {
+	hyphen := p.options["initialize-with-hyphen"] != "false"
+	words := make([]string, 0)
+	for _, word := range strings.Fields(given) {
+		parts := strings.Split(word, "-")
+		initials := make([]string, 0, len(parts))
+		for _, part := range parts {
+			r, _ := utf8.DecodeRuneInString(part)
+			if r == utf8.RuneError || !unicode.IsUpper(r) {
+				// particles and already initialized names are kept as is
+				initials = append(initials, part)
+				continue
+			}
+			initials = append(initials, string(r)+with)
+		}
+		if hyphen {
+			words = append(words, strings.TrimSpace(strings.Join(initials, "-")))
+		} else {
+			words = append(words, strings.TrimSpace(strings.Join(initials, "")))
+		}
+	}
+	if strings.TrimSpace(with) == with {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words, " ")
+}
+
+func (p *citeproc) renderDate(n *cslNode) cslOutput {
+	out := cslOutput{called: true}
+	date := p.date(n.attr("variable"))
+	if date[0] == 0 {
+		return out
+	}
+	out.rendered = true
+
+	var parts []*cslNode
+	delimiter := n.attr("delimiter")
+	if form := n.attr("form"); form != "" {
+		// localized date format, with attributes overridden by the style
+		format := p.dateFormat(form)
+		if format == nil {
+			return out
+		}
+		delimiter = format.attr("delimiter")
+		include := map[string][]string{
+			"year":           {"year"},
+			"year-month":     {"year", "month"},
+			"year-month-day": {"year", "month", "day"},
+		}[n.attr("date-parts")]
+		if include == nil {
+			include = []string{"year", "month", "day"}
+		}
+		for _, dp := range format.children("date-part") {
+			if !slices.Contains(include, dp.attr("name")) {
+				continue
+			}
+			merged := &cslNode{XMLName: dp.XMLName, Attrs: slices.Clone(dp.Attrs)}
+			for _, override := range n.children("date-part") {
+				if override.attr("name") != dp.attr("name") {
+					continue
+				}
+				for _, a := range override.Attrs {
+					if a.Name.Local != "prefix" && a.Name.Local != "suffix" {
+						merged.Attrs = append(merged.Attrs, a)
+					}
+				}
+			}
+			parts = append(parts, merged)
+		}
+	} else {
+		parts = n.children("date-part")
+	}
+
+	values := make([]string, 0)
+	for _, dp := range parts {
+		var s string
+		form := dp.attr("form")
+		switch dp.attr("name") {
+		case "year":
+			if form == "short" {
+				s = fmt.Sprintf("%02d", date[0]%100)
+			} else {
+				s = strconv.Itoa(date[0])
+			}
+		case "month":
+			if date[1] < 1 || date[1] > 12 {
+				continue
+			}
+			switch form {
+			case "numeric":
+				s = strconv.Itoa(date[1])
+			case "numeric-leading-zeros":
+				s = fmt.Sprintf("%02d", date[1])
+			default:
+				s = p.term(fmt.Sprintf("month-%02d", date[1]), form, false)
+			}
+		case "day":
+			if date[2] == 0 {
+				continue
+			}
+			switch form {
+			case "numeric-leading-zeros":
+				s = fmt.Sprintf("%02d", date[2])
+			case "ordinal":
+				s = strconv.Itoa(date[2]) + p.ordinal(date[2])
+			default:
+				s = strconv.Itoa(date[2])
+			}
+		}
+		if s != "" {
+			values = append(values, p.format(dp, s))
+		}
+	}
+	out.text = strings.TrimSpace(strings.Join(values, delimiter))
+	return out
+}
+
+// test evaluates the conditions of an if or else-if element
+func (p *citeproc) test(n *cslNode) bool {
+	results := make([]bool, 0)
+	for _, t := range strings.Fields(n.attr("type")) {
+		results = append(results, p.item["type"] == t)
+	}
+	for _, v := range strings.Fields(n.attr("variable")) {
+		results = append(results, p.hasVariable(v))
+	}
+	for _, v := range strings.Fields(n.attr("is-numeric")) {
+		results = append(results, isNumeric(p.variable(v, "")))
+	}
+	// conditions only relevant for citations, or not supported
+	for _, attr := range []string{"is-uncertain-date", "locator", "position"} {
+		for range strings.Fields(n.attr(attr)) {
+			results = append(results, false)
+		}
+	}
+	if n.attr("disambiguate") != "" {
+		results = append(results, false)
+	}
+
+	switch n.attr("match") {
+	case "any":
+		return slices.Contains(results, true)
+	case "none":
+		return !slices.Contains(results, true)
+	default:
+		return len(results) > 0 && !slices.Contains(results, false)
+	}
+}
+
+var pluralRegexp = regexp.MustCompile(`\d\s*([-–,&]|and)\s*\d`)
+
+var numericRegexp = regexp.MustCompile(`^[a-zA-Z]?\d+[a-zA-Z]?(\s*([-–,&]|and)\s*[a-zA-Z]?\d+[a-zA-Z]?)*$`)
+
+func isNumeric(s string) bool {
+	return numericRegexp.MatchString(strings.TrimSpace(s))
+}
+
+// format applies the text-case, strip-periods, quotes and affixes attributes of an element
+func (p *citeproc) format(n *cslNode, s string) string {
+	if n == nil || s == "" {
+		return s
+	}
+	if n.attr("strip-periods") == "true" {
+		s = strings.ReplaceAll(s, ".", "")
+	}
+	switch n.attr("text-case") {
+	case "lowercase":
+		s = strings.ToLower(s)
+	case "uppercase":
+		s = strings.ToUpper(s)
+	case "capitalize-first", "sentence":
+		s = capitalize(s)
+	case "capitalize-all":
+		words := strings.Split(s, " ")
+		for i, w := range words {
+			words[i] = capitalize(w)
+		}
+		s = strings.Join(words, " ")
+	case "title":
+		// title case is only applied to English text
+		if strings.HasPrefix(p.lang, "en") {
+			s = titleCase(s)
+		}
+	}
+	if n.attr("quotes") == "true" {
+		s = p.term("open-quote", "", false) + s + p.term("close-quote", "", false)
+	}
+	return n.attr("prefix") + s + n.attr("suffix")
+}
+
+var titleCaseStopWords = []string{"a", "about", "above", "across", "after", "against", "along", "among", "an", "and", "around", "as", "at", "before", "behind", "below", "beneath", "beside", "between", "beyond", "but", "by", "down", "during", "except", "for", "from", "in", "inside", "into", "like", "near", "nor", "of", "off", "on", "onto", "or", "out", "over", "per", "since", "so", "than", "the", "through", "till", "to", "toward", "towards", "under", "until", "up", "upon", "via", "with", "within", "without", "yet"}
+
+// titleCase capitalizes all lowercase words except stop words
+func titleCase(s string) string {
+	words := strings.Split(s, " ")
+	for i, w := range words {
+		if i > 0 && i < len(words)-1 && slices.Contains(titleCaseStopWords, w) {
+			continue
+		}
+		if w == strings.ToLower(w) {
+			words[i] = capitalize(w)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+func romanNumeral(n int) string {
+	if n <= 0 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	numerals := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(numerals[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
+
+var (
+	spaceRegexp             = regexp.MustCompile(`\s+`)
+	spaceBeforePunctRegexp  = regexp.MustCompile(`\s+([,.;:])`)
+	duplicatePeriodRegexp   = regexp.MustCompile(`([.?!])\.(\s|$)`)
+	commaBeforePeriodRegexp = regexp.MustCompile(`[,;:]\.`)
+)
+
+// cleanup removes duplicate punctuation and whitespace created by joining affixes
+func (p *citeproc) cleanup(s string) string {
+	s = spaceRegexp.ReplaceAllString(s, " ")
+	s = spaceBeforePunctRegexp.ReplaceAllString(s, "$1")
+	s = duplicatePeriodRegexp.ReplaceAllString(s, "$1$2")
+	s = commaBeforePeriodRegexp.ReplaceAllString(s, ".")
+	if p.punctuationInQuote() {
+		// move periods and commas into the closing quotes of the locale
+		if q := p.term("close-quote", "", false); q != "" {
+			s = strings.NewReplacer(q+".", "."+q, q+",", ","+q).Replace(s)
+		}
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+func TestWriteCitation(t *testing.T) {
+	t.Parallel()
+
+	data := commonmeta.Data{
+		ID:   "https://doi.org/10.7554/elife.01567",
+		Type: "JournalArticle",
+		Contributors: []commonmeta.Contributor{
+			{Type: "Person", GivenName: "Martial", FamilyName: "Sankar", ContributorRoles: []string{"Author"}},
+			{Type: "Person", GivenName: "Kaisa", FamilyName: "Nieminen", ContributorRoles: []string{"Author"}},
+			{Type: "Person", GivenName: "Laura", FamilyName: "Ragni", ContributorRoles: []string{"Author"}},
+			{Type: "Person", GivenName: "Ioannis", FamilyName: "Xenarios", ContributorRoles: []string{"Author"}},
+			{Type: "Person", GivenName: "Christian S.", FamilyName: "Hardtke", ContributorRoles: []string{"Author"}},
+		},
+		Titles:    []commonmeta.Title{{Title: "Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth"}},
+		Container: commonmeta.Container{Type: "Journal", Title: "eLife", Volume: "3", FirstPage: "e01567"},
+		Publisher: commonmeta.Publisher{Name: "eLife Sciences Publications, Ltd"},
+		Date:      commonmeta.Date{Published: "2014-02-11"},
+		URL:       "https://elifesciences.org/articles/01567",
+	}
+
+	type testCase struct {
+		style  string
+		locale string
+		want   string
+	}
+
+	testCases := []testCase{
+		{style: "apa", locale: "", want: "Sankar, M., Nieminen, K., Ragni, L., Xenarios, I., & Hardtke, C. S. (2014). Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth. eLife, 3, e01567. https://doi.org/10.7554/elife.01567"},
+		{style: "chicago", locale: "en-US", want: "Sankar, Martial, Kaisa Nieminen, Laura Ragni, Ioannis Xenarios, and Christian S. Hardtke. 2014. “Automated Quantitative Histology Reveals Vascular Morphodynamics during Arabidopsis Hypocotyl Secondary Growth.” eLife 3 (February): e01567. https://doi.org/10.7554/elife.01567."},
+		{style: "harvard", locale: "", want: "Sankar, M. et al. (2014) ‘Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth’, eLife, 3, p. e01567. Available at: https://doi.org/10.7554/elife.01567."},
+		{style: "ieee", locale: "fr", want: "[1] M. Sankar, K. Nieminen, L. Ragni, I. Xenarios, et C. S. Hardtke, «\u202fAutomated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth\u202f», eLife, vol. 3, p. e01567, févr. 2014, doi: 10.7554/elife.01567."},
+		{style: "modern-language-association", locale: "de-DE", want: "Sankar, Martial, u. a. „Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth“. eLife, Bd. 3, Februar 2014, S. e01567, https://doi.org/10.7554/elife.01567."},
+		{style: "vancouver", locale: "en-GB", want: "1. Sankar M, Nieminen K, Ragni L, Xenarios I, Hardtke CS. Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth. eLife [Internet]. 2014 Feb 11;3:e01567. Available from: https://elifesciences.org/articles/01567"},
+	}
+	for _, tc := range testCases {
+		got, err := WriteCitation(data, tc.style, tc.locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.want != got {
+			t.Errorf("Write citation(%v, %v): want %v, got %v", tc.style, tc.locale, tc.want, got)
+		}
+	}
+
+	_, err := WriteCitation(data, "nature", "")
+	if err == nil {
+		t.Errorf("Write citation(nature): want error for unknown style")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+// CodemetaContext is the JSON-LD context of Codemeta 3.0
+const CodemetaContext = "https://w3id.org/codemeta/3.0"
+
+// Codemeta represents software metadata in Codemeta format
+type Codemeta struct {
+	Context        string          `json:"@context"`
+	Type           string          `json:"@type"`
+	ID             string          `json:"@id"`
+	Identifier     string          `json:"identifier,omitempty"`
+	Name           string          `json:"name,omitempty"`
+	Description    string          `json:"description,omitempty"`
+	Version        string          `json:"version,omitempty"`
+	License        string          `json:"license,omitempty"`
+	CodeRepository string          `json:"codeRepository,omitempty"`
+	URL            string          `json:"url,omitempty"`
+	DateCreated    string          `json:"dateCreated,omitempty"`
+	DatePublished  string          `json:"datePublished,omitempty"`
+	DateModified   string          `json:"dateModified,omitempty"`
+	Author         []CodemetaAgent `json:"author,omitempty"`
+	Contributor    []CodemetaAgent `json:"contributor,omitempty"`
+	Publisher      *CodemetaAgent  `json:"publisher,omitempty"`
+	Keywords       []string        `json:"keywords,omitempty"`
+	Funding        []CodemetaGrant `json:"funding,omitempty"`
+	RelatedLink    []string        `json:"relatedLink,omitempty"`
+	IsPartOf       []string        `json:"isPartOf,omitempty"`
+	InLanguage     string          `json:"inLanguage,omitempty"`
+	SameAs         []string        `json:"sameAs,omitempty"`
+}
+
+// CodemetaAgent is a person or organization
+type CodemetaAgent struct {
+	Type        string          `json:"@type"`
+	ID          string          `json:"@id,omitempty"`
+	GivenName   string          `json:"givenName,omitempty"`
+	FamilyName  string          `json:"familyName,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	Affiliation []CodemetaAgent `json:"affiliation,omitempty"`
+}
+
+// CodemetaGrant is a funding reference
+type CodemetaGrant struct {
+	Type       string         `json:"@type"`
+	ID         string         `json:"@id,omitempty"`
+	Identifier string         `json:"identifier,omitempty"`
+	Funder     *CodemetaAgent `json:"funder,omitempty"`
+}
+
+// WriteCodemeta converts commonmeta metadata of a software work to Codemeta.
+func WriteCodemeta(data commonmeta.Data) (Codemeta, error) {
+	if data.ID == "" {
+		return Codemeta{}, invalidWork("missing id")
+	}
+	c := Codemeta{
+		Context:        CodemetaContext,
+		Type:           "SoftwareSourceCode",
+		ID:             data.ID,
+		Identifier:     data.ID,
+		Name:           stripTags(mainTitle(data)),
+		Description:    stripTags(abstract(data)),
+		Version:        data.Version,
+		License:        licenseURL(data),
+		CodeRepository: codeRepository(data),
+		URL:            data.URL,
+		DateCreated:    data.Date.Created,
+		DatePublished:  data.Date.Published,
+		DateModified:   data.Date.Updated,
+		Keywords:       keywords(data),
+		InLanguage:     data.Language,
+	}
+	for _, v := range data.Contributors {
+		agent := codemetaAgent(v)
+		if slices.Contains(v.ContributorRoles, "Author") {
+			c.Author = append(c.Author, agent)
+		} else {
+			c.Contributor = append(c.Contributor, agent)
+		}
+	}
+	if data.Publisher.Name != "" {
+		c.Publisher = &CodemetaAgent{Type: "Organization", ID: data.Publisher.ID, Name: data.Publisher.Name}
+	}
+	for _, f := range data.FundingReferences {
+		if f.FunderName == "" && f.AwardNumber == "" {
+			continue
+		}
+		grant := CodemetaGrant{Type: "Grant", ID: f.AwardURI, Identifier: f.AwardNumber}
+		if f.FunderName != "" {
+			grant.Funder = &CodemetaAgent{Type: "Organization", ID: f.FunderIdentifier, Name: f.FunderName}
+		}
+		c.Funding = append(c.Funding, grant)
+	}
+	for _, i := range data.Identifiers {
+		if i.Identifier != data.ID && isIRI(i.Identifier) {
+			c.SameAs = append(c.SameAs, i.Identifier)
+		}
+	}
+	for _, r := range data.Relations {
+		if r.ID == "" || r.ID == c.CodeRepository {
+			continue
+		}
+		switch r.Type {
+		case "IsPartOf":
+			c.IsPartOf = append(c.IsPartOf, r.ID)
+		case "IsIdenticalTo":
+			c.SameAs = append(c.SameAs, r.ID)
+		default:
+			c.RelatedLink = append(c.RelatedLink, r.ID)
+		}
+	}
+	return c, nil
+}
+
+// codemetaAgent maps a commonmeta contributor to a Codemeta person or organization
+func codemetaAgent(v commonmeta.Contributor) CodemetaAgent {
+	agent := CodemetaAgent{ID: v.ID}
+	if v.Type == "Organization" {
+		agent.Type = "Organization"
+		agent.Name = v.Name
+	} else {
+		agent.Type = "Person"
+		agent.GivenName = v.GivenName
+		agent.FamilyName = v.FamilyName
+		if v.FamilyName == "" {
+			agent.Name = v.Name
+		}
+	}
+	for _, a := range v.Affiliations {
+		if a != nil && a.Name != "" {
+			agent.Affiliation = append(agent.Affiliation, CodemetaAgent{Type: "Organization", ID: a.ID, Name: a.Name})
+		}
+	}
+	return agent
+}
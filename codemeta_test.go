@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+var softwareData = commonmeta.Data{
+	ID:      "https://doi.org/10.5281/zenodo.8340374",
+	Type:    "Software",
+	Version: "v0.3.18",
+	Contributors: []commonmeta.Contributor{
+		{ID: "https://orcid.org/0000-0003-1419-2405", Type: "Person", GivenName: "Martin", FamilyName: "Fenner", ContributorRoles: []string{"Author"}, Affiliations: []*commonmeta.Affiliation{{Name: "Front Matter"}}},
+		{Type: "Organization", Name: "Front Matter", ContributorRoles: []string{"RightsHolder"}},
+	},
+	Titles:       []commonmeta.Title{{Title: "commonmeta: convert scholarly metadata"}},
+	Descriptions: []commonmeta.Description{{Description: "Library for conversions to/from\nthe Commonmeta scholarly metadata format", Type: "Abstract"}},
+	Publisher:    commonmeta.Publisher{Name: "Zenodo"},
+	Date:         commonmeta.Date{Published: "2024-05-10"},
+	Subjects:     []commonmeta.Subject{{Subject: "scholarly metadata"}},
+	License:      commonmeta.License{ID: "MIT", URL: "https://opensource.org/licenses/MIT"},
+	Identifiers:  []commonmeta.Identifier{{Identifier: "swh:1:dir:d198bc9d7a6bcf6db04f476d29314f157507d505", IdentifierType: "SWHID"}},
+	Relations: []commonmeta.Relation{
+		{ID: "https://github.com/front-matter/commonmeta/tree/v0.3.18", Type: "IsSupplementTo"},
+		{ID: "https://doi.org/10.5281/zenodo.8340373", Type: "IsVersionOf"},
+	},
+	URL: "https://zenodo.org/records/11176215",
+}
+
+func TestWriteCodemeta(t *testing.T) {
+	t.Parallel()
+
+	c, err := WriteCodemeta(softwareData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	want := []string{
+		`"@context":"https://w3id.org/codemeta/3.0","@type":"SoftwareSourceCode","@id":"https://doi.org/10.5281/zenodo.8340374"`,
+		`"version":"v0.3.18"`,
+		`"license":"https://opensource.org/licenses/MIT"`,
+		`"codeRepository":"https://github.com/front-matter/commonmeta/tree/v0.3.18"`,
+		`"author":[{"@type":"Person","@id":"https://orcid.org/0000-0003-1419-2405","givenName":"Martin","familyName":"Fenner","affiliation":[{"@type":"Organization","name":"Front Matter"}]}]`,
+		`"contributor":[{"@type":"Organization","name":"Front Matter"}]`,
+		`"relatedLink":["https://doi.org/10.5281/zenodo.8340373"]`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Write Codemeta: want %v, got %v", w, got)
+		}
+	}
+}
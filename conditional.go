@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns a strong entity tag for a representation, computed from its
+// content type and serialized output
+func ETag(contentType string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(contentType))
+	h.Write([]byte{0})
+	h.Write(body)
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// RevisionHeader is the response header with the revision of a stored work,
+// sent with all its representations
+const RevisionHeader = "Work-Revision"
+
+// RevisionETag returns the strong entity tag of the revision of a stored work,
+// sent by the write API and checked against If-Match. It is independent of
+// the representations served by the resolver, which have their own ETag.
+func RevisionETag(work *Work) string {
+	return fmt.Sprintf(`"rev-%d"`, work.Revision)
+}
+
+// NotModified evaluates the If-None-Match and If-Modified-Since preconditions
+// of a GET or HEAD request, as defined in RFC 9110. If-Modified-Since is
+// ignored when If-None-Match is present.
+func NotModified(req *http.Request, etag string, modified time.Time) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	ims := req.Header.Get("If-Modified-Since")
+	if ims == "" || modified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(t)
+}
+
+// etagMatches checks whether a list of entity tags matches an etag, using the
+// weak comparison required for If-None-Match
+func etagMatches(list string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch evaluates the If-Match precondition of a request changing a
+// resource, as defined in RFC 9110, using the strong comparison. It returns
+// 428 Precondition Required (RFC 6585) without If-Match, 412 Precondition
+// Failed if no entity tag matches, and 0 if the precondition holds. An empty
+// etag stands for a missing resource.
+func IfMatch(req *http.Request, etag string) int {
+	im := req.Header.Get("If-Match")
+	if im == "" {
+		return http.StatusPreconditionRequired
+	}
+	for _, candidate := range strings.Split(im, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if etag != "" && (candidate == "*" || candidate == etag) {
+			return 0
+		}
+	}
+	return http.StatusPreconditionFailed
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestETag(t *testing.T) {
+	t.Parallel()
+
+	a := ETag("application/x-bibtex; charset=utf-8", []byte("@article{}"))
+	if a != ETag("application/x-bibtex; charset=utf-8", []byte("@article{}")) {
+		t.Errorf("ETag: want stable etag, got %v", a)
+	}
+	if a == ETag("text/plain; charset=utf-8", []byte("@article{}")) {
+		t.Errorf("ETag: want different etag per content type, got %v", a)
+	}
+	if len(a) != 34 || a[0] != '"' {
+		t.Errorf("ETag: want quoted strong etag, got %v", a)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	t.Parallel()
+
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 500, time.UTC)
+	etag := `"abc"`
+	type testCase struct {
+		method string
+		header map[string]string
+		want   bool
+	}
+	testCases := []testCase{
+		{method: http.MethodGet, want: false},
+		{method: http.MethodGet, header: map[string]string{"If-None-Match": `"abc"`}, want: true},
+		{method: http.MethodHead, header: map[string]string{"If-None-Match": `"xyz", W/"abc"`}, want: true},
+		{method: http.MethodGet, header: map[string]string{"If-None-Match": "*"}, want: true},
+		{method: http.MethodGet, header: map[string]string{"If-None-Match": `"xyz"`}, want: false},
+		{method: http.MethodGet, header: map[string]string{"If-Modified-Since": "Wed, 01 May 2024 10:00:00 GMT"}, want: true},
+		{method: http.MethodGet, header: map[string]string{"If-Modified-Since": "Wed, 01 May 2024 09:59:59 GMT"}, want: false},
+		{method: http.MethodGet, header: map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": "Wed, 01 May 2024 10:00:00 GMT"}, want: false},
+		{method: http.MethodPost, header: map[string]string{"If-None-Match": `"abc"`}, want: false},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, "/", nil)
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+		got := NotModified(req, etag, modified)
+		if tc.want != got {
+			t.Errorf("Not modified(%v %v): want %v, got %v", tc.method, tc.header, tc.want, got)
+		}
+	}
+}
+
+func TestServeWriterConditional(t *testing.T) {
+	t.Parallel()
+
+	w, _ := Writers.ByName("bibtex")
+	updated, err := types.ParseDateTime("2024-05-01 10:00:00.000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &WriteRequest{
+		Work:      &Work{Updated: updated},
+		Data:      commonmeta.Data{ID: "https://doi.org/10.7554/elife.01567", Type: "JournalArticle"},
+		MediaType: "application/x-bibtex",
+		Params:    map[string]string{},
+	}
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	if err := ServeWriter(e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), w, r); err != nil {
+		t.Fatal(err)
+	}
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Serve writer: want 200 with ETag, got %v %v", rec.Code, etag)
+	}
+	if got := rec.Header().Get("Last-Modified"); got != "Wed, 01 May 2024 10:00:00 GMT" {
+		t.Errorf("Serve writer: want Last-Modified Wed, 01 May 2024 10:00:00 GMT, got %v", got)
+	}
+
+	req := httptest.NewRequest(http.MethodHead, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	if err := ServeWriter(e.NewContext(req, rec), w, r); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("Serve writer: want 304 without body, got %v %v", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	t.Parallel()
+
+	etag := `"abc"`
+	type testCase struct {
+		header string
+		etag   string
+		want   int
+	}
+	testCases := []testCase{
+		{etag: etag, want: http.StatusPreconditionRequired},
+		{header: `"abc"`, etag: etag, want: 0},
+		{header: `"xyz", "abc"`, etag: etag, want: 0},
+		{header: "*", etag: etag, want: 0},
+		{header: `"xyz"`, etag: etag, want: http.StatusPreconditionFailed},
+		{header: `W/"abc"`, etag: etag, want: http.StatusPreconditionFailed},
+		{header: "*", etag: "", want: http.StatusPreconditionFailed},
+		{header: `"abc"`, etag: "", want: http.StatusPreconditionFailed},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		if tc.header != "" {
+			req.Header.Set("If-Match", tc.header)
+		}
+		got := IfMatch(req, tc.etag)
+		if tc.want != got {
+			t.Errorf("IfMatch(%v, %v): want %v, got %v", tc.header, tc.etag, tc.want, got)
+		}
+	}
+}
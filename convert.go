@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+)
+
+// maxBodySize is the maximum size of a payload sent for conversion or validation
+const maxBodySize = 10 << 20
+
+// readBody reads the request body, returning nil if it exceeds maxBodySize
+func readBody(c echo.Context) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxBodySize+1))
+	if err != nil || len(body) > maxBodySize {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Convert converts the metadata of a single work posted in the request body
+// from one format to another. The input format is selected with the from query
+// parameter or the Content-Type header, the output format with the to query
+// parameter or the Accept header. Conversions are stateless and never touch
+// the works collection.
+func Convert(c echo.Context) error {
+	reader, err := convertReader(c)
+	if err != nil {
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]interface{}{
+			"error":     err.Error(),
+			"available": Readers.MediaTypes(),
+		})
+	}
+
+	// only local writers can convert, commonmeta is the default
+	offers := []string{"application/vnd.commonmeta+json"}
+	for _, w := range Writers.Writers() {
+		if w.Delivery == Local && w.MediaType != offers[0] {
+			offers = append(offers, w.MediaType)
+		}
+	}
+	var writer *Writer
+	contentType := ""
+	params := map[string]string{}
+	if to := c.QueryParam("to"); to != "" {
+		w, ok := Writers.ByName(to)
+		if !ok {
+			w, ok = Writers.Lookup(to)
+		}
+		if !ok {
+			return notAcceptable(c, fmt.Sprintf("Format %s not supported", to))
+		}
+		writer, contentType = w, w.MediaType
+	} else {
+		c.Response().Header().Add("Vary", "Accept")
+		accept := c.Request().Header.Get("Accept")
+		if strings.TrimSpace(accept) == "" {
+			accept = "*/*"
+		}
+		offer, m, ok := NegotiateContentType(accept, offers)
+		if !ok {
+			return notAcceptable(c, fmt.Sprintf("No acceptable Content-Type in %s", accept))
+		}
+		writer, _ = Writers.Lookup(offer)
+		contentType = offer
+		params = m.Params
+	}
+	if writer.Delivery != Local {
+		return notAcceptable(c, fmt.Sprintf("Content-Type %s not supported for conversion", contentType))
+	}
+
+	body, err := readBody(c)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Payload too large"})
+	}
+	data, err := reader.Parse(body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid %s input: %s", reader.Name, err)})
+	}
+	if !writer.Supports(data.Type) {
+		return notAcceptable(c, fmt.Sprintf("Content-Type %s only supported for %s", contentType, strings.Join(writer.Types, ", ")))
+	}
+
+	// query parameters take precedence over media type parameters. There is
+	// nothing to redirect to, so text/html is always the landing page.
+	for k, v := range c.QueryParams() {
+		params[k] = v[0]
+	}
+	params["landing"] = ""
+	return ServeWriter(c, writer, &WriteRequest{
+		Data:      data,
+		MediaType: contentType,
+		Params:    params,
+		BaseURL:   c.Scheme() + "://" + c.Request().Host,
+	})
+}
+
+// convertReader selects the reader for a conversion by the from query
+// parameter, falling back to the Content-Type header
+func convertReader(c echo.Context) (*Reader, error) {
+	if from := c.QueryParam("from"); from != "" {
+		rd, ok := Readers.ByName(from)
+		if !ok {
+			rd, ok = Readers.Lookup(from)
+		}
+		if !ok {
+			return nil, fmt.Errorf("format %s not supported", from)
+		}
+		return rd, nil
+	}
+	header := c.Request().Header.Get("Content-Type")
+	if header == "" {
+		return nil, fmt.Errorf("missing from parameter or Content-Type")
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return nil, err
+	}
+	rd, ok := Readers.Lookup(mediaType)
+	if !ok {
+		return nil, fmt.Errorf("content type %s not supported", mediaType)
+	}
+	return rd, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+)
+
+const crossrefMessage = `{"status":"ok","message-type":"work","message":{
+	"DOI":"10.7554/elife.01567","type":"journal-article","URL":"https://elifesciences.org/articles/01567",
+	"title":["Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth"],
+	"author":[{"given":"Martial","family":"Sankar","sequence":"first"}],
+	"container-title":["eLife"],"publisher":"eLife Sciences Publications, Ltd",
+	"published":{"date-parts":[[2014,2,11]]},"issued":{"date-parts":[[2014,2,11]]}}}`
+
+const dataciteAttributes = `{"data":{"id":"10.5281/zenodo.5244404","attributes":{
+	"doi":"10.5281/zenodo.5244404","url":"https://zenodo.org/record/5244404",
+	"types":{"resourceTypeGeneral":"Software"},
+	"titles":[{"title":"commonmeta-ruby"}],
+	"creators":[{"name":"Fenner, Martin","givenName":"Martin","familyName":"Fenner","nameType":"Personal"}],
+	"publisher":"Zenodo","publicationYear":2021,"version":"v0.8.1"}}}`
+
+const bibtexEntries = `@article{sankar2014,
+	author = {Sankar, Martial},
+	title = {Automated quantitative histology},
+	journal = {eLife},
+	year = {2014},
+	doi = {10.7554/elife.01567}
+}
+@misc{other, title = {Another work}}`
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		query          string
+		contentType    string
+		accept         string
+		body           string
+		wantStatus     int
+		contentTypeOut string
+		contains       string
+	}
+	testCases := []testCase{
+		{query: "?from=crossref&to=bibtex", body: crossrefMessage, wantStatus: http.StatusOK, contentTypeOut: "application/x-bibtex; charset=utf-8", contains: "10.7554/elife.01567"},
+		{contentType: "application/vnd.crossref+json", accept: "application/x-research-info-systems", body: crossrefMessage, wantStatus: http.StatusOK, contentTypeOut: "application/x-research-info-systems; charset=utf-8", contains: "TY  - JOUR"},
+		{contentType: "application/vnd.datacite.datacite+json; charset=utf-8", body: dataciteAttributes, wantStatus: http.StatusOK, contentTypeOut: echo.MIMEApplicationJSONCharsetUTF8, contains: `"type":"Software"`},
+		{query: "?from=datacite&to=codemeta", body: dataciteAttributes, wantStatus: http.StatusOK, contains: "commonmeta-ruby"},
+		{query: "?from=crossref&to=codemeta", body: crossrefMessage, wantStatus: http.StatusNotAcceptable},
+		{query: "?from=crossref&to=html", body: crossrefMessage, wantStatus: http.StatusOK, contentTypeOut: "text/html; charset=utf-8"},
+		{query: "?from=crossref&to=pdf", body: crossrefMessage, wantStatus: http.StatusNotAcceptable},
+		{query: "?from=crossref&to=unknown", body: crossrefMessage, wantStatus: http.StatusNotAcceptable},
+		{query: "?from=unknown", body: crossrefMessage, wantStatus: http.StatusUnsupportedMediaType},
+		{contentType: "text/plain", body: crossrefMessage, wantStatus: http.StatusUnsupportedMediaType},
+		{body: crossrefMessage, wantStatus: http.StatusUnsupportedMediaType},
+		{query: "?from=crossref", body: `{"message":`, wantStatus: http.StatusBadRequest},
+		{query: "?from=bibtex&to=ris", body: strings.SplitAfter(bibtexEntries, "}\n}")[0], wantStatus: http.StatusOK, contains: "DO  - 10.7554/elife.01567"},
+		{contentType: "text/markdown; charset=utf-8", body: "---\ntitle: A post\ndoi: 10.5555/post\n---\nText", wantStatus: http.StatusOK, contains: `"id":"https://doi.org/10.5555/post"`},
+		{contentType: "application/x-bibtex", body: bibtexEntries, wantStatus: http.StatusBadRequest, contains: "expected a single work"},
+	}
+	e := echo.New()
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/convert"+tc.query, strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		rec := httptest.NewRecorder()
+		if err := Convert(e.NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantStatus != rec.Code {
+			t.Errorf("Convert(%v %v): want status %v, got %v %v", tc.query, tc.contentType, tc.wantStatus, rec.Code, rec.Body.String())
+		}
+		if tc.contentTypeOut != "" && tc.contentTypeOut != rec.Header().Get("Content-Type") {
+			t.Errorf("Convert(%v %v): want Content-Type %v, got %v", tc.query, tc.contentType, tc.contentTypeOut, rec.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rec.Body.String(), tc.contains) {
+			t.Errorf("Convert(%v %v): want %v, got %v", tc.query, tc.contentType, tc.contains, rec.Body.String())
+		}
+		if rec.Code == http.StatusUnsupportedMediaType {
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := body["available"]; !ok {
+				t.Errorf("Convert(%v %v): want available formats, got %v", tc.query, tc.contentType, body)
+			}
+		}
+	}
+}
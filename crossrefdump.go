@@ -0,0 +1,115 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImportCrossrefDataFile imports the Crossref public data file, a tarball or
+// a directory of gzip'd JSON files with the items of the Crossref REST API.
+// Items are read with the crossref reader by parallel workers, as when
+// fetching works lazily.
+func (im *Importer) ImportCrossrefDataFile(path string, workers int) error {
+	produce := func(send func(f dumpFile) error) error {
+		return eachDumpFile(path, im.dumpFilter(".json.gz", ".json"), send)
+	}
+	return im.importDump(workers, produce, parseCrossrefDataFile)
+}
+
+// parseCrossrefDataFile reads the items of a file of the Crossref public data file
+func parseCrossrefDataFile(f dumpFile) []dumpRecord {
+	reader, _ := Readers.ByName("crossref")
+	b, err := gunzip(f)
+	if err != nil {
+		return []dumpRecord{{source: f.name, err: err}}
+	}
+	var page struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(b, &page); err != nil {
+		return []dumpRecord{{source: f.name, err: err}}
+	}
+	records := make([]dumpRecord, 0, len(page.Items))
+	for i, raw := range page.Items {
+		data, err := reader.Parse(raw)
+		data.Provider = "Crossref"
+		records = append(records, dumpRecord{source: fmt.Sprintf("%s:%d", f.name, i+1), raw: raw, data: data, err: err})
+	}
+	return records
+}
+
+// eachDumpFile sends the files of a dump matched by include, either members
+// of a tarball (optionally gzip'd) or files in a directory
+func eachDumpFile(path string, include func(name string) bool, send func(f dumpFile) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !include(name) {
+				return err
+			}
+			b, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			return send(dumpFile{name: name, b: b})
+		})
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if header.Typeflag != tar.TypeReg || !include(header.Name) {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", header.Name, err)
+		}
+		if err := send(dumpFile{name: header.Name, b: b}); err != nil {
+			return err
+		}
+	}
+}
+
+// gunzip returns the uncompressed content of a gzip'd dump file
+func gunzip(f dumpFile) ([]byte, error) {
+	if !strings.HasSuffix(f.name, ".gz") {
+		return f.b, nil
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(f.b))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// crossrefDataFile returns a page of the Crossref public data file
+func crossrefDataFile(t *testing.T, items ...string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(`{"items":[` + strings.Join(items, ",") + `]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseCrossrefDataFile(t *testing.T) {
+	t.Parallel()
+
+	var envelope struct {
+		Message json.RawMessage `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(crossrefMessage), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	records := parseCrossrefDataFile(dumpFile{name: "0.json.gz", b: crossrefDataFile(t, string(envelope.Message), `{"DOI":1}`)})
+	if len(records) != 2 {
+		t.Fatalf("Parse Crossref data file: want 2 records, got %v", len(records))
+	}
+	if r := records[0]; r.err != nil || r.data.ID != "https://doi.org/10.7554/elife.01567" || r.data.Provider != "Crossref" || r.source != "0.json.gz:1" {
+		t.Errorf("Parse Crossref data file: want eLife article from Crossref, got %v %v %v %v", r.source, r.data.ID, r.data.Provider, r.err)
+	}
+	if r := records[1]; r.err == nil || r.source != "0.json.gz:2" {
+		t.Errorf("Parse Crossref data file: want error for invalid item, got %v %v", r.source, r.err)
+	}
+	if records := parseCrossrefDataFile(dumpFile{name: "1.json.gz", b: []byte("not gzip")}); len(records) != 1 || records[0].err == nil {
+		t.Errorf("Parse Crossref data file: want error for invalid file, got %v", records)
+	}
+}
+
+func TestEachDumpFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string][]byte{"0.json.gz": []byte("a"), "1.json.gz": []byte("b"), "README.md": []byte("c")}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"0.json.gz", "1.json.gz", "README.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), files[name], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: "data/" + name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tarball := filepath.Join(t.TempDir(), "crossref.tar")
+	if err := os.WriteFile(tarball, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{dir, tarball} {
+		got := make([]string, 0)
+		include := func(name string) bool { return strings.HasSuffix(name, ".json.gz") }
+		err := eachDumpFile(path, include, func(f dumpFile) error {
+			got = append(got, filepath.Base(f.name)+"="+string(f.b))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "0.json.gz=a 1.json.gz=b"; want != strings.Join(got, " ") {
+			t.Errorf("Each dump file(%v): want %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestImportDump(t *testing.T) {
+	t.Parallel()
+
+	// all records are rejected, so the importer doesn't need a database
+	var rejected bytes.Buffer
+	im := &Importer{BatchSize: 100, Rejected: &rejected}
+	produce := func(send func(f dumpFile) error) error {
+		for i := 0; i < 20; i++ {
+			if err := send(dumpFile{name: fmt.Sprintf("%d.json.gz", i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	parse := func(f dumpFile) []dumpRecord {
+		return []dumpRecord{{source: f.name + ":1", err: errors.New("invalid")}, {source: f.name + ":2", err: errors.New("invalid")}}
+	}
+	if err := im.importDump(4, produce, parse); err != nil {
+		t.Fatal(err)
+	}
+	if im.Stats.Read != 40 || im.Stats.Rejected != 40 {
+		t.Errorf("Import dump: want 40 read and rejected, got %v", im.Stats)
+	}
+	lines := strings.Split(strings.TrimSpace(rejected.String()), "\n")
+	for i := 0; i < len(lines); i += 2 {
+		if !strings.Contains(lines[i], `:1"`) || !strings.Contains(lines[i+1], `:2"`) {
+			t.Errorf("Import dump: want records of a file in order, got %v %v", lines[i], lines[i+1])
+		}
+	}
+
+	fail := func(send func(f dumpFile) error) error { return errors.New("broken tarball") }
+	if err := im.importDump(4, fail, parse); err == nil {
+		t.Errorf("Import dump: want error of broken tarball, got nil")
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/dateutils"
+)
+
+// CSLToCMMappings maps CSL item types to commonmeta types
+var CSLToCMMappings = map[string]string{
+	"article":                "Article",
+	"article-journal":        "JournalArticle",
+	"article-magazine":       "Article",
+	"article-newspaper":      "Article",
+	"post":                   "Article",
+	"post-weblog":            "BlogPost",
+	"book":                   "Book",
+	"chapter":                "BookChapter",
+	"collection":             "Collection",
+	"dataset":                "Dataset",
+	"document":               "Document",
+	"entry":                  "Entry",
+	"entry-dictionary":       "Entry",
+	"entry-encyclopedia":     "Entry",
+	"event":                  "Event",
+	"figure":                 "Image",
+	"graphic":                "Image",
+	"motion_picture":         "Audiovisual",
+	"broadcast":              "Audiovisual",
+	"manuscript":             "Document",
+	"paper-conference":       "ProceedingsArticle",
+	"periodical":             "Journal",
+	"report":                 "Report",
+	"software":               "Software",
+	"speech":                 "Presentation",
+	"standard":               "Standard",
+	"thesis":                 "Dissertation",
+	"webpage":                "WebPage",
+	"personal_communication": "Other",
+}
+
+// cslItem is an item of CSL JSON, as exported by reference managers
+type cslItem struct {
+	ID             cslString `json:"id"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	Author         []cslName `json:"author"`
+	Editor         []cslName `json:"editor"`
+	ContainerTitle cslString `json:"container-title"`
+	Volume         cslString `json:"volume"`
+	Issue          cslString `json:"issue"`
+	Page           cslString `json:"page"`
+	Issued         cslDate   `json:"issued"`
+	Publisher      string    `json:"publisher"`
+	DOI            string    `json:"DOI"`
+	URL            string    `json:"URL"`
+	ISSN           cslString `json:"ISSN"`
+	ISBN           cslString `json:"ISBN"`
+	Abstract       string    `json:"abstract"`
+	Keyword        string    `json:"keyword"`
+	Language       string    `json:"language"`
+	License        string    `json:"license"`
+	Version        cslString `json:"version"`
+}
+
+type cslName struct {
+	Family              string `json:"family"`
+	Given               string `json:"given"`
+	Literal             string `json:"literal"`
+	NonDroppingParticle string `json:"non-dropping-particle"`
+	DroppingParticle    string `json:"dropping-particle"`
+}
+
+type cslDate struct {
+	DateParts [][]cslString `json:"date-parts"`
+	Raw       string        `json:"raw"`
+}
+
+// cslString is a CSL variable exported as string, number or array of strings
+type cslString string
+
+func (s *cslString) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if list, ok := v.([]any); ok {
+		if len(list) == 0 {
+			*s = ""
+			return nil
+		}
+		v = list[0]
+	}
+	switch v := v.(type) {
+	case string:
+		*s = cslString(v)
+	case float64:
+		*s = cslString(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		*s = ""
+	}
+	return nil
+}
+
+// ReadCSLAll reads a CSL JSON item or an array of items
+func ReadCSLAll(b []byte) ([]ReadResult, error) {
+	results := make([]ReadResult, 0)
+	err := eachJSONRecord(bytes.NewReader(b), "csl", func(source string, raw []byte) error {
+		var item cslItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			results = append(results, ReadResult{Raw: raw, Err: err})
+			return nil
+		}
+		results = append(results, ReadResult{Raw: raw, Key: string(item.ID), Data: readCSLItem(item)})
+		return nil
+	})
+	return results, err
+}
+
+// readCSLItem converts a CSL JSON item to commonmeta
+func readCSLItem(item cslItem) commonmeta.Data {
+	data := commonmeta.Data{
+		ID:   workPid(item.DOI, item.URL),
+		Type: CSLToCMMappings[item.Type],
+		URL:  item.URL,
+	}
+	if data.ID == "" {
+		// the id of an item is sometimes its DOI or URL
+		data.ID = workPid("", string(item.ID))
+	}
+	if data.Type == "" {
+		data.Type = "Other"
+	}
+	for _, role := range []string{"Author", "Editor"} {
+		names := item.Author
+		if role == "Editor" {
+			names = item.Editor
+		}
+		for _, n := range names {
+			if n.Family == "" {
+				if n.Literal != "" {
+					data.Contributors = append(data.Contributors, contributorFromName(n.Literal, role))
+				}
+				continue
+			}
+			data.Contributors = append(data.Contributors, commonmeta.Contributor{
+				Type:             "Person",
+				GivenName:        strings.TrimSpace(n.Given + " " + n.DroppingParticle),
+				FamilyName:       strings.TrimSpace(n.NonDroppingParticle + " " + n.Family),
+				ContributorRoles: []string{role},
+			})
+		}
+	}
+	if item.Title != "" {
+		data.Titles = append(data.Titles, commonmeta.Title{Title: item.Title})
+	}
+
+	container := commonmeta.Container{
+		Title:  string(item.ContainerTitle),
+		Volume: string(item.Volume),
+		Issue:  string(item.Issue),
+	}
+	container.FirstPage, container.LastPage = pageRange(string(item.Page))
+	switch data.Type {
+	case "JournalArticle":
+		container.Type = "Journal"
+	case "ProceedingsArticle":
+		container.Type = "Proceedings"
+	case "BookChapter":
+		container.Type = "Book"
+	}
+	if container.Title == "" {
+		container.Type = ""
+	}
+	if item.ISSN != "" {
+		container.Identifier, container.IdentifierType = string(item.ISSN), "ISSN"
+	}
+	data.Container = container
+	if item.ISBN != "" {
+		data.Identifiers = append(data.Identifiers, commonmeta.Identifier{Identifier: string(item.ISBN), IdentifierType: "ISBN"})
+	}
+
+	if item.Publisher != "" {
+		data.Publisher = commonmeta.Publisher{Name: item.Publisher}
+	}
+	if len(item.Issued.DateParts) > 0 {
+		parts := make([]int, 0, 3)
+		for _, p := range item.Issued.DateParts[0] {
+			n, err := strconv.Atoi(string(p))
+			if err != nil {
+				break
+			}
+			parts = append(parts, n)
+		}
+		data.Date.Published = dateutils.GetDateFromParts(parts...)
+	} else {
+		data.Date.Published = isoDate(item.Issued.Raw)
+	}
+	if item.Abstract != "" {
+		data.Descriptions = append(data.Descriptions, commonmeta.Description{Description: item.Abstract, Type: "Abstract"})
+	}
+	if subjects := subjectsFromKeywords(item.Keyword); len(subjects) > 0 {
+		data.Subjects = subjects
+	}
+	data.Language = item.Language
+	data.License = licenseFromURL(item.License)
+	data.Version = string(item.Version)
+	return data
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReadCSLAll(t *testing.T) {
+	t.Parallel()
+
+	input := `[{"id":"sankar2014","type":"article-journal",
+	"title":"Automated quantitative histology","DOI":"10.7554/eLife.01567",
+	"author":[{"family":"Sankar","given":"Martial"},{"family":"Beethoven","given":"Ludwig","non-dropping-particle":"van"},{"literal":"eLife Sciences"}],
+	"container-title":["eLife"],"volume":3,"page":"e01567-e01570",
+	"issued":{"date-parts":[["2014","2","11"]]}},
+	{"id":"https://example.org/posts/1","type":"post-weblog","title":"A blog post",
+	"issued":{"raw":"2021/05"}},
+	{"id":"broken","title":["not a string"]}]`
+	results, err := ReadCSLAll([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Read CSL: want 3 items, got %v", len(results))
+	}
+
+	article := results[0].Data
+	if article.ID != "https://doi.org/10.7554/elife.01567" || article.Type != "JournalArticle" {
+		t.Errorf("Read CSL: want eLife article, got %v %v", article.ID, article.Type)
+	}
+	if len(article.Contributors) != 3 || article.Contributors[1].FamilyName != "van Beethoven" || article.Contributors[2].Type != "Organization" {
+		t.Errorf("Read CSL: want 3 authors, got %v", article.Contributors)
+	}
+	if c := article.Container; c.Title != "eLife" || c.Type != "Journal" || c.Volume != "3" || c.LastPage != "e01570" {
+		t.Errorf("Read CSL: want container eLife, got %v", c)
+	}
+	if article.Date.Published != "2014-02-11" {
+		t.Errorf("Read CSL: want date 2014-02-11, got %v", article.Date.Published)
+	}
+
+	post := results[1].Data
+	if post.ID != "https://example.org/posts/1" || post.Type != "BlogPost" || post.Date.Published != "2021-05" {
+		t.Errorf("Read CSL: want blog post with pid from id, got %v %v %v", post.ID, post.Type, post.Date.Published)
+	}
+
+	if results[2].Err == nil {
+		t.Errorf("Read CSL: want error for invalid item, got nil")
+	}
+}
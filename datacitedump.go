@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ImportDataciteDataFile imports the DataCite public data file, a tarball or
+// a directory of gzip'd JSON Lines files with one JSON:API record per line.
+// JSON files with a page of the DataCite REST API are supported as well.
+// Records are read with the datacite reader by parallel workers.
+func (im *Importer) ImportDataciteDataFile(path string, workers int) error {
+	produce := func(send func(f dumpFile) error) error {
+		return eachDumpFile(path, im.dumpFilter(".jsonl.gz", ".jsonl", ".json.gz", ".json"), send)
+	}
+	return im.importDump(workers, produce, parseDataciteDataFile)
+}
+
+// parseDataciteDataFile reads the records of a file of the DataCite public data file
+func parseDataciteDataFile(f dumpFile) []dumpRecord {
+	reader, _ := Readers.ByName("datacite")
+	b, err := gunzip(f)
+	if err != nil {
+		return []dumpRecord{{source: f.name, err: err}}
+	}
+	var records []dumpRecord
+	add := func(source string, raw []byte) error {
+		data, err := reader.Parse(raw)
+		data.Provider = "DataCite"
+		records = append(records, dumpRecord{source: source, raw: raw, data: data, err: err})
+		return nil
+	}
+	if strings.HasSuffix(strings.TrimSuffix(f.name, ".gz"), ".jsonl") {
+		if err := eachJSONLine(bytes.NewReader(b), f.name, add); err != nil {
+			return append(records, dumpRecord{source: f.name, err: err})
+		}
+		return records
+	}
+	var page struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &page); err != nil {
+		return []dumpRecord{{source: f.name, err: err}}
+	}
+	for i, raw := range page.Data {
+		add(fmt.Sprintf("%s:%d", f.name, i+1), raw)
+	}
+	return records
+}
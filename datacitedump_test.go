@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"testing"
+)
+
+// dataciteRecord is a JSON:API record of the DataCite public data file
+const dataciteRecord = `{"id":"10.5281/zenodo.5244404","type":"dois","attributes":{
+	"doi":"10.5281/zenodo.5244404","url":"https://zenodo.org/record/5244404",
+	"types":{"resourceTypeGeneral":"Software"},
+	"titles":[{"title":"commonmeta-ruby"}],
+	"creators":[{"name":"Fenner, Martin","givenName":"Martin","familyName":"Fenner","nameType":"Personal"}],
+	"publisher":"Zenodo","publicationYear":2021,"version":"v0.8.1"}}`
+
+func TestParseDataciteDataFile(t *testing.T) {
+	t.Parallel()
+
+	var record bytes.Buffer
+	if err := json.Compact(&record, []byte(dataciteRecord)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(record.String() + "\n\n" + `{"attributes":1}` + "\n"))
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		file    dumpFile
+		sources []string
+		valid   []bool
+	}
+	testCases := []testCase{
+		{file: dumpFile{name: "updated_2024-01/part_0.jsonl.gz", b: buf.Bytes()}, sources: []string{"updated_2024-01/part_0.jsonl.gz:1", "updated_2024-01/part_0.jsonl.gz:3"}, valid: []bool{true, false}},
+		{file: dumpFile{name: "page.json", b: []byte(`{"data":[` + dataciteRecord + `]}`)}, sources: []string{"page.json:1"}, valid: []bool{true}},
+		{file: dumpFile{name: "page.json", b: []byte(`{"data":`)}, sources: []string{"page.json"}, valid: []bool{false}},
+		{file: dumpFile{name: "part_1.jsonl.gz", b: []byte("not gzip")}, sources: []string{"part_1.jsonl.gz"}, valid: []bool{false}},
+	}
+	for _, tc := range testCases {
+		records := parseDataciteDataFile(tc.file)
+		if len(records) != len(tc.sources) {
+			t.Fatalf("Parse DataCite data file(%v): want %v records, got %v", tc.file.name, len(tc.sources), len(records))
+		}
+		for i, r := range records {
+			if r.source != tc.sources[i] || (r.err == nil) != tc.valid[i] {
+				t.Errorf("Parse DataCite data file(%v): want %v valid %v, got %v %v", tc.file.name, tc.sources[i], tc.valid[i], r.source, r.err)
+			}
+			if tc.valid[i] && (r.data.ID != "https://doi.org/10.5281/zenodo.5244404" || r.data.Provider != "DataCite") {
+				t.Errorf("Parse DataCite data file(%v): want Zenodo software from DataCite, got %v %v", tc.file.name, r.data.ID, r.data.Provider)
+			}
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/datacite"
+)
+
+//go:embed resources/datacite
+var dataciteResources embed.FS
+
+// DataciteSchemaLocation is the location of the DataCite Metadata Schema 4.5
+const DataciteSchemaLocation = "http://datacite.org/schema/kernel-4 https://schema.datacite.org/meta/kernel-4.5/metadata.xsd"
+
+// dataciteXSD compiles the DataCite schema bundled in resources/datacite once.
+// It is a subset of the official schema without related items, and accepts
+// identifiers that are not DOIs; the header of metadata.xsd lists the
+// differences.
+var dataciteXSD = sync.OnceValues(func() (*XSD, error) {
+	return LoadXSD(dataciteResources, "resources/datacite/metadata.xsd")
+})
+
+// controlled lists of the DataCite schema used when mapping commonmeta values
+var (
+	dataciteContributorTypes = []string{"ContactPerson", "DataCollector", "DataCurator", "DataManager", "Distributor", "Editor", "HostingInstitution", "Producer", "ProjectLeader", "ProjectManager", "ProjectMember", "RegistrationAgency", "RegistrationAuthority", "RelatedPerson", "Researcher", "ResearchGroup", "RightsHolder", "Sponsor", "Supervisor", "WorkPackageLeader", "Other"}
+	dataciteRelationTypes    = []string{"IsCitedBy", "Cites", "IsSupplementTo", "IsSupplementedBy", "IsContinuedBy", "Continues", "IsDescribedBy", "Describes", "HasMetadata", "IsMetadataFor", "HasVersion", "IsVersionOf", "IsNewVersionOf", "IsPreviousVersionOf", "IsPartOf", "HasPart", "IsPublishedIn", "IsReferencedBy", "References", "IsDocumentedBy", "Documents", "IsCompiledBy", "Compiles", "IsVariantFormOf", "IsOriginalFormOf", "IsIdenticalTo", "IsReviewedBy", "Reviews", "IsDerivedFrom", "IsSourceOf", "IsRequiredBy", "Requires", "IsObsoletedBy", "Obsoletes", "IsCollectedBy", "Collects"}
+	dataciteTitleTypes       = []string{"AlternativeTitle", "Subtitle", "TranslatedTitle", "Other"}
+	dataciteDescriptionTypes = []string{"Abstract", "Methods", "SeriesInformation", "TableOfContents", "TechnicalInfo", "Other"}
+	dataciteFunderTypes      = []string{"ISNI", "GRID", "ROR", "Crossref Funder ID", "Other"}
+	dataciteResourceTypes    = []string{"Audiovisual", "Book", "BookChapter", "Collection", "ComputationalNotebook", "ConferencePaper", "ConferenceProceeding", "DataPaper", "Dataset", "Dissertation", "Event", "Image", "Instrument", "InteractiveResource", "Journal", "JournalArticle", "Model", "OutputManagementPlan", "PeerReview", "PhysicalObject", "Preprint", "Report", "Service", "Software", "Sound", "Standard", "StudyRegistration", "Text", "Workflow", "Other"}
+)
+
+// DataciteValidationError is returned if a work can't be serialized as valid
+// DataCite XML. Missing lists the mandatory properties not found.
+type DataciteValidationError struct {
+	Missing []string
+	Errors  []string
+}
+
+func (e *DataciteValidationError) Error() string {
+	if len(e.Missing) > 0 {
+		return fmt.Sprintf("missing mandatory DataCite properties: %s", strings.Join(e.Missing, ", "))
+	}
+	return fmt.Sprintf("invalid DataCite XML: %s", strings.Join(e.Errors, "; "))
+}
+
+type dataciteResource struct {
+	XMLName              xml.Name                       `xml:"http://datacite.org/schema/kernel-4 resource"`
+	XSI                  string                         `xml:"xmlns:xsi,attr"`
+	SchemaLocation       string                         `xml:"xsi:schemaLocation,attr"`
+	Identifier           *dataciteIdentifier            `xml:"identifier"`
+	Creators             *[]dataciteName                `xml:"creators>creator"`
+	Titles               *[]dataciteTitle               `xml:"titles>title"`
+	Publisher            *datacitePublisher             `xml:"publisher"`
+	PublicationYear      string                         `xml:"publicationYear,omitempty"`
+	ResourceType         dataciteResourceType           `xml:"resourceType"`
+	Subjects             *[]string                      `xml:"subjects>subject"`
+	Contributors         *[]dataciteName                `xml:"contributors>contributor"`
+	Dates                *[]dataciteDate                `xml:"dates>date"`
+	Language             string                         `xml:"language,omitempty"`
+	AlternateIdentifiers *[]dataciteAlternateIdentifier `xml:"alternateIdentifiers>alternateIdentifier"`
+	RelatedIdentifiers   *[]dataciteRelatedIdentifier   `xml:"relatedIdentifiers>relatedIdentifier"`
+	Formats              *[]string                      `xml:"formats>format"`
+	Version              string                         `xml:"version,omitempty"`
+	RightsList           *[]dataciteRights              `xml:"rightsList>rights"`
+	Descriptions         *[]dataciteDescription         `xml:"descriptions>description"`
+	GeoLocations         *[]dataciteGeoLocation         `xml:"geoLocations>geoLocation"`
+	FundingReferences    *[]dataciteFundingReference    `xml:"fundingReferences>fundingReference"`
+}
+
+type dataciteIdentifier struct {
+	IdentifierType string `xml:"identifierType,attr"`
+	Value          string `xml:",chardata"`
+}
+
+// dataciteName is a creator or contributor, with either a creatorName or
+// contributorName element
+type dataciteName struct {
+	ContributorType string                `xml:"contributorType,attr,omitempty"`
+	CreatorName     *dataciteNameValue    `xml:"creatorName"`
+	ContributorName *dataciteNameValue    `xml:"contributorName"`
+	GivenName       string                `xml:"givenName,omitempty"`
+	FamilyName      string                `xml:"familyName,omitempty"`
+	NameIdentifiers []dataciteNameID      `xml:"nameIdentifier"`
+	Affiliations    []dataciteAffiliation `xml:"affiliation"`
+}
+
+type dataciteNameValue struct {
+	NameType string `xml:"nameType,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type dataciteNameID struct {
+	Scheme    string `xml:"nameIdentifierScheme,attr"`
+	SchemeURI string `xml:"schemeURI,attr,omitempty"`
+	Value     string `xml:",chardata"`
+}
+
+type dataciteAffiliation struct {
+	Identifier       string `xml:"affiliationIdentifier,attr,omitempty"`
+	IdentifierScheme string `xml:"affiliationIdentifierScheme,attr,omitempty"`
+	SchemeURI        string `xml:"schemeURI,attr,omitempty"`
+	Value            string `xml:",chardata"`
+}
+
+type dataciteTitle struct {
+	TitleType string `xml:"titleType,attr,omitempty"`
+	Lang      string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Value     string `xml:",chardata"`
+}
+
+type datacitePublisher struct {
+	Identifier       string `xml:"publisherIdentifier,attr,omitempty"`
+	IdentifierScheme string `xml:"publisherIdentifierScheme,attr,omitempty"`
+	SchemeURI        string `xml:"schemeURI,attr,omitempty"`
+	Value            string `xml:",chardata"`
+}
+
+type dataciteResourceType struct {
+	ResourceTypeGeneral string `xml:"resourceTypeGeneral,attr"`
+	Value               string `xml:",chardata"`
+}
+
+type dataciteDate struct {
+	DateType string `xml:"dateType,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type dataciteAlternateIdentifier struct {
+	Type  string `xml:"alternateIdentifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dataciteRelatedIdentifier struct {
+	Type         string `xml:"relatedIdentifierType,attr"`
+	RelationType string `xml:"relationType,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type dataciteRights struct {
+	URI              string `xml:"rightsURI,attr,omitempty"`
+	Identifier       string `xml:"rightsIdentifier,attr,omitempty"`
+	IdentifierScheme string `xml:"rightsIdentifierScheme,attr,omitempty"`
+	SchemeURI        string `xml:"schemeURI,attr,omitempty"`
+	Value            string `xml:",chardata"`
+}
+
+type dataciteDescription struct {
+	DescriptionType string `xml:"descriptionType,attr"`
+	Lang            string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Value           string `xml:",chardata"`
+}
+
+type dataciteGeoLocation struct {
+	Place string            `xml:"geoLocationPlace,omitempty"`
+	Point *dataciteGeoPoint `xml:"geoLocationPoint"`
+	Box   *dataciteGeoBox   `xml:"geoLocationBox"`
+}
+
+type dataciteGeoPoint struct {
+	Longitude float64 `xml:"pointLongitude"`
+	Latitude  float64 `xml:"pointLatitude"`
+}
+
+type dataciteGeoBox struct {
+	WestBoundLongitude float64 `xml:"westBoundLongitude"`
+	EastBoundLongitude float64 `xml:"eastBoundLongitude"`
+	SouthBoundLatitude float64 `xml:"southBoundLatitude"`
+	NorthBoundLatitude float64 `xml:"northBoundLatitude"`
+}
+
+type dataciteFundingReference struct {
+	FunderName       string               `xml:"funderName"`
+	FunderIdentifier *dataciteFunderID    `xml:"funderIdentifier"`
+	AwardNumber      *dataciteAwardNumber `xml:"awardNumber"`
+}
+
+type dataciteFunderID struct {
+	Type  string `xml:"funderIdentifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dataciteAwardNumber struct {
+	AwardURI string `xml:"awardURI,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+// WriteDataciteXML converts commonmeta metadata to DataCite Metadata Schema
+// 4.5 XML, validated against the bundled XSD. Works with a pid that is not a
+// DOI use the pid as URL identifier, which only the bundled schema accepts.
+// Returns a DataciteValidationError if the work doesn't meet the requirements
+// of the schema.
+func WriteDataciteXML(data commonmeta.Data) ([]byte, error) {
+	r := dataciteResource{
+		XSI:            xsiNS,
+		SchemaLocation: DataciteSchemaLocation,
+	}
+	var (
+		creators             []dataciteName
+		titles               []dataciteTitle
+		contributors         []dataciteName
+		dates                []dataciteDate
+		alternateIdentifiers []dataciteAlternateIdentifier
+		relatedIdentifiers   []dataciteRelatedIdentifier
+		formats              []string
+		rightsList           []dataciteRights
+		descriptions         []dataciteDescription
+		geoLocations         []dataciteGeoLocation
+		fundingReferences    []dataciteFundingReference
+	)
+	if doi := doiFromPid(data.ID); doi != "" {
+		r.Identifier = &dataciteIdentifier{IdentifierType: "DOI", Value: doi}
+	} else if data.ID != "" {
+		r.Identifier = &dataciteIdentifier{IdentifierType: "URL", Value: data.ID}
+	}
+
+	for _, c := range data.Contributors {
+		n, ok := dataciteContributorName(c)
+		if !ok {
+			continue
+		}
+		if slices.Contains(c.ContributorRoles, "Author") {
+			creators = append(creators, n)
+		}
+		for _, role := range c.ContributorRoles {
+			if role == "Author" {
+				continue
+			}
+			if !slices.Contains(dataciteContributorTypes, role) {
+				role = "Other"
+			}
+			contributor := n
+			contributor.ContributorType = role
+			contributor.ContributorName = n.CreatorName
+			contributor.CreatorName = nil
+			contributors = append(contributors, contributor)
+		}
+	}
+
+	for _, t := range data.Titles {
+		title := stripTags(t.Title)
+		if title == "" {
+			continue
+		}
+		titleType := t.Type
+		if titleType != "" && !slices.Contains(dataciteTitleTypes, titleType) {
+			titleType = "Other"
+		}
+		titles = append(titles, dataciteTitle{TitleType: titleType, Lang: t.Language, Value: title})
+	}
+	if data.Publisher.Name != "" {
+		r.Publisher = &datacitePublisher{Value: data.Publisher.Name}
+		if strings.HasPrefix(data.Publisher.ID, "https://ror.org/") {
+			r.Publisher.Identifier = data.Publisher.ID
+			r.Publisher.IdentifierScheme = "ROR"
+			r.Publisher.SchemeURI = "https://ror.org/"
+		}
+	}
+	r.PublicationYear = publicationYear(data)
+	// commonmeta types without mapping, such as Dissertation, are often
+	// DataCite resource types already
+	r.ResourceType.ResourceTypeGeneral = datacite.CMToDCMappings[data.Type]
+	if r.ResourceType.ResourceTypeGeneral == "" && slices.Contains(dataciteResourceTypes, data.Type) {
+		r.ResourceType.ResourceTypeGeneral = data.Type
+	} else if r.ResourceType.ResourceTypeGeneral == "" {
+		r.ResourceType.ResourceTypeGeneral = "Other"
+	}
+	r.ResourceType.Value = data.AdditionalType
+	if r.ResourceType.Value == "" {
+		r.ResourceType.Value = data.Type
+	}
+
+	subjects := keywords(data)
+	for _, d := range []struct{ Type, Value string }{
+		{"Issued", data.Date.Published},
+		{"Created", data.Date.Created},
+		{"Submitted", data.Date.Submitted},
+		{"Accepted", data.Date.Accepted},
+		{"Available", data.Date.Available},
+		{"Updated", data.Date.Updated},
+		{"Copyrighted", data.Date.Copyrighted},
+		{"Collected", data.Date.Collected},
+		{"Valid", data.Date.Valid},
+		{"Withdrawn", data.Date.Withdrawn},
+		{"Other", data.Date.Other},
+	} {
+		if d.Value != "" {
+			dates = append(dates, dataciteDate{DateType: d.Type, Value: d.Value})
+		}
+	}
+	r.Language = data.Language
+
+	for _, i := range data.Identifiers {
+		if i.Identifier != "" && i.Identifier != data.ID && i.IdentifierType != "" {
+			alternateIdentifiers = append(alternateIdentifiers, dataciteAlternateIdentifier{Type: i.IdentifierType, Value: i.Identifier})
+		}
+	}
+	if data.Container.IdentifierType == "ISSN" && data.Container.Identifier != "" {
+		relatedIdentifiers = append(relatedIdentifiers, dataciteRelatedIdentifier{Type: "ISSN", RelationType: "IsPublishedIn", Value: data.Container.Identifier})
+	}
+	for _, v := range data.Relations {
+		if slices.Contains(dataciteRelationTypes, v.Type) {
+			if related, ok := dataciteRelatedID(v.ID, v.Type); ok {
+				relatedIdentifiers = append(relatedIdentifiers, related)
+			}
+		}
+	}
+	for _, v := range data.References {
+		if related, ok := dataciteRelatedID(v.ID, "References"); ok {
+			relatedIdentifiers = append(relatedIdentifiers, related)
+		}
+	}
+
+	for _, f := range data.Files {
+		if f.MimeType != "" && !slices.Contains(formats, f.MimeType) {
+			formats = append(formats, f.MimeType)
+		}
+	}
+	r.Version = data.Version
+	if data.License.URL != "" || data.License.ID != "" {
+		rights := dataciteRights{URI: data.License.URL, Value: data.License.ID}
+		if data.License.ID != "" {
+			rights.Identifier = strings.ToLower(data.License.ID)
+			rights.IdentifierScheme = "SPDX"
+			rights.SchemeURI = "https://spdx.org/licenses/"
+		}
+		rightsList = append(rightsList, rights)
+	}
+	for _, d := range data.Descriptions {
+		description := stripTags(d.Description)
+		if description == "" {
+			continue
+		}
+		descriptionType := d.Type
+		if descriptionType == "" {
+			descriptionType = "Abstract"
+		} else if !slices.Contains(dataciteDescriptionTypes, descriptionType) {
+			descriptionType = "Other"
+		}
+		descriptions = append(descriptions, dataciteDescription{DescriptionType: descriptionType, Lang: d.Language, Value: description})
+	}
+	for _, g := range data.GeoLocations {
+		geoLocation := dataciteGeoLocation{Place: g.GeoLocationPlace}
+		if g.GeoLocationPoint != (commonmeta.GeoLocationPoint{}) {
+			geoLocation.Point = &dataciteGeoPoint{Longitude: g.GeoLocationPoint.PointLongitude, Latitude: g.GeoLocationPoint.PointLatitude}
+		}
+		if g.GeoLocationBox != (commonmeta.GeoLocationBox{}) {
+			box := g.GeoLocationBox
+			geoLocation.Box = &dataciteGeoBox{
+				WestBoundLongitude: box.WestBoundLongitude,
+				EastBoundLongitude: box.EastBoundLongitude,
+				SouthBoundLatitude: box.SouthBoundLatitude,
+				NorthBoundLatitude: box.NorthBoundLatitude,
+			}
+		}
+		if geoLocation.Place != "" || geoLocation.Point != nil || geoLocation.Box != nil {
+			geoLocations = append(geoLocations, geoLocation)
+		}
+	}
+	for _, f := range data.FundingReferences {
+		if f.FunderName == "" {
+			continue
+		}
+		funding := dataciteFundingReference{FunderName: f.FunderName}
+		if f.FunderIdentifier != "" {
+			funding.FunderIdentifier = &dataciteFunderID{Type: dataciteFunderIdentifierType(f), Value: f.FunderIdentifier}
+		}
+		if f.AwardNumber != "" || f.AwardURI != "" {
+			funding.AwardNumber = &dataciteAwardNumber{AwardURI: f.AwardURI, Value: f.AwardNumber}
+		}
+		fundingReferences = append(fundingReferences, funding)
+	}
+
+	r.Creators = list(creators)
+	r.Titles = list(titles)
+	r.Subjects = list(subjects)
+	r.Contributors = list(contributors)
+	r.Dates = list(dates)
+	r.AlternateIdentifiers = list(alternateIdentifiers)
+	r.RelatedIdentifiers = list(relatedIdentifiers)
+	r.Formats = list(formats)
+	r.RightsList = list(rightsList)
+	r.Descriptions = list(descriptions)
+	r.GeoLocations = list(geoLocations)
+	r.FundingReferences = list(fundingReferences)
+
+	out, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	out = append([]byte(xml.Header), out...)
+
+	schema, err := dataciteXSD()
+	if err != nil {
+		return nil, err
+	}
+	errs, err := schema.Validate(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		verr := &DataciteValidationError{Missing: []string{}, Errors: []string{}}
+		for _, e := range errs {
+			if e.Missing {
+				verr.Missing = append(verr.Missing, strings.TrimPrefix(e.Path, "/resource/"))
+			}
+			verr.Errors = append(verr.Errors, e.Error())
+		}
+		return nil, verr
+	}
+	return out, nil
+}
+
+// list returns a pointer to a slice, or nil if the slice is empty, so that the
+// wrapper element of an empty list is omitted
+func list[T any](s []T) *[]T {
+	if len(s) == 0 {
+		return nil
+	}
+	return &s
+}
+
+// dataciteContributorName maps a commonmeta contributor to a DataCite creator,
+// returns false if the contributor has no name
+func dataciteContributorName(c commonmeta.Contributor) (dataciteName, bool) {
+	var n dataciteName
+	if c.Type == "Organization" {
+		if c.Name == "" {
+			return n, false
+		}
+		n.CreatorName = &dataciteNameValue{NameType: "Organizational", Value: c.Name}
+	} else {
+		name := c.Name
+		if c.FamilyName != "" {
+			name = c.FamilyName
+			if c.GivenName != "" {
+				name += ", " + c.GivenName
+			}
+		}
+		if name == "" {
+			return n, false
+		}
+		n.CreatorName = &dataciteNameValue{NameType: "Personal", Value: name}
+		n.GivenName = c.GivenName
+		n.FamilyName = c.FamilyName
+	}
+	if strings.HasPrefix(c.ID, "https://orcid.org/") {
+		n.NameIdentifiers = append(n.NameIdentifiers, dataciteNameID{Scheme: "ORCID", SchemeURI: "https://orcid.org", Value: c.ID})
+	} else if strings.HasPrefix(c.ID, "https://ror.org/") {
+		n.NameIdentifiers = append(n.NameIdentifiers, dataciteNameID{Scheme: "ROR", SchemeURI: "https://ror.org", Value: c.ID})
+	}
+	for _, a := range c.Affiliations {
+		if a == nil || a.Name == "" {
+			continue
+		}
+		affiliation := dataciteAffiliation{Value: a.Name}
+		if strings.HasPrefix(a.ID, "https://ror.org/") {
+			affiliation.Identifier = a.ID
+			affiliation.IdentifierScheme = "ROR"
+			affiliation.SchemeURI = "https://ror.org"
+		}
+		n.Affiliations = append(n.Affiliations, affiliation)
+	}
+	return n, true
+}
+
+// dataciteRelatedID returns a related identifier for a DOI or URL
+func dataciteRelatedID(id string, relationType string) (dataciteRelatedIdentifier, bool) {
+	if doi := doiFromPid(id); doi != "" {
+		return dataciteRelatedIdentifier{Type: "DOI", RelationType: relationType, Value: doi}, true
+	}
+	if isIRI(id) {
+		return dataciteRelatedIdentifier{Type: "URL", RelationType: relationType, Value: id}, true
+	}
+	return dataciteRelatedIdentifier{}, false
+}
+
+// dataciteFunderIdentifierType guesses the funder identifier type if it is
+// not one of the DataCite controlled list values
+func dataciteFunderIdentifierType(f commonmeta.FundingReference) string {
+	switch {
+	case slices.Contains(dataciteFunderTypes, f.FunderIdentifierType):
+		return f.FunderIdentifierType
+	case strings.Contains(f.FunderIdentifier, "10.13039/"):
+		return "Crossref Funder ID"
+	case strings.HasPrefix(f.FunderIdentifier, "https://ror.org/"):
+		return "ROR"
+	case strings.Contains(f.FunderIdentifier, "isni.org"):
+		return "ISNI"
+	default:
+		return "Other"
+	}
+}
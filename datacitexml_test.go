@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/datacite"
+)
+
+func TestWriteDataciteXML(t *testing.T) {
+	t.Parallel()
+
+	data := commonmeta.Data{
+		ID:   "https://doi.org/10.7554/elife.01567",
+		Type: "JournalArticle",
+		Contributors: []commonmeta.Contributor{
+			{ID: "https://orcid.org/0000-0002-8419-5237", Type: "Person", GivenName: "Martial", FamilyName: "Sankar", ContributorRoles: []string{"Author"}, Affiliations: []*commonmeta.Affiliation{{ID: "https://ror.org/019whta54", Name: "University of Lausanne"}}},
+			{Type: "Person", GivenName: "Kaisa", FamilyName: "Nieminen", ContributorRoles: []string{"Editor"}},
+		},
+		Titles:            []commonmeta.Title{{Title: "Automated quantitative histology reveals vascular morphodynamics during <i>Arabidopsis</i> hypocotyl secondary growth", Language: "en"}},
+		Container:         commonmeta.Container{Title: "eLife", Identifier: "2050-084X", IdentifierType: "ISSN"},
+		Publisher:         commonmeta.Publisher{Name: "eLife Sciences Publications, Ltd"},
+		Date:              commonmeta.Date{Published: "2014-02-11"},
+		Descriptions:      []commonmeta.Description{{Description: "Among the most striking aspects", Type: "Abstract"}},
+		References:        []commonmeta.Reference{{Key: "ref1", ID: "https://doi.org/10.1038/nature02100"}},
+		FundingReferences: []commonmeta.FundingReference{{FunderIdentifier: "https://doi.org/10.13039/501100001711", FunderName: "Swiss National Science Foundation", AwardNumber: "CRSII3_136278"}},
+		License:           commonmeta.License{ID: "CC-BY-3.0", URL: "https://creativecommons.org/licenses/by/3.0/legalcode"},
+		Language:          "en",
+	}
+	out, err := WriteDataciteXML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	want := []string{
+		`<resource xmlns="http://datacite.org/schema/kernel-4" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://datacite.org/schema/kernel-4 https://schema.datacite.org/meta/kernel-4.5/metadata.xsd">`,
+		`<identifier identifierType="DOI">10.7554/elife.01567</identifier>`,
+		`<creatorName nameType="Personal">Sankar, Martial</creatorName>`,
+		`<nameIdentifier nameIdentifierScheme="ORCID" schemeURI="https://orcid.org">https://orcid.org/0000-0002-8419-5237</nameIdentifier>`,
+		`<affiliation affiliationIdentifier="https://ror.org/019whta54" affiliationIdentifierScheme="ROR" schemeURI="https://ror.org">University of Lausanne</affiliation>`,
+		`<title xml:lang="en">Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth</title>`,
+		`<publicationYear>2014</publicationYear>`,
+		`<resourceType resourceTypeGeneral="JournalArticle">JournalArticle</resourceType>`,
+		`<contributor contributorType="Editor">`,
+		`<contributorName nameType="Personal">Nieminen, Kaisa</contributorName>`,
+		`<date dateType="Issued">2014-02-11</date>`,
+		`<relatedIdentifier relatedIdentifierType="ISSN" relationType="IsPublishedIn">2050-084X</relatedIdentifier>`,
+		`<relatedIdentifier relatedIdentifierType="DOI" relationType="References">10.1038/nature02100</relatedIdentifier>`,
+		`<funderIdentifier funderIdentifierType="Crossref Funder ID">https://doi.org/10.13039/501100001711</funderIdentifier>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Write DataCite XML: want %v, got\n%v", w, got)
+		}
+	}
+}
+
+func TestWriteDataciteXMLNonDOI(t *testing.T) {
+	t.Parallel()
+
+	data := commonmeta.Data{
+		ID:           "https://blog.front-matter.io/posts/commonmeta",
+		Type:         "Article",
+		Contributors: []commonmeta.Contributor{{Type: "Organization", Name: "Front Matter", ContributorRoles: []string{"Author"}}},
+		Titles:       []commonmeta.Title{{Title: "Commonmeta"}},
+		Publisher:    commonmeta.Publisher{Name: "Front Matter"},
+		Date:         commonmeta.Date{Published: "2023-12-01"},
+	}
+	out, err := WriteDataciteXML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<identifier identifierType="URL">https://blog.front-matter.io/posts/commonmeta</identifier>`
+	if !strings.Contains(string(out), want) {
+		t.Errorf("Write DataCite XML: want %v, got\n%v", want, string(out))
+	}
+}
+
+func TestWriteDataciteXMLMissing(t *testing.T) {
+	t.Parallel()
+
+	data := commonmeta.Data{
+		ID:     "https://doi.org/10.5555/12345678",
+		Type:   "JournalArticle",
+		Titles: []commonmeta.Title{{Title: "Toward a Unified Theory of High-Energy Metaphysics"}},
+	}
+	_, err := WriteDataciteXML(data)
+	var verr *DataciteValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Write DataCite XML: want validation error, got %v", err)
+	}
+	want := []string{"creators", "publisher", "publicationYear"}
+	if !slices.Equal(want, verr.Missing) {
+		t.Errorf("Write DataCite XML: want missing %v, got %v", want, verr.Missing)
+	}
+}
+
+func TestWriteDataciteXMLExamples(t *testing.T) {
+	t.Parallel()
+
+	// official kernel-4 example documents of the DataCite Metadata Schema,
+	// read into commonmeta and written again
+	type testCase struct {
+		name                string
+		resourceTypeGeneral string
+	}
+	testCases := []testCase{
+		{name: "datacite-example-full-v4.4.xml", resourceTypeGeneral: "Software"},
+		{name: "datacite-example-dissertation-v4.4.xml", resourceTypeGeneral: "Dissertation"},
+		{name: "datacite-example-ancientdates-v4.3.xml", resourceTypeGeneral: "PhysicalObject"},
+		{name: "datacite-example-affiliation.xml", resourceTypeGeneral: "Software"},
+		{name: "datacite-example-complicated-v4.1.xml", resourceTypeGeneral: "Text"},
+		{name: "datacite-example-geolocation.xml", resourceTypeGeneral: "Dataset"},
+	}
+	for _, tc := range testCases {
+		doc, err := os.ReadFile(filepath.Join("testdata", "datacite", tc.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var example xmlNode
+		if err := xml.Unmarshal(doc, &example); err != nil {
+			t.Fatal(err)
+		}
+		data, err := readDataciteExample(&example)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := WriteDataciteXML(data)
+		if err != nil {
+			t.Errorf("Write DataCite XML(%v): want valid XML, got %v", tc.name, err)
+			continue
+		}
+		var got xmlNode
+		if err := xml.Unmarshal(out, &got); err != nil {
+			t.Fatal(err)
+		}
+		// DOIs are case-insensitive
+		for _, name := range []string{"identifier", "publisher", "publicationYear"} {
+			if want := strings.TrimSpace(example.child(name).Text); got.child(name) == nil || !strings.EqualFold(got.child(name).Text, want) {
+				t.Errorf("Write DataCite XML(%v): want %v %v, got %v", tc.name, name, want, got.child(name))
+			}
+		}
+		if rtg := got.child("resourceType").attr("resourceTypeGeneral"); rtg != tc.resourceTypeGeneral {
+			t.Errorf("Write DataCite XML(%v): want resourceTypeGeneral %v, got %v", tc.name, tc.resourceTypeGeneral, rtg)
+		}
+		for _, list := range []string{"creators", "titles"} {
+			if want, n := len(example.child(list).Nodes), len(got.child(list).Nodes); want != n {
+				t.Errorf("Write DataCite XML(%v): want %v %v, got %v", tc.name, want, list, n)
+			}
+		}
+	}
+}
+
+// readDataciteExample reads a DataCite XML document with the DataCite JSON
+// reader, mapping the properties used in the example documents
+func readDataciteExample(n *xmlNode) (commonmeta.Data, error) {
+	text := func(n *xmlNode, name string) string {
+		if c := n.child(name); c != nil {
+			return strings.TrimSpace(c.Text)
+		}
+		return ""
+	}
+	items := func(list string, item func(c *xmlNode) map[string]any) []map[string]any {
+		result := make([]map[string]any, 0)
+		if l := n.child(list); l != nil {
+			for _, c := range l.Nodes {
+				result = append(result, item(c))
+			}
+		}
+		return result
+	}
+	name := func(c *xmlNode) map[string]any {
+		nameNode := c.child("creatorName")
+		if nameNode == nil {
+			nameNode = c.child("contributorName")
+		}
+		affiliations := make([]string, 0)
+		nameIdentifiers := make([]map[string]any, 0)
+		for _, a := range c.Nodes {
+			switch a.XMLName.Local {
+			case "affiliation":
+				affiliations = append(affiliations, strings.TrimSpace(a.Text))
+			case "nameIdentifier":
+				nameIdentifiers = append(nameIdentifiers, map[string]any{"nameIdentifier": strings.TrimSpace(a.Text), "nameIdentifierScheme": a.attr("nameIdentifierScheme"), "schemeUri": a.attr("schemeURI")})
+			}
+		}
+		return map[string]any{
+			"name":            strings.TrimSpace(nameNode.Text),
+			"nameType":        nameNode.attr("nameType"),
+			"givenName":       text(c, "givenName"),
+			"familyName":      text(c, "familyName"),
+			"affiliation":     affiliations,
+			"nameIdentifiers": nameIdentifiers,
+			"contributorType": c.attr("contributorType"),
+		}
+	}
+	// the reader takes the raw JSON of the publication year as date
+	year := json.RawMessage(text(n, "publicationYear"))
+	content := map[string]any{
+		"doi":             text(n, "identifier"),
+		"creators":        items("creators", name),
+		"contributors":    items("contributors", name),
+		"publisher":       text(n, "publisher"),
+		"publicationYear": year,
+		"language":        text(n, "language"),
+		"version":         text(n, "version"),
+		"types":           map[string]any{"resourceTypeGeneral": n.child("resourceType").attr("resourceTypeGeneral"), "resourceType": text(n, "resourceType")},
+		"titles": items("titles", func(c *xmlNode) map[string]any {
+			return map[string]any{"title": strings.TrimSpace(c.Text), "titleType": c.attr("titleType"), "lang": c.attr("lang")}
+		}),
+		"subjects": items("subjects", func(c *xmlNode) map[string]any {
+			return map[string]any{"subject": strings.TrimSpace(c.Text)}
+		}),
+		"dates": items("dates", func(c *xmlNode) map[string]any {
+			return map[string]any{"date": strings.TrimSpace(c.Text), "dateType": c.attr("dateType")}
+		}),
+		"alternateIdentifiers": items("alternateIdentifiers", func(c *xmlNode) map[string]any {
+			return map[string]any{"alternateIdentifier": strings.TrimSpace(c.Text), "alternateIdentifierType": c.attr("alternateIdentifierType")}
+		}),
+		"relatedIdentifiers": items("relatedIdentifiers", func(c *xmlNode) map[string]any {
+			return map[string]any{"relatedIdentifier": strings.TrimSpace(c.Text), "relatedIdentifierType": c.attr("relatedIdentifierType"), "relationType": c.attr("relationType")}
+		}),
+		"rightsList": items("rightsList", func(c *xmlNode) map[string]any {
+			return map[string]any{"rights": strings.TrimSpace(c.Text), "rightsUri": c.attr("rightsURI")}
+		}),
+		"descriptions": items("descriptions", func(c *xmlNode) map[string]any {
+			return map[string]any{"description": strings.TrimSpace(c.Text), "descriptionType": c.attr("descriptionType"), "lang": c.attr("lang")}
+		}),
+		"fundingReferences": items("fundingReferences", func(c *xmlNode) map[string]any {
+			funderIdentifier := c.child("funderIdentifier")
+			if funderIdentifier == nil {
+				funderIdentifier = &xmlNode{}
+			}
+			return map[string]any{"funderName": text(c, "funderName"), "funderIdentifier": strings.TrimSpace(funderIdentifier.Text), "funderIdentifierType": funderIdentifier.attr("funderIdentifierType"), "awardNumber": text(c, "awardNumber")}
+		}),
+		"geoLocations": items("geoLocations", func(c *xmlNode) map[string]any {
+			geoLocation := map[string]any{"geoLocationPlace": text(c, "geoLocationPlace")}
+			if p := c.child("geoLocationPoint"); p != nil {
+				geoLocation["geoLocationPoint"] = map[string]any{"pointLongitude": text(p, "pointLongitude"), "pointLatitude": text(p, "pointLatitude")}
+			}
+			if b := c.child("geoLocationBox"); b != nil {
+				geoLocation["geoLocationBox"] = map[string]any{"westBoundLongitude": text(b, "westBoundLongitude"), "eastBoundLongitude": text(b, "eastBoundLongitude"), "southBoundLatitude": text(b, "southBoundLatitude"), "northBoundLatitude": text(b, "northBoundLatitude")}
+			}
+			return geoLocation
+		}),
+	}
+	b, err := json.Marshal(content)
+	if err != nil {
+		return commonmeta.Data{}, err
+	}
+	var c datacite.Content
+	if err := json.Unmarshal(b, &c); err != nil {
+		return commonmeta.Data{}, err
+	}
+	return datacite.Read(c)
+}
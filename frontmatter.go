@@ -0,0 +1,403 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is the YAML front matter of a Quarto or Hugo page, with the
+// fields of both
+type frontMatter struct {
+	Title        string              `yaml:"title"`
+	Subtitle     string              `yaml:"subtitle"`
+	Author       any                 `yaml:"author"`
+	Authors      any                 `yaml:"authors"`
+	Date         string              `yaml:"date"`
+	DateModified string              `yaml:"date-modified"`
+	Lastmod      string              `yaml:"lastmod"`
+	DOI          string              `yaml:"doi"`
+	URL          string              `yaml:"url"`
+	License      any                 `yaml:"license"`
+	Abstract     string              `yaml:"abstract"`
+	Description  string              `yaml:"description"`
+	Summary      string              `yaml:"summary"`
+	Keywords     stringList          `yaml:"keywords"`
+	Categories   stringList          `yaml:"categories"`
+	Tags         stringList          `yaml:"tags"`
+	Lang         string              `yaml:"lang"`
+	Citation     frontMatterCitation `yaml:"citation"`
+	Bibliography stringList          `yaml:"bibliography"`
+	References   []map[string]any    `yaml:"references"`
+	Nocite       string              `yaml:"nocite"`
+	Draft        bool                `yaml:"draft"`
+}
+
+// frontMatterCitation is the citation metadata of a Quarto page, which
+// uses the names of CSL variables
+type frontMatterCitation struct {
+	Type           string `yaml:"type"`
+	DOI            string `yaml:"doi"`
+	URL            string `yaml:"url"`
+	ContainerTitle string `yaml:"container-title"`
+	Publisher      string `yaml:"publisher"`
+	Volume         string `yaml:"volume"`
+	Issue          string `yaml:"issue"`
+	Page           string `yaml:"page"`
+}
+
+// UnmarshalYAML ignores citation: true, which asks Quarto to derive the
+// citation metadata from the page
+func (c *frontMatterCitation) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return nil
+	}
+	type citation frontMatterCitation
+	return value.Decode((*citation)(c))
+}
+
+// stringList is a list of strings in front matter, also written as a single string
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = stringList{value.Value}
+		return nil
+	}
+	return value.Decode((*[]string)(l))
+}
+
+// pandocCitationRegexp matches Pandoc citations such as [@key], @key or
+// [-@key; @{other key}]
+var pandocCitationRegexp = regexp.MustCompile(`(?:^|[\s\[;-])@(?:\{([^}]+)\}|([\pL\pN_][\pL\pN_:.#$%&+?<>~/-]*))`)
+
+// ReadFrontMatter reads the YAML front matter of a Markdown or Quarto file.
+// The references are the works cited in the text, as far as they are listed
+// in the references field of the front matter.
+func ReadFrontMatter(b []byte) (commonmeta.Data, error) {
+	fm, _, body, err := parseFrontMatter(b)
+	if err != nil {
+		return commonmeta.Data{}, err
+	}
+	data, err := readFrontMatter(fm)
+	if err != nil {
+		return data, err
+	}
+	bibliography, err := inlineReferences(fm)
+	if err != nil {
+		return data, err
+	}
+	data.References = citedReferences(fm, body, bibliography)
+	return data, nil
+}
+
+// ReadFrontMatterFile reads the YAML front matter of a Markdown or Quarto
+// file, with the references cited from the bibliography files next to it.
+// Pages of a Quarto website without DOI or url get their pid from the
+// site-url of the project.
+func ReadFrontMatterFile(filename string) ([]ReadResult, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fm, raw, body, err := parseFrontMatter(b)
+	if err != nil {
+		return []ReadResult{{Raw: b, Err: err}}, nil
+	}
+	data, err := readFrontMatter(fm)
+	if err != nil {
+		return []ReadResult{{Raw: raw, Err: err}}, nil
+	}
+	if data.ID == "" {
+		data.URL = quartoPageURL(filename)
+		data.ID = pidFromURL(data.URL)
+	}
+	bibliography, err := inlineReferences(fm)
+	if err != nil {
+		return []ReadResult{{Raw: raw, Err: err}}, nil
+	}
+	for _, name := range fm.Bibliography {
+		works, err := readBibliography(filepath.Join(filepath.Dir(filename), name))
+		if err != nil {
+			return []ReadResult{{Raw: raw, Err: fmt.Errorf("bibliography %s: %w", name, err)}}, nil
+		}
+		bibliography = append(bibliography, works...)
+	}
+	data.References = citedReferences(fm, body, bibliography)
+	return []ReadResult{{Raw: raw, Data: data}}, nil
+}
+
+// parseFrontMatter splits a Markdown file into the YAML front matter between
+// the --- lines at its start and the text
+func parseFrontMatter(b []byte) (frontMatter, []byte, string, error) {
+	var fm frontMatter
+	s := strings.ReplaceAll(strings.TrimPrefix(string(b), "\ufeff"), "\r\n", "\n")
+	rest, ok := strings.CutPrefix(s, "---\n")
+	if !ok {
+		return fm, nil, "", errors.New("missing YAML front matter")
+	}
+	offset := 0
+	for _, line := range strings.SplitAfter(rest, "\n") {
+		if end := strings.TrimRight(line, " \n"); end == "---" || end == "..." {
+			raw := []byte(rest[:offset])
+			if err := yaml.Unmarshal(raw, &fm); err != nil {
+				return fm, raw, "", fmt.Errorf("YAML front matter: %w", err)
+			}
+			return fm, raw, rest[offset+len(line):], nil
+		}
+		offset += len(line)
+	}
+	return fm, nil, "", errors.New("unterminated YAML front matter")
+}
+
+// readFrontMatter converts front matter to commonmeta. Pages are articles
+// unless the Quarto citation metadata has a CSL type, draft pages are
+// rejected.
+func readFrontMatter(fm frontMatter) (commonmeta.Data, error) {
+	if fm.Draft {
+		return commonmeta.Data{}, errors.New("draft page")
+	}
+	data := commonmeta.Data{
+		ID:   workPid(cmp.Or(fm.DOI, fm.Citation.DOI), cmp.Or(fm.URL, fm.Citation.URL)),
+		Type: CSLToCMMappings[fm.Citation.Type],
+		URL:  cmp.Or(fm.URL, fm.Citation.URL),
+	}
+	if data.Type == "" {
+		data.Type = "Article"
+	}
+	data.Contributors = append(frontMatterContributors(fm.Author), frontMatterContributors(fm.Authors)...)
+	if fm.Title != "" {
+		data.Titles = append(data.Titles, commonmeta.Title{Title: fm.Title})
+	}
+	if fm.Subtitle != "" {
+		data.Titles = append(data.Titles, commonmeta.Title{Title: fm.Subtitle, Type: "Subtitle"})
+	}
+	data.Container = commonmeta.Container{
+		Title:  fm.Citation.ContainerTitle,
+		Volume: fm.Citation.Volume,
+		Issue:  fm.Citation.Issue,
+	}
+	data.Container.FirstPage, data.Container.LastPage = pageRange(fm.Citation.Page)
+	if fm.Citation.Publisher != "" {
+		data.Publisher = commonmeta.Publisher{Name: fm.Citation.Publisher}
+	}
+	data.Date.Published = parseDate(fm.Date)
+	data.Date.Updated = parseDate(cmp.Or(fm.DateModified, fm.Lastmod))
+	if abstract := cmp.Or(fm.Abstract, fm.Description, fm.Summary); abstract != "" {
+		data.Descriptions = append(data.Descriptions, commonmeta.Description{Description: abstract, Type: "Abstract"})
+	}
+	keywords := slices.Concat(fm.Keywords, fm.Categories, fm.Tags)
+	for _, s := range subjectsFromKeywords(keywords...) {
+		if !slices.Contains(data.Subjects, s) {
+			data.Subjects = append(data.Subjects, s)
+		}
+	}
+	data.Language = fm.Lang
+	data.License = frontMatterLicense(fm.License)
+	return data, nil
+}
+
+// frontMatterContributors reads the authors of a page, given as names or as
+// objects with name, orcid and affiliations as in Quarto
+func frontMatterContributors(v any) []commonmeta.Contributor {
+	var contributors []commonmeta.Contributor
+	for _, a := range anyList(v) {
+		var c commonmeta.Contributor
+		switch a := a.(type) {
+		case string:
+			if strings.TrimSpace(a) == "" {
+				continue
+			}
+			c = contributorFromName(a, "Author")
+		case map[string]any:
+			var ok bool
+			if c, ok = frontMatterAuthor(a); !ok {
+				continue
+			}
+		default:
+			continue
+		}
+		contributors = append(contributors, c)
+	}
+	return contributors
+}
+
+// frontMatterAuthor reads an author object, with the name as a string or
+// with given and family name
+func frontMatterAuthor(a map[string]any) (commonmeta.Contributor, bool) {
+	var c commonmeta.Contributor
+	switch name := a["name"].(type) {
+	case string:
+		c = contributorFromName(name, "Author")
+	case map[string]any:
+		given, _ := name["given"].(string)
+		family, _ := name["family"].(string)
+		literal, _ := name["literal"].(string)
+		if family != "" {
+			c = commonmeta.Contributor{Type: "Person", GivenName: given, FamilyName: family, ContributorRoles: []string{"Author"}}
+		} else if literal != "" {
+			c = contributorFromName(literal, "Author")
+		}
+	}
+	if c.Type == "" {
+		return c, false
+	}
+	orcid, _ := a["orcid"].(string)
+	c.ID = utils.NormalizeORCID(orcid)
+	for _, af := range append(anyList(a["affiliation"]), anyList(a["affiliations"])...) {
+		switch af := af.(type) {
+		case string:
+			c.Affiliations = append(c.Affiliations, &commonmeta.Affiliation{Name: af})
+		case map[string]any:
+			name, _ := af["name"].(string)
+			ror, _ := af["ror"].(string)
+			if name != "" {
+				c.Affiliations = append(c.Affiliations, &commonmeta.Affiliation{ID: utils.NormalizeROR(ror), Name: name})
+			}
+		}
+	}
+	return c, true
+}
+
+// anyList returns a YAML value that is a single item or a list as a list
+func anyList(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+// frontMatterLicense reads the license of a page, a URL or a Creative
+// Commons license such as CC BY, as in Quarto
+func frontMatterLicense(v any) commonmeta.License {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case map[string]any:
+		s, _ = v["url"].(string)
+	}
+	if code, ok := strings.CutPrefix(strings.ToLower(s), "cc"); ok {
+		switch code = strings.Join(strings.Fields(code), "-"); code {
+		case "0":
+			s = "https://creativecommons.org/publicdomain/zero/1.0/legalcode"
+		case "by", "by-sa", "by-nd", "by-nc", "by-nc-sa", "by-nc-nd":
+			s = "https://creativecommons.org/licenses/" + code + "/4.0/legalcode"
+		}
+	}
+	return licenseFromURL(s)
+}
+
+// inlineReferences reads the references field of front matter, a list of
+// CSL items as in Pandoc
+func inlineReferences(fm frontMatter) ([]ReadResult, error) {
+	if len(fm.References) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(fm.References)
+	if err != nil {
+		return nil, err
+	}
+	return ReadCSLAll(b)
+}
+
+// readBibliography reads a bibliography file in BibTeX, RIS or CSL JSON
+func readBibliography(filename string) ([]ReadResult, error) {
+	name := "csl"
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != ".json" {
+		reader, ok := Readers.ByExtension(ext)
+		if !ok || reader.ReadAll == nil {
+			return nil, fmt.Errorf("unsupported bibliography format %s", ext)
+		}
+		name = reader.Name
+	}
+	reader, _ := Readers.ByName(name)
+	return reader.ParseFile(filename)
+}
+
+// citedReferences returns the works of the bibliography cited in the text or
+// in the nocite field, in the order of their first citation. Citations of
+// works that are not in the bibliography, e.g. Quarto cross references such
+// as @fig-1, are ignored.
+func citedReferences(fm frontMatter, body string, bibliography []ReadResult) []commonmeta.Reference {
+	works := make(map[string]commonmeta.Data)
+	for _, r := range bibliography {
+		if r.Err == nil && r.Key != "" {
+			works[r.Key] = r.Data
+		}
+	}
+	var keys []string
+	if strings.Contains(fm.Nocite, "@*") {
+		for _, r := range bibliography {
+			keys = append(keys, r.Key)
+		}
+	}
+	for _, m := range pandocCitationRegexp.FindAllStringSubmatch(fm.Nocite+"\n"+body, -1) {
+		keys = append(keys, cmp.Or(m[1], strings.TrimRight(m[2], ".:/-+?")))
+	}
+
+	var references []commonmeta.Reference
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		work, ok := works[key]
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		reference := commonmeta.Reference{Key: key, ID: work.ID, Type: work.Type, Title: stripTags(mainTitle(work))}
+		if len(work.Date.Published) >= 4 {
+			reference.PublicationYear = work.Date.Published[:4]
+		}
+		references = append(references, reference)
+	}
+	return references
+}
+
+// quartoPageURL returns the URL of a page of a Quarto website, from the
+// site-url in the _quarto.yml file of the project. Index pages are served
+// at the URL of their directory.
+func quartoPageURL(filename string) string {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return ""
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		for _, name := range []string{"_quarto.yml", "_quarto.yaml"} {
+			b, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			var project struct {
+				Website struct {
+					SiteURL string `yaml:"site-url"`
+				} `yaml:"website"`
+			}
+			if err := yaml.Unmarshal(b, &project); err != nil || project.Website.SiteURL == "" {
+				return ""
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return ""
+			}
+			page := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel)) + ".html"
+			return strings.TrimSuffix(project.Website.SiteURL, "/") + "/" + strings.TrimSuffix(page, "index.html")
+		}
+		if dir == filepath.Dir(dir) {
+			return ""
+		}
+	}
+}
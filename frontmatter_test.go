@@ -0,0 +1,201 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const quartoPost = `---
+title: "Metadata for blog posts"
+subtitle: A case study
+author:
+  - name: Martin Fenner
+    orcid: 0000-0003-1419-2405
+    affiliations:
+      - name: Front Matter
+        ror: https://ror.org/04wxnsj81
+  - name:
+      given: Jane
+      family: Doe
+  - Public Library of Science
+date: 2024-03-15
+date-modified: 2024-04-01
+license: CC BY
+keywords: [metadata, blogs]
+categories:
+  - blogs
+lang: en
+bibliography: references.bib
+references:
+  - id: inline2020
+    type: article-journal
+    title: An inline reference
+    DOI: 10.1234/inline
+    issued:
+      date-parts: [[2020]]
+nocite: |
+  @other2019
+---
+
+As shown by @sankar2014 and others [see @inline2020, p. 3; @sankar2014], see @fig-overview.
+Questions go to admin@example.org.
+`
+
+const quartoBibliography = `@article{sankar2014,
+  author = {Sankar, Martial},
+  title = {Automated quantitative histology},
+  journal = {eLife},
+  year = {2014},
+  doi = {10.7554/elife.01567}
+}
+@misc{other2019, title = {Another work}, url = {https://example.org/other}, year = 2019}
+@misc{uncited, title = {Not cited}}
+`
+
+func TestReadFrontMatterFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "posts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"_quarto.yml":          "project:\n  type: website\nwebsite:\n  site-url: https://blog.example.org/\n",
+		"posts/metadata.qmd":   quartoPost,
+		"posts/references.bib": quartoBibliography,
+		"index.qmd":            "---\ntitle: Home\ndoi: 10.5555/home\n---\n",
+		"draft.md":             "---\ntitle: Draft\ndraft: true\n---\n",
+		"README.md":            "# Readme\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := ReadFrontMatterFile(filepath.Join(dir, "posts/metadata.qmd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Read front matter: want a single work, got %v", results)
+	}
+	data := results[0].Data
+
+	// pages without DOI or url get their pid from the site-url
+	if data.ID != "https://blog.example.org/posts/metadata.html" || data.Type != "Article" {
+		t.Errorf("Read front matter: want pid from site-url, got %v %v", data.ID, data.Type)
+	}
+	if len(data.Titles) != 2 || data.Titles[1].Type != "Subtitle" {
+		t.Errorf("Read front matter: want title and subtitle, got %v", data.Titles)
+	}
+	if len(data.Contributors) != 3 {
+		t.Fatalf("Read front matter: want 3 authors, got %v", data.Contributors)
+	}
+	if c := data.Contributors[0]; c.ID != "https://orcid.org/0000-0003-1419-2405" || c.FamilyName != "Fenner" || len(c.Affiliations) != 1 || c.Affiliations[0].ID != "https://ror.org/04wxnsj81" {
+		t.Errorf("Read front matter: want author with ORCID and affiliation, got %v", c)
+	}
+	if c := data.Contributors[1]; c.GivenName != "Jane" || c.FamilyName != "Doe" {
+		t.Errorf("Read front matter: want author with given and family name, got %v", c)
+	}
+	if c := data.Contributors[2]; c.Type != "Organization" || c.Name != "Public Library of Science" {
+		t.Errorf("Read front matter: want organization, got %v", c)
+	}
+	if data.Date.Published != "2024-03-15" || data.Date.Updated != "2024-04-01" {
+		t.Errorf("Read front matter: want dates, got %v", data.Date)
+	}
+	if data.License.ID != "CC-BY-4.0" || len(data.Subjects) != 2 || data.Language != "en" {
+		t.Errorf("Read front matter: want license, subjects and language, got %v %v %v", data.License, data.Subjects, data.Language)
+	}
+
+	// references are the cited works of the bibliography, in citation order
+	want := []string{"other2019", "sankar2014", "inline2020"}
+	if len(data.References) != len(want) {
+		t.Fatalf("Read front matter: want references %v, got %v", want, data.References)
+	}
+	for i, key := range want {
+		if data.References[i].Key != key {
+			t.Errorf("Read front matter: want reference %v, got %v", key, data.References[i].Key)
+		}
+	}
+	if r := data.References[1]; r.ID != "https://doi.org/10.7554/elife.01567" || r.Title != "Automated quantitative histology" || r.PublicationYear != "2014" {
+		t.Errorf("Read front matter: want reference from bibliography, got %v", r)
+	}
+
+	type testCase struct {
+		name    string
+		want    string
+		wantErr bool
+	}
+	testCases := []testCase{
+		{name: "index.qmd", want: "https://doi.org/10.5555/home"},
+		{name: "draft.md", wantErr: true},
+		{name: "README.md", wantErr: true},
+	}
+	for _, tc := range testCases {
+		results, err := ReadFrontMatterFile(filepath.Join(dir, tc.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantErr != (results[0].Err != nil) {
+			t.Errorf("Read front matter(%v): want error %v, got %v", tc.name, tc.wantErr, results[0].Err)
+		}
+		if tc.want != results[0].Data.ID {
+			t.Errorf("Read front matter(%v): want %v, got %v", tc.name, tc.want, results[0].Data.ID)
+		}
+	}
+}
+
+func TestReadFrontMatter(t *testing.T) {
+	t.Parallel()
+
+	// without the file, only the references in the front matter are cited
+	data, err := ReadFrontMatter([]byte(quartoPost))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.ID != "" || mainTitle(data) != "Metadata for blog posts" {
+		t.Errorf("Read front matter: want title without pid, got %v %v", data.ID, mainTitle(data))
+	}
+	if len(data.References) != 1 || data.References[0].ID != "https://doi.org/10.1234/inline" {
+		t.Errorf("Read front matter: want inline reference, got %v", data.References)
+	}
+
+	input := "\ufeff---\r\ntitle: Hugo post\r\nauthors: [Jane Doe]\r\ndate: 2023-05-01T09:00:00-07:00\r\nurl: https://example.org/posts/hugo/\r\ncitation: true\r\ntags: hugo\r\n...\r\nText\r\n"
+	data, err = ReadFrontMatter([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.ID != "https://example.org/posts/hugo" || data.Date.Published != "2023-05-01" || len(data.Contributors) != 1 || len(data.Subjects) != 1 {
+		t.Errorf("Read front matter: want Hugo post, got %v %v %v %v", data.ID, data.Date.Published, data.Contributors, data.Subjects)
+	}
+
+	if _, err := ReadFrontMatter([]byte("---\ntitle: unterminated\n")); err == nil {
+		t.Errorf("Read front matter: want error for unterminated front matter, got nil")
+	}
+}
+
+func TestFrontMatterLicense(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input any
+		want  string
+	}
+	testCases := []testCase{
+		{input: "CC BY", want: "CC-BY-4.0"},
+		{input: "CC BY-NC-SA", want: "CC-BY-NC-SA-4.0"},
+		{input: "CC0", want: "CC0-1.0"},
+		{input: "https://creativecommons.org/licenses/by/4.0/", want: "CC-BY-4.0"},
+		{input: map[string]any{"text": "Some license", "url": "https://creativecommons.org/licenses/by-sa/4.0/legalcode"}, want: "CC-BY-SA-4.0"},
+		{input: "All rights reserved", want: ""},
+		{input: nil, want: ""},
+	}
+	for _, tc := range testCases {
+		got := frontMatterLicense(tc.input)
+		if tc.want != got.ID {
+			t.Errorf("Front matter license(%v): want %v, got %v", tc.input, tc.want, got.ID)
+		}
+	}
+}
@@ -0,0 +1,715 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/spf13/cobra"
+)
+
+// ImportStats counts the records of an import
+type ImportStats struct {
+	Read     int `json:"read"`
+	Created  int `json:"created"`
+	Updated  int `json:"updated"`
+	Rejected int `json:"rejected"`
+	// Warned counts the created or updated works saved with violations
+	// in the warn validation mode
+	Warned int `json:"warned"`
+}
+
+func (s ImportStats) String() string {
+	return fmt.Sprintf("%d records, %d created, %d updated, %d rejected, %d with violations", s.Read, s.Created, s.Updated, s.Rejected, s.Warned)
+}
+
+// RejectedRecord is written to the rejected records file, one per line
+type RejectedRecord struct {
+	// Source is the file and record number, e.g. works.jsonl:12
+	Source string            `json:"source"`
+	Error  string            `json:"error"`
+	Errors []ValidationError `json:"errors,omitempty"`
+	Record any               `json:"record"`
+}
+
+// importRecord is a record waiting for the next batch
+type importRecord struct {
+	source string
+	raw    []byte
+	data   commonmeta.Data
+}
+
+// Importer stores works in the works collection in batched transactions,
+// updating works with the same pid. Works are validated by the model hooks,
+// or by the Importer itself in a dry run.
+type Importer struct {
+	Dao       *daos.Dao
+	BatchSize int
+	// DryRun reads and validates records without storing them
+	DryRun bool
+	// Mode and Version of the validation in a dry run
+	Mode    ValidationMode
+	Version string
+	// Rejected receives the rejected records as JSON Lines, if not nil
+	Rejected io.Writer
+	// Progress receives a report after each batch, if not nil
+	Progress io.Writer
+	// Checkpoint records the imported files of a data dump, if not nil
+	Checkpoint *Checkpoint
+
+	Stats    ImportStats
+	batch    []importRecord
+	start    time.Time
+	finished []string
+	prefixes map[string]string
+}
+
+// Add queues a record read from source, storing the batch when it is full
+func (im *Importer) Add(source string, raw []byte, data commonmeta.Data) error {
+	if im.start.IsZero() {
+		im.start = time.Now()
+	}
+	im.Stats.Read++
+	if data.ID == "" {
+		return im.Reject(source, raw, errors.New("missing id"))
+	}
+	im.batch = append(im.batch, importRecord{source: source, raw: raw, data: data})
+	if len(im.batch) >= max(im.BatchSize, 1) {
+		return im.Flush()
+	}
+	return nil
+}
+
+// Reject writes a record that can't be imported to the rejected records
+func (im *Importer) Reject(source string, raw []byte, err error) error {
+	im.Stats.Rejected++
+	if im.Rejected == nil {
+		return nil
+	}
+	rejected := RejectedRecord{Source: source, Error: err.Error(), Record: string(raw)}
+	if json.Valid(raw) {
+		rejected.Record = json.RawMessage(raw)
+	}
+	var verr *InvalidWorkError
+	if errors.As(err, &verr) {
+		rejected.Errors = verr.Errors
+	}
+	b, err := json.Marshal(rejected)
+	if err != nil {
+		return err
+	}
+	_, err = im.Rejected.Write(append(b, '\n'))
+	return err
+}
+
+// Flush stores the queued records in a single transaction
+func (im *Importer) Flush() error {
+	if len(im.batch) == 0 {
+		return im.saveCheckpoint()
+	}
+	batch := im.batch
+	im.batch = nil
+	var rejected []importRecord
+	var rejectedErrs []error
+	var stored []string
+	stats := ImportStats{}
+	store := func(dao *daos.Dao) error {
+		for _, r := range batch {
+			created, warned, err := im.store(dao, r.data)
+			if err != nil {
+				rejected = append(rejected, r)
+				rejectedErrs = append(rejectedErrs, err)
+				continue
+			} else if created {
+				stats.Created++
+			} else {
+				stats.Updated++
+			}
+			if warned {
+				stats.Warned++
+			}
+			stored = append(stored, r.data.ID)
+		}
+		return nil
+	}
+	var err error
+	if im.DryRun {
+		err = store(im.Dao)
+	} else {
+		err = im.Dao.RunInTransaction(store)
+	}
+	if err != nil {
+		return err
+	}
+	if !im.DryRun && im.Mode == Warn {
+		// the violations of the stored works are recorded after the transaction
+		for _, pid := range stored {
+			violation, err := FindViolationByPid(im.Dao, pid)
+			if err != nil {
+				return err
+			} else if violation != nil {
+				stats.Warned++
+			}
+		}
+	}
+	im.Stats.Created += stats.Created
+	im.Stats.Updated += stats.Updated
+	im.Stats.Warned += stats.Warned
+	for i, r := range rejected {
+		if err := im.Reject(r.source, r.raw, rejectedErrs[i]); err != nil {
+			return err
+		}
+	}
+	if im.Progress != nil {
+		elapsed := time.Since(im.start)
+		fmt.Fprintf(im.Progress, "%s in %s (%.0f records/s)\n", im.Stats, elapsed.Round(time.Second), float64(im.Stats.Read)/elapsed.Seconds())
+	}
+	return im.saveCheckpoint()
+}
+
+// saveCheckpoint adds the dump files whose records have all been stored to
+// the checkpoint
+func (im *Importer) saveCheckpoint() error {
+	if im.Checkpoint == nil || im.DryRun || len(im.finished) == 0 {
+		return nil
+	}
+	finished := im.finished
+	im.finished = nil
+	return im.Checkpoint.Add(finished...)
+}
+
+// store creates or updates a work, matching the pid case-insensitively. It
+// reports whether the work was created, and in a dry run whether it has
+// violations in the warn validation mode.
+func (im *Importer) store(dao *daos.Dao, data commonmeta.Data) (bool, bool, error) {
+	existing, err := FindWorkByPid(dao, data.ID)
+	if err != nil {
+		return false, false, err
+	}
+	work := GetWorkFromCommonmeta(data)
+	if existing != nil {
+		work.Id = existing.Id
+		work.Pid = existing.Pid
+		work.Created = existing.Created
+		work.Revision = existing.Revision
+		work.MarkAsNotNew()
+	}
+	if im.DryRun {
+		warned, err := im.check(work)
+		if err != nil {
+			return false, false, err
+		}
+		return existing == nil, warned, nil
+	}
+	if err := dao.Save(work); err != nil {
+		return false, false, err
+	}
+	if err := im.savePrefix(dao, data); err != nil {
+		return false, false, err
+	}
+	return existing == nil, false, nil
+}
+
+// check validates a work in a dry run, as the validation hooks would when it
+// is saved: invalid works are rejected in the reject mode, and saved with
+// violations in the warn mode. It reports whether the work has violations.
+func (im *Importer) check(work *Work) (bool, error) {
+	if im.Mode == Off {
+		return false, nil
+	}
+	_, errs, err := ValidateWork(work, im.Version)
+	if err != nil || len(errs) == 0 {
+		return false, err
+	}
+	if im.Mode == Warn {
+		return true, nil
+	}
+	return false, &InvalidWorkError{Pid: work.Pid, Version: im.Version, Errors: errs}
+}
+
+// savePrefix records the registration agency of the DOI prefix of a work
+// read from Crossref or DataCite, used by FindDoiRegistrationAgency
+func (im *Importer) savePrefix(dao *daos.Dao, data commonmeta.Data) error {
+	prefix, ra, ok := registrationAgency(data.ID, data.Provider)
+	if !ok || im.prefixes[prefix] == ra {
+		return nil
+	}
+	if err := SavePrefix(dao, prefix, ra); err != nil {
+		return err
+	}
+	if im.prefixes == nil {
+		im.prefixes = make(map[string]string)
+	}
+	im.prefixes[prefix] = ra
+	return nil
+}
+
+// ImportFile imports the commonmeta records of a .json, .jsonl or .jsonl.gz
+// file. JSON files hold a single record or an array of records.
+func (im *Importer) ImportFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	name := filepath.Base(filename)
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	add := func(source string, raw []byte) error {
+		var data commonmeta.Data
+		if err := json.Unmarshal(raw, &data); err != nil {
+			im.Stats.Read++
+			return im.Reject(source, raw, err)
+		}
+		return im.Add(source, raw, data)
+	}
+	switch filepath.Ext(name) {
+	case ".jsonl":
+		err = eachJSONLine(r, filename, add)
+	case ".json":
+		err = eachJSONRecord(r, filename, add)
+	default:
+		return fmt.Errorf("%s: unsupported file extension, expected .json, .jsonl or .jsonl.gz", filename)
+	}
+	if err != nil {
+		return err
+	}
+	return im.Flush()
+}
+
+// ImportFileAs imports the works of a file in another format, e.g. BibTeX,
+// read with a reader of the registry. .gz files are decompressed.
+func (im *Importer) ImportFileAs(filename string, reader *Reader) error {
+	results, err := reader.ParseFile(filename)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for i, r := range results {
+		source := fmt.Sprintf("%s:%d", filename, i+1)
+		if r.Err != nil {
+			im.Stats.Read++
+			err = im.Reject(source, r.Raw, r.Err)
+		} else {
+			err = im.Add(source, r.Raw, r.Data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return im.Flush()
+}
+
+// importReader returns the reader for a file imported with a format, or nil
+// for commonmeta files. Without format, the reader is chosen by the file
+// extension, e.g. .bib or .ris.
+func importReader(format string, filename string) (*Reader, error) {
+	if format == "" {
+		ext := filepath.Ext(strings.TrimSuffix(filename, ".gz"))
+		if reader, ok := Readers.ByExtension(ext); ok && reader.Name != "commonmeta" {
+			return reader, nil
+		}
+		return nil, nil
+	}
+	reader, ok := Readers.ByName(format)
+	if !ok {
+		names := make([]string, 0)
+		for _, rd := range Readers.Readers() {
+			names = append(names, rd.Name)
+		}
+		return nil, fmt.Errorf("unknown format %s, expected one of %s", format, strings.Join(names, ", "))
+	}
+	if reader.Name == "commonmeta" {
+		return nil, nil
+	}
+	return reader, nil
+}
+
+// importFiles returns the files to import, walking directories for the files
+// of the format, by default Markdown and Quarto files with front matter.
+// Files and directories starting with _ or . are skipped, e.g. _site.
+func importFiles(format string, paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		extensions, err := importExtensions(format)
+		if err != nil {
+			return nil, err
+		}
+		err = filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if name != path && (strings.HasPrefix(d.Name(), "_") || strings.HasPrefix(d.Name(), ".")) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(name, ".gz")))
+			if !d.IsDir() && slices.Contains(extensions, ext) {
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// importExtensions returns the file extensions of a format imported from a
+// directory
+func importExtensions(format string) ([]string, error) {
+	switch format {
+	case "":
+		format = "frontmatter"
+	case "commonmeta":
+		return []string{".json", ".jsonl"}, nil
+	}
+	reader, ok := Readers.ByName(format)
+	if !ok || reader.Extension == "" {
+		return nil, fmt.Errorf("format %s can't be imported from a directory, import its files instead", format)
+	}
+	return append([]string{reader.Extension}, reader.Extensions...), nil
+}
+
+// eachJSONLine calls fn for each non-empty line of JSON Lines, with the
+// filename and line number as source
+func eachJSONLine(r io.Reader, filename string, fn func(source string, raw []byte) error) error {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if err := fn(fmt.Sprintf("%s:%d", filename, n), line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// eachJSONRecord calls fn for each element of a JSON array, or for a single
+// JSON object, with the filename and record number as source
+func eachJSONRecord(r io.Reader, filename string, fn func(source string, raw []byte) error) error {
+	br := bufio.NewReader(r)
+	var first byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			first = b
+			br.UnreadByte()
+			break
+		}
+	}
+	decoder := json.NewDecoder(br)
+	if first != '[' {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		return fn(filename+":1", raw)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for n := 1; decoder.More(); n++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return fmt.Errorf("%s:%d: %w", filename, n, err)
+		}
+		if err := fn(fmt.Sprintf("%s:%d", filename, n), raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewImportCommand returns the import command of the command line interface,
+// which imports commonmeta files into the works collection. Its subcommands
+// import the data dumps of DOI registration agencies.
+func NewImportCommand(app core.App, mode ValidationMode, version string) *cobra.Command {
+	im := &Importer{Mode: mode, Version: version}
+	var format, rejected, checkpoint string
+	var workers int
+	run := func(cmd *cobra.Command, importFn func() error) error {
+		im.Dao = app.Dao()
+		im.Progress = cmd.ErrOrStderr()
+		if err := RunMigrations(app); err != nil {
+			return err
+		}
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if checkpoint != "" {
+			var err error
+			if im.Checkpoint, err = LoadCheckpoint(checkpoint); err != nil {
+				return err
+			}
+			// keep the records rejected before the import was interrupted
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		if rejected != "" {
+			file, err := os.OpenFile(rejected, flag, 0o644)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			im.Rejected = file
+		}
+		if err := importFn(); err != nil {
+			return err
+		}
+		if im.DryRun {
+			cmd.Printf("Dry run: %s\n", im.Stats)
+		} else {
+			cmd.Printf("Imported %s\n", im.Stats)
+		}
+		return nil
+	}
+
+	cmd := &cobra.Command{
+		Use:   "import [files or directories]",
+		Short: "Import commonmeta .json, .jsonl or .jsonl.gz files, BibTeX, RIS, CSL JSON or JSON Feed files, or directories of Markdown and Quarto files, into the works collection",
+		Args:  cobra.MinimumNArgs(1),
+
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := importReader(format, ""); err != nil {
+				return err
+			}
+			files, err := importFiles(format, args)
+			if err != nil {
+				return err
+			}
+			return run(cmd, func() error {
+				for _, filename := range files {
+					reader, err := importReader(format, filename)
+					if err != nil {
+						return err
+					}
+					if reader != nil {
+						err = im.ImportFileAs(filename, reader)
+					} else {
+						err = im.ImportFile(filename)
+					}
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "format of the files, e.g. bibtex, ris, csl, jsonfeed or frontmatter, defaults to commonmeta or the format of the file extension, and to frontmatter for directories")
+	cmd.PersistentFlags().IntVar(&im.BatchSize, "batch-size", 1000, "number of records per transaction")
+	cmd.PersistentFlags().BoolVar(&im.DryRun, "dry-run", false, "read and validate the records without storing them")
+	cmd.PersistentFlags().StringVar(&rejected, "rejected", "", "write rejected records as JSON Lines to this file")
+
+	dumpCommand := func(use string, short string, importFn func(path string, workers int) error) *cobra.Command {
+		dumpCmd := &cobra.Command{
+			Use:   use,
+			Short: short,
+			Args:  cobra.ExactArgs(1),
+
+			SilenceUsage:  true,
+			SilenceErrors: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return run(cmd, func() error {
+					return importFn(args[0], workers)
+				})
+			},
+		}
+		dumpCmd.Flags().IntVar(&workers, "workers", runtime.NumCPU(), "number of parallel workers reading the data file")
+		dumpCmd.Flags().StringVar(&checkpoint, "checkpoint", "", "record the imported files in this file and skip them when resuming an import")
+		return dumpCmd
+	}
+	cmd.AddCommand(dumpCommand("crossref [path]", "Import the Crossref public data file, a tarball or directory of gzip'd JSON files", im.ImportCrossrefDataFile))
+	cmd.AddCommand(dumpCommand("datacite [path]", "Import the DataCite public data file, a tarball or directory of gzip'd JSON Lines files", im.ImportDataciteDataFile))
+	return cmd
+}
+
+// dumpFile is a file of a data dump, e.g. a member of a tarball
+type dumpFile struct {
+	name string
+	b    []byte
+}
+
+// dumpRecord is a record parsed from a dumpFile
+type dumpRecord struct {
+	source string
+	raw    []byte
+	data   commonmeta.Data
+	err    error
+}
+
+// parsedDumpFile holds the records parsed from a dumpFile
+type parsedDumpFile struct {
+	name    string
+	records []dumpRecord
+}
+
+// dumpFilter matches the files of a dump with one of the suffixes, skipping
+// files already imported according to the checkpoint
+func (im *Importer) dumpFilter(suffixes ...string) func(name string) bool {
+	return func(name string) bool {
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(name, suffix) {
+				return im.Checkpoint == nil || !im.Checkpoint.Done(name)
+			}
+		}
+		return false
+	}
+}
+
+// importDump parses the files sent by produce with parallel workers and adds
+// the records in a single goroutine, as writes to the database are
+// serialized anyway. Records of a file keep their order. A file is added to
+// the checkpoint once all its records are stored.
+func (im *Importer) importDump(workers int, produce func(send func(f dumpFile) error) error, parse func(f dumpFile) []dumpRecord) error {
+	files := make(chan dumpFile)
+	results := make(chan parsedDumpFile)
+	done := make(chan struct{})
+	defer close(done)
+
+	var produceErr error
+	go func() {
+		defer close(files)
+		produceErr = produce(func(f dumpFile) error {
+			select {
+			case files <- f:
+				return nil
+			case <-done:
+				return errors.New("import canceled")
+			}
+		})
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				select {
+				case results <- parsedDumpFile{name: f.name, records: parse(f)}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for parsed := range results {
+		for _, r := range parsed.records {
+			var err error
+			if r.err != nil {
+				im.Stats.Read++
+				err = im.Reject(r.source, r.raw, r.err)
+			} else {
+				err = im.Add(r.source, r.raw, r.data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		im.finished = append(im.finished, parsed.name)
+	}
+	if produceErr != nil {
+		return produceErr
+	}
+	return im.Flush()
+}
+
+// Checkpoint records the files of a data dump that have been imported, so
+// that an interrupted import resumes with the remaining files
+type Checkpoint struct {
+	path  string
+	Files []string `json:"files"`
+	done  map[string]bool
+}
+
+// LoadCheckpoint reads a checkpoint file, starting a new checkpoint if the
+// file doesn't exist
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	cp := &Checkpoint{path: path, Files: []string{}, done: make(map[string]bool)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, name := range cp.Files {
+		cp.done[name] = true
+	}
+	return cp, nil
+}
+
+// Done reports whether a file has been imported
+func (cp *Checkpoint) Done(name string) bool {
+	return cp.done[name]
+}
+
+// Add marks files as imported and saves the checkpoint. The file is replaced
+// atomically, so that it stays intact when the import is interrupted.
+func (cp *Checkpoint) Add(names ...string) error {
+	for _, name := range names {
+		if !cp.done[name] {
+			cp.done[name] = true
+			cp.Files = append(cp.Files, name)
+		}
+	}
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+func TestEachJSONRecord(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input string
+		lines bool
+		want  []string
+	}
+	testCases := []testCase{
+		{input: `{"id":"a"}`, want: []string{`works.json:1 {"id":"a"}`}},
+		{input: "\n [{\"id\":\"a\"},\n {\"id\":\"b\"}]", want: []string{`works.json:1 {"id":"a"}`, `works.json:2 {"id":"b"}`}},
+		{input: "[]", want: []string{}},
+		{input: "", want: []string{}},
+		{input: "{\"id\":\"a\"}\n\n{\"id\":\"b\"}\nnot json", lines: true, want: []string{`works.json:1 {"id":"a"}`, `works.json:3 {"id":"b"}`, `works.json:4 not json`}},
+	}
+	for _, tc := range testCases {
+		got := make([]string, 0)
+		fn := func(source string, raw []byte) error {
+			got = append(got, source+" "+string(raw))
+			return nil
+		}
+		var err error
+		if tc.lines {
+			err = eachJSONLine(strings.NewReader(tc.input), "works.json", fn)
+		} else {
+			err = eachJSONRecord(strings.NewReader(tc.input), "works.json", fn)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(tc.want, "|") != strings.Join(got, "|") {
+			t.Errorf("Each JSON record(%v): want %v, got %v", tc.input, tc.want, got)
+		}
+	}
+
+	err := eachJSONRecord(strings.NewReader(`[{"id":"a"}, {"id":`), "works.json", func(string, []byte) error { return nil })
+	if err == nil || !strings.HasPrefix(err.Error(), "works.json:2") {
+		t.Errorf("Each JSON record: want error for record 2, got %v", err)
+	}
+}
+
+func TestImporterReject(t *testing.T) {
+	t.Parallel()
+
+	var rejected bytes.Buffer
+	im := &Importer{BatchSize: 10, Rejected: &rejected}
+	if err := im.Add("works.jsonl:1", []byte(`{"type":"Dataset"}`), commonmeta.Data{Type: "Dataset"}); err != nil {
+		t.Fatal(err)
+	}
+	err := im.Reject("works.jsonl:2", []byte(`not json`), &InvalidWorkError{Pid: "https://doi.org/10.5555/1", Version: "0.15", Errors: []ValidationError{{Pointer: "/type", Keyword: "enum"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if im.Stats.Read != 1 || im.Stats.Rejected != 2 {
+		t.Errorf("Importer reject: want 1 read and 2 rejected, got %v", im.Stats)
+	}
+	lines := strings.Split(strings.TrimSpace(rejected.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Importer reject: want 2 rejected records, got %v", rejected.String())
+	}
+	var first, second RejectedRecord
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if first.Source != "works.jsonl:1" || first.Error != "missing id" {
+		t.Errorf("Importer reject: want missing id, got %v", first)
+	}
+	if record, ok := first.Record.(map[string]any); !ok || record["type"] != "Dataset" {
+		t.Errorf("Importer reject: want record as JSON, got %v", first.Record)
+	}
+	if second.Record != "not json" || len(second.Errors) != 1 || second.Errors[0].Pointer != "/type" {
+		t.Errorf("Importer reject: want record as string with validation errors, got %v", second)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Done("0.jsonl.gz") {
+		t.Errorf("Checkpoint: want new checkpoint without files, got %v", cp.Files)
+	}
+	if err := cp.Add("0.jsonl.gz", "1.jsonl.gz", "0.jsonl.gz"); err != nil {
+		t.Fatal(err)
+	}
+	cp, err = LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Done("0.jsonl.gz") || !cp.Done("1.jsonl.gz") || cp.Done("2.jsonl.gz") || len(cp.Files) != 2 {
+		t.Errorf("Checkpoint: want 0.jsonl.gz and 1.jsonl.gz done, got %v", cp.Files)
+	}
+
+	// resuming skips the files in the checkpoint
+	im := &Importer{BatchSize: 100, Checkpoint: cp}
+	include := im.dumpFilter(".jsonl.gz")
+	if include("0.jsonl.gz") || !include("2.jsonl.gz") || include("README.md") {
+		t.Errorf("Checkpoint: want filter to skip imported files")
+	}
+	produce := func(send func(f dumpFile) error) error {
+		for _, name := range []string{"2.jsonl.gz", "3.jsonl.gz"} {
+			if err := send(dumpFile{name: name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	parse := func(f dumpFile) []dumpRecord {
+		return []dumpRecord{{source: f.name + ":1", err: errors.New("invalid")}}
+	}
+	if err := im.importDump(2, produce, parse); err != nil {
+		t.Fatal(err)
+	}
+	cp, err = LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.Files) != 4 || !cp.Done("3.jsonl.gz") {
+		t.Errorf("Checkpoint: want 4 files done after import, got %v", cp.Files)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCheckpoint(path); err == nil {
+		t.Errorf("Checkpoint: want error for corrupt checkpoint, got nil")
+	}
+}
+
+func TestImportReader(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		format   string
+		filename string
+		want     string
+		wantErr  bool
+	}
+	testCases := []testCase{
+		{filename: "works.jsonl", want: ""},
+		{filename: "works.json.gz", want: ""},
+		{filename: "library.bib", want: "bibtex"},
+		{filename: "library.ris.gz", want: "ris"},
+		{filename: "posts/post.qmd", want: "frontmatter"},
+		{filename: "README.MD", want: "frontmatter"},
+		{format: "bibtex", filename: "library.txt", want: "bibtex"},
+		{format: "csl", filename: "library.json", want: "csl"},
+		{format: "commonmeta", filename: "library.bib", want: ""},
+		{format: "unknown", filename: "library.bib", wantErr: true},
+	}
+	for _, tc := range testCases {
+		reader, err := importReader(tc.format, tc.filename)
+		if tc.wantErr != (err != nil) {
+			t.Errorf("Import reader(%v %v): want error %v, got %v", tc.format, tc.filename, tc.wantErr, err)
+		}
+		got := ""
+		if reader != nil {
+			got = reader.Name
+		}
+		if tc.want != got {
+			t.Errorf("Import reader(%v %v): want %v, got %v", tc.format, tc.filename, tc.want, got)
+		}
+	}
+}
+
+func TestImportFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"posts", "_site", ".quarto", "data"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"index.qmd", "posts/one.md", "posts/_draft.md", "posts/refs.bib", "_site/index.md", ".quarto/cache.md", "data/works.jsonl.gz", "data/works.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type testCase struct {
+		format  string
+		paths   []string
+		want    []string
+		wantErr bool
+	}
+	testCases := []testCase{
+		{paths: []string{dir}, want: []string{"index.qmd", "posts/one.md"}},
+		{format: "bibtex", paths: []string{dir}, want: []string{"posts/refs.bib"}},
+		{format: "commonmeta", paths: []string{filepath.Join(dir, "data")}, want: []string{"data/works.json", "data/works.jsonl.gz"}},
+		{format: "bibtex", paths: []string{filepath.Join(dir, "posts/_draft.md")}, want: []string{"posts/_draft.md"}},
+		{format: "jsonfeed", paths: []string{dir}, wantErr: true},
+		{paths: []string{filepath.Join(dir, "missing")}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		files, err := importFiles(tc.format, tc.paths)
+		if tc.wantErr != (err != nil) {
+			t.Errorf("Import files(%v %v): want error %v, got %v", tc.format, tc.paths, tc.wantErr, err)
+		}
+		got := make([]string, 0, len(files))
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if !tc.wantErr && !slices.Equal(tc.want, got) {
+			t.Errorf("Import files(%v %v): want %v, got %v", tc.format, tc.paths, tc.want, got)
+		}
+	}
+}
+
+func TestImporterDryRunCheck(t *testing.T) {
+	t.Parallel()
+
+	valid := GetWorkFromCommonmeta(commonmeta.Data{ID: "https://doi.org/10.5555/1", Type: "Dataset", URL: "https://example.org/1"})
+	invalid := GetWorkFromCommonmeta(commonmeta.Data{ID: "https://doi.org/10.5555/2", Type: "Unknown"})
+	type testCase struct {
+		mode       ValidationMode
+		work       *Work
+		wantWarned bool
+		wantErr    bool
+	}
+	testCases := []testCase{
+		{mode: Warn, work: invalid, wantWarned: true, wantErr: false},
+		{mode: Warn, work: valid, wantWarned: false, wantErr: false},
+		{mode: Reject, work: invalid, wantWarned: false, wantErr: true},
+		{mode: Reject, work: valid, wantWarned: false, wantErr: false},
+		{mode: Off, work: invalid, wantWarned: false, wantErr: false},
+	}
+	for _, tc := range testCases {
+		im := &Importer{DryRun: true, Mode: tc.mode, Version: LatestSchemaVersion()}
+		warned, err := im.check(tc.work)
+		if tc.wantWarned != warned || tc.wantErr != (err != nil) {
+			t.Errorf("Importer dry run(%v, %v): want warned %v and error %v, got %v %v", tc.mode, tc.work.Pid, tc.wantWarned, tc.wantErr, warned, err)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+// JATSModes lists the JATS fragments generated from metadata
+var JATSModes = []string{"element-citation", "mixed-citation", "front"}
+
+// CMToJATSMappings maps commonmeta types to JATS publication types
+var CMToJATSMappings = map[string]string{
+	"Article":            "journal",
+	"JournalArticle":     "journal",
+	"Book":               "book",
+	"BookChapter":        "book",
+	"BookSection":        "book",
+	"ProceedingsArticle": "confproc",
+	"Proceedings":        "confproc",
+	"Dataset":            "data",
+	"Software":           "software",
+	"Report":             "report",
+	"Dissertation":       "thesis",
+	"Preprint":           "preprint",
+	"WebPage":            "webpage",
+	"Patent":             "patent",
+}
+
+// jatsWriter writes JATS XML, optionally indented. Mixed citations are written
+// without indentation, as whitespace is significant in mixed content.
+type jatsWriter struct {
+	b      strings.Builder
+	indent bool
+	depth  int
+}
+
+func (w *jatsWriter) newline() {
+	if w.indent {
+		w.b.WriteString("\n" + strings.Repeat("  ", w.depth))
+	}
+}
+
+func (w *jatsWriter) tag(name string, attrs []string) string {
+	s := name
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if attrs[i+1] != "" {
+			s += fmt.Sprintf(` %s="%s"`, attrs[i], xmlEscape(attrs[i+1]))
+		}
+	}
+	return s
+}
+
+func (w *jatsWriter) open(name string, attrs ...string) {
+	w.newline()
+	w.b.WriteString("<" + w.tag(name, attrs) + ">")
+	w.depth++
+}
+
+func (w *jatsWriter) close(name string) {
+	w.depth--
+	w.newline()
+	w.b.WriteString("</" + name + ">")
+}
+
+// elem writes an element with text content, unless the value is empty
+func (w *jatsWriter) elem(name string, value string, attrs ...string) {
+	if value == "" {
+		return
+	}
+	w.newline()
+	w.b.WriteString("<" + w.tag(name, attrs) + ">" + xmlEscape(value) + "</" + name + ">")
+}
+
+// empty writes an element without content
+func (w *jatsWriter) empty(name string, attrs ...string) {
+	w.newline()
+	w.b.WriteString("<" + w.tag(name, attrs) + "/>")
+}
+
+func (w *jatsWriter) text(s string) {
+	w.b.WriteString(xmlEscape(s))
+}
+
+// WriteJATS generates a JATS element-citation, mixed-citation or front block
+// from commonmeta metadata.
+func WriteJATS(data commonmeta.Data, mode string) ([]byte, error) {
+	if data.ID == "" {
+		return nil, fmt.Errorf("missing id")
+	}
+	w := &jatsWriter{indent: mode != "mixed-citation"}
+	switch mode {
+	case "element-citation":
+		writeJATSElementCitation(w, data)
+	case "mixed-citation":
+		writeJATSMixedCitation(w, data)
+	case "front":
+		writeJATSFront(w, data)
+	default:
+		return nil, fmt.Errorf("JATS mode %s not supported, use one of %s", mode, strings.Join(JATSModes, ", "))
+	}
+	return []byte(xml.Header + strings.TrimPrefix(w.b.String(), "\n") + "\n"), nil
+}
+
+func jatsPublicationType(data commonmeta.Data) string {
+	if t, ok := CMToJATSMappings[data.Type]; ok {
+		return t
+	}
+	return "other"
+}
+
+// jatsDateParts splits an ISO 8601 date into year, month and day
+func jatsDateParts(date string) (string, string, string) {
+	var year, month, day string
+	if len(date) >= 4 {
+		year = date[:4]
+	}
+	if len(date) >= 7 {
+		month = date[5:7]
+	}
+	if len(date) >= 10 {
+		day = date[8:10]
+	}
+	return year, month, day
+}
+
+// jatsTitles returns the title elements for a publication type: the title
+// of the work and the source, i.e. the container or book title
+func jatsTitles(data commonmeta.Data) (string, string, string) {
+	title := stripTags(mainTitle(data))
+	switch jatsPublicationType(data) {
+	case "book":
+		if data.Type == "Book" {
+			return "", "", title
+		}
+		return "chapter-title", title, stripTags(data.Container.Title)
+	case "data":
+		return "data-title", title, stripTags(data.Container.Title)
+	case "software", "webpage", "report", "thesis", "other":
+		if data.Container.Title == "" {
+			return "", "", title
+		}
+		return "part-title", title, stripTags(data.Container.Title)
+	default:
+		return "article-title", title, stripTags(data.Container.Title)
+	}
+}
+
+func writeJATSPersonGroup(w *jatsWriter, contributors []commonmeta.Contributor, groupType string) {
+	if len(contributors) == 0 {
+		return
+	}
+	w.open("person-group", "person-group-type", groupType)
+	for _, c := range contributors {
+		if c.FamilyName != "" {
+			w.open("name")
+			w.elem("surname", c.FamilyName)
+			w.elem("given-names", c.GivenName)
+			w.close("name")
+		} else {
+			w.elem("collab", c.Name)
+		}
+	}
+	w.close("person-group")
+}
+
+// editors returns the contributors with the Editor role
+func editors(data commonmeta.Data) []commonmeta.Contributor {
+	e := make([]commonmeta.Contributor, 0)
+	for _, c := range data.Contributors {
+		for _, r := range c.ContributorRoles {
+			if r == "Editor" {
+				e = append(e, c)
+				break
+			}
+		}
+	}
+	return e
+}
+
+func writeJATSElementCitation(w *jatsWriter, data commonmeta.Data) {
+	w.open("element-citation", "publication-type", jatsPublicationType(data), "xmlns:xlink", "http://www.w3.org/1999/xlink")
+	writeJATSPersonGroup(w, authors(data), "author")
+	titleTag, title, source := jatsTitles(data)
+	if titleTag != "" {
+		w.elem(titleTag, title)
+	}
+	writeJATSPersonGroup(w, editors(data), "editor")
+	w.elem("source", source)
+	year, month, day := jatsDateParts(publicationDate(data))
+	w.elem("year", year)
+	w.elem("month", month)
+	w.elem("day", day)
+	w.elem("volume", data.Container.Volume)
+	w.elem("issue", data.Container.Issue)
+	w.elem("fpage", data.Container.FirstPage)
+	w.elem("lpage", data.Container.LastPage)
+	w.elem("version", data.Version)
+	w.elem("publisher-name", data.Publisher.Name)
+	if doi := doiFromPid(data.ID); doi != "" {
+		w.elem("pub-id", doi, "pub-id-type", "doi")
+	} else {
+		w.elem("ext-link", data.ID, "ext-link-type", "uri", "xlink:href", data.ID)
+	}
+	w.close("element-citation")
+}
+
+// writeJATSMixedCitation writes a formatted citation, with the punctuation as
+// text between the elements
+func writeJATSMixedCitation(w *jatsWriter, data commonmeta.Data) {
+	w.open("mixed-citation", "publication-type", jatsPublicationType(data), "xmlns:xlink", "http://www.w3.org/1999/xlink")
+	if a := authors(data); len(a) > 0 {
+		w.open("person-group", "person-group-type", "author")
+		for i, c := range a {
+			if i > 0 {
+				w.text(", ")
+			}
+			if c.FamilyName != "" {
+				w.open("string-name")
+				w.elem("surname", c.FamilyName)
+				if c.GivenName != "" {
+					w.text(" ")
+					w.elem("given-names", c.GivenName)
+				}
+				w.close("string-name")
+			} else {
+				w.elem("collab", c.Name)
+			}
+		}
+		w.close("person-group")
+		w.text(" ")
+	}
+	year, _, _ := jatsDateParts(publicationDate(data))
+	if year != "" {
+		w.text("(")
+		w.elem("year", year)
+		w.text("). ")
+	}
+	titleTag, title, source := jatsTitles(data)
+	if titleTag != "" && title != "" {
+		w.elem(titleTag, title)
+		w.text(". ")
+	}
+	if source != "" {
+		w.elem("source", source)
+		if data.Container.Volume != "" {
+			w.text(", ")
+			w.elem("volume", data.Container.Volume)
+		}
+		if data.Container.Issue != "" {
+			w.text("(")
+			w.elem("issue", data.Container.Issue)
+			w.text(")")
+		}
+		if data.Container.FirstPage != "" {
+			w.text(", ")
+			w.elem("fpage", data.Container.FirstPage)
+			if data.Container.LastPage != "" {
+				w.text("–")
+				w.elem("lpage", data.Container.LastPage)
+			}
+		}
+		w.text(". ")
+	}
+	if data.Publisher.Name != "" && jatsPublicationType(data) != "journal" {
+		w.elem("publisher-name", data.Publisher.Name)
+		w.text(". ")
+	}
+	if doi := doiFromPid(data.ID); doi != "" {
+		w.open("ext-link", "ext-link-type", "doi", "xlink:href", data.ID)
+		w.text(data.ID)
+		w.close("ext-link")
+	} else {
+		w.elem("ext-link", data.ID, "ext-link-type", "uri", "xlink:href", data.ID)
+	}
+	w.close("mixed-citation")
+}
+
+// writeJATSFront writes the journal and article metadata of a JATS article
+func writeJATSFront(w *jatsWriter, data commonmeta.Data) {
+	w.open("front", "xmlns:xlink", "http://www.w3.org/1999/xlink")
+	w.open("journal-meta")
+	if data.Container.Title != "" {
+		w.open("journal-title-group")
+		w.elem("journal-title", stripTags(data.Container.Title))
+		w.close("journal-title-group")
+	}
+	if data.Container.IdentifierType == "ISSN" {
+		w.elem("issn", data.Container.Identifier)
+	}
+	if data.Publisher.Name != "" {
+		w.open("publisher")
+		w.elem("publisher-name", data.Publisher.Name)
+		w.close("publisher")
+	}
+	w.close("journal-meta")
+
+	w.open("article-meta")
+	if doi := doiFromPid(data.ID); doi != "" {
+		w.elem("article-id", doi, "pub-id-type", "doi")
+	} else {
+		w.elem("article-id", data.ID, "pub-id-type", "uri")
+	}
+	w.open("title-group")
+	w.elem("article-title", stripTags(mainTitle(data)))
+	for _, t := range data.Titles {
+		if t.Type == "Subtitle" {
+			w.elem("subtitle", stripTags(t.Title))
+		}
+	}
+	w.close("title-group")
+	if len(data.Contributors) > 0 {
+		w.open("contrib-group")
+		for _, c := range data.Contributors {
+			contribType := "author"
+			if len(c.ContributorRoles) > 0 && c.ContributorRoles[0] != "Author" {
+				contribType = strings.ToLower(c.ContributorRoles[0])
+			}
+			w.open("contrib", "contrib-type", contribType)
+			if strings.HasPrefix(c.ID, "https://orcid.org/") {
+				w.elem("contrib-id", c.ID, "contrib-id-type", "orcid")
+			}
+			if c.FamilyName != "" {
+				w.open("name")
+				w.elem("surname", c.FamilyName)
+				w.elem("given-names", c.GivenName)
+				w.close("name")
+			} else {
+				w.elem("collab", c.Name)
+			}
+			for _, a := range c.Affiliations {
+				if a != nil {
+					w.elem("aff", a.Name)
+				}
+			}
+			w.close("contrib")
+		}
+		w.close("contrib-group")
+	}
+	if year, month, day := jatsDateParts(publicationDate(data)); year != "" {
+		w.open("pub-date", "date-type", "pub", "publication-format", "electronic")
+		w.elem("day", day)
+		w.elem("month", month)
+		w.elem("year", year)
+		w.close("pub-date")
+	}
+	w.elem("volume", data.Container.Volume)
+	w.elem("issue", data.Container.Issue)
+	w.elem("fpage", data.Container.FirstPage)
+	w.elem("lpage", data.Container.LastPage)
+	if license := licenseURL(data); license != "" {
+		w.open("permissions")
+		w.empty("license", "xlink:href", license)
+		w.close("permissions")
+	}
+	if data.URL != "" {
+		w.empty("self-uri", "xlink:href", data.URL)
+	}
+	if a := stripTags(abstract(data)); a != "" {
+		w.open("abstract")
+		w.elem("p", a)
+		w.close("abstract")
+	}
+	if k := keywords(data); len(k) > 0 {
+		w.open("kwd-group")
+		for _, v := range k {
+			w.elem("kwd", v)
+		}
+		w.close("kwd-group")
+	}
+	if len(data.FundingReferences) > 0 {
+		w.open("funding-group")
+		for _, f := range data.FundingReferences {
+			w.open("award-group")
+			if strings.Contains(f.FunderIdentifier, "10.13039/") {
+				w.open("funding-source")
+				w.open("institution-wrap")
+				w.elem("institution-id", f.FunderIdentifier, "institution-id-type", "FundRef")
+				w.elem("institution", f.FunderName)
+				w.close("institution-wrap")
+				w.close("funding-source")
+			} else {
+				w.elem("funding-source", f.FunderName)
+			}
+			w.elem("award-id", f.AwardNumber)
+			w.close("award-group")
+		}
+		w.close("funding-group")
+	}
+	w.close("article-meta")
+	w.close("front")
+}
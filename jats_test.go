@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+var jatsData = commonmeta.Data{
+	ID:   "https://doi.org/10.7554/elife.01567",
+	Type: "JournalArticle",
+	Contributors: []commonmeta.Contributor{
+		{ID: "https://orcid.org/0000-0002-8419-5237", Type: "Person", GivenName: "Martial", FamilyName: "Sankar", ContributorRoles: []string{"Author"}, Affiliations: []*commonmeta.Affiliation{{Name: "University of Lausanne"}}},
+		{Type: "Person", GivenName: "Kaisa", FamilyName: "Nieminen", ContributorRoles: []string{"Author"}},
+	},
+	Titles:            []commonmeta.Title{{Title: "Automated quantitative histology"}},
+	Container:         commonmeta.Container{Title: "eLife", Identifier: "2050-084X", IdentifierType: "ISSN", Volume: "3", FirstPage: "e01567"},
+	Publisher:         commonmeta.Publisher{Name: "eLife Sciences Publications, Ltd"},
+	Date:              commonmeta.Date{Published: "2014-02-11"},
+	Descriptions:      []commonmeta.Description{{Description: "Among the most striking aspects", Type: "Abstract"}},
+	FundingReferences: []commonmeta.FundingReference{{FunderIdentifier: "https://doi.org/10.13039/501100001711", FunderName: "Swiss National Science Foundation", AwardNumber: "CRSII3_136278"}},
+	License:           commonmeta.License{ID: "CC-BY-3.0", URL: "https://creativecommons.org/licenses/by/3.0/legalcode"},
+}
+
+func TestWriteJATS(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		mode string
+		want string
+	}
+	testCases := []testCase{
+		{mode: "element-citation", want: `<element-citation publication-type="journal" xmlns:xlink="http://www.w3.org/1999/xlink">
+  <person-group person-group-type="author">
+    <name>
+      <surname>Sankar</surname>
+      <given-names>Martial</given-names>
+    </name>
+    <name>
+      <surname>Nieminen</surname>
+      <given-names>Kaisa</given-names>
+    </name>
+  </person-group>
+  <article-title>Automated quantitative histology</article-title>
+  <source>eLife</source>
+  <year>2014</year>
+  <month>02</month>
+  <day>11</day>
+  <volume>3</volume>
+  <fpage>e01567</fpage>
+  <publisher-name>eLife Sciences Publications, Ltd</publisher-name>
+  <pub-id pub-id-type="doi">10.7554/elife.01567</pub-id>
+</element-citation>
+`},
+		{mode: "mixed-citation", want: `<mixed-citation publication-type="journal" xmlns:xlink="http://www.w3.org/1999/xlink"><person-group person-group-type="author"><string-name><surname>Sankar</surname> <given-names>Martial</given-names></string-name>, <string-name><surname>Nieminen</surname> <given-names>Kaisa</given-names></string-name></person-group> (<year>2014</year>). <article-title>Automated quantitative histology</article-title>. <source>eLife</source>, <volume>3</volume>, <fpage>e01567</fpage>. <ext-link ext-link-type="doi" xlink:href="https://doi.org/10.7554/elife.01567">https://doi.org/10.7554/elife.01567</ext-link></mixed-citation>
+`},
+	}
+	for _, tc := range testCases {
+		out, err := WriteJATS(jatsData, tc.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.TrimPrefix(string(out), `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+		if tc.want != got {
+			t.Errorf("Write JATS(%v): want\n%v\ngot\n%v", tc.mode, tc.want, got)
+		}
+	}
+}
+
+func TestWriteJATSFront(t *testing.T) {
+	t.Parallel()
+
+	out, err := WriteJATS(jatsData, "front")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	want := []string{
+		"<journal-title>eLife</journal-title>",
+		"<issn>2050-084X</issn>",
+		`<article-id pub-id-type="doi">10.7554/elife.01567</article-id>`,
+		`<contrib-id contrib-id-type="orcid">https://orcid.org/0000-0002-8419-5237</contrib-id>`,
+		"<aff>University of Lausanne</aff>",
+		`<pub-date date-type="pub" publication-format="electronic">`,
+		`<license xlink:href="https://creativecommons.org/licenses/by/3.0/legalcode"/>`,
+		`<institution-id institution-id-type="FundRef">https://doi.org/10.13039/501100001711</institution-id>`,
+		"<award-id>CRSII3_136278</award-id>",
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Write JATS front: want %v, got\n%v", w, got)
+		}
+	}
+	if _, err := WriteJATS(jatsData, "article"); err == nil {
+		t.Errorf("Write JATS: want error for unsupported mode")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/doiutils"
+	"github.com/front-matter/commonmeta/utils"
+)
+
+// jsonFeed is a feed in JSON Feed 1.1, see https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version     string            `json:"version"`
+	Title       string            `json:"title"`
+	HomePageURL string            `json:"home_page_url"`
+	Language    string            `json:"language"`
+	Authors     []jsonFeedAuthor  `json:"authors"`
+	Author      *jsonFeedAuthor   `json:"author"`
+	Items       []json.RawMessage `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	ExternalURL   string           `json:"external_url"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	Author        *jsonFeedAuthor  `json:"author"`
+	Tags          []string         `json:"tags"`
+	Language      string           `json:"language"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// ReadJSONFeedAll reads the items of a JSON Feed as blog posts. The feed is
+// the container of its items, and its authors and language are used for
+// items without them. JSON Feed 1.0 feeds with a single author are supported.
+func ReadJSONFeedAll(b []byte) ([]ReadResult, error) {
+	var feed jsonFeed
+	if err := json.Unmarshal(b, &feed); err != nil {
+		return nil, err
+	}
+	if feed.Author != nil {
+		feed.Authors = append(feed.Authors, *feed.Author)
+	}
+	results := make([]ReadResult, 0, len(feed.Items))
+	for _, raw := range feed.Items {
+		var item jsonFeedItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			results = append(results, ReadResult{Raw: raw, Err: err})
+			continue
+		}
+		results = append(results, ReadResult{Raw: raw, Key: item.ID, Data: readJSONFeedItem(feed, item)})
+	}
+	return results, nil
+}
+
+// readJSONFeedItem converts an item of a JSON Feed to commonmeta. The DOI is
+// taken from the id or external_url of the item, the pid of items without
+// DOI from their URL.
+func readJSONFeedItem(feed jsonFeed, item jsonFeedItem) commonmeta.Data {
+	doi := item.ID
+	if doiutils.NormalizeDOI(doi) == "" {
+		doi = item.ExternalURL
+	}
+	data := commonmeta.Data{
+		ID:   workPid(doi, item.URL),
+		Type: "BlogPost",
+		URL:  item.URL,
+	}
+	if data.ID == "" {
+		data.ID = pidFromURL(item.ID)
+	}
+
+	authors := item.Authors
+	if item.Author != nil {
+		authors = append(authors, *item.Author)
+	}
+	if len(authors) == 0 {
+		authors = feed.Authors
+	}
+	for _, a := range authors {
+		if strings.TrimSpace(a.Name) == "" {
+			continue
+		}
+		c := contributorFromName(a.Name, "Author")
+		c.ID = utils.NormalizeORCID(a.URL)
+		data.Contributors = append(data.Contributors, c)
+	}
+
+	if item.Title != "" {
+		data.Titles = append(data.Titles, commonmeta.Title{Title: item.Title})
+	}
+	if feed.Title != "" {
+		data.Container = commonmeta.Container{Title: feed.Title, Type: "Periodical"}
+		if feed.HomePageURL != "" {
+			data.Container.Identifier, data.Container.IdentifierType = feed.HomePageURL, "URL"
+		}
+	}
+	data.Date.Published = parseDate(item.DatePublished)
+	data.Date.Updated = parseDate(item.DateModified)
+	if item.Summary != "" {
+		data.Descriptions = append(data.Descriptions, commonmeta.Description{Description: item.Summary, Type: "Abstract"})
+	}
+	if subjects := subjectsFromKeywords(item.Tags...); len(subjects) > 0 {
+		data.Subjects = subjects
+	}
+	data.Language = item.Language
+	if data.Language == "" {
+		data.Language = feed.Language
+	}
+	return data
+}
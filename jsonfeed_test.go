@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReadJSONFeedAll(t *testing.T) {
+	t.Parallel()
+
+	input := `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "Front Matter",
+	"home_page_url": "https://blog.front-matter.io",
+	"language": "en",
+	"authors": [{"name": "Martin Fenner", "url": "https://orcid.org/0000-0003-1419-2405"}],
+	"items": [
+		{"id": "https://doi.org/10.53731/r79z0kh-97aq74v-ag58n", "url": "https://blog.front-matter.io/posts/one",
+		 "title": "A post with DOI", "summary": "A summary", "date_published": "2023-06-02T10:00:00Z",
+		 "date_modified": "2023-06-03T08:00:00+02:00", "tags": ["Feature", "Metadata"]},
+		{"id": "2", "url": "http://Blog.Front-Matter.io/posts/two/", "title": "A post without DOI",
+		 "date_published": "2023-07-01T00:00:00Z", "authors": [{"name": "Jane Doe"}], "language": "de"},
+		{"id": 3}
+	]
+}`
+	results, err := ReadJSONFeedAll([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Read JSON Feed: want 3 items, got %v", len(results))
+	}
+
+	post := results[0].Data
+	if post.ID != "https://doi.org/10.53731/r79z0kh-97aq74v-ag58n" || post.Type != "BlogPost" || post.URL != "https://blog.front-matter.io/posts/one" {
+		t.Errorf("Read JSON Feed: want post with DOI, got %v %v %v", post.ID, post.Type, post.URL)
+	}
+	if len(post.Contributors) != 1 || post.Contributors[0].ID != "https://orcid.org/0000-0003-1419-2405" {
+		t.Errorf("Read JSON Feed: want feed author with ORCID, got %v", post.Contributors)
+	}
+	if post.Container.Title != "Front Matter" || post.Container.Identifier != "https://blog.front-matter.io" {
+		t.Errorf("Read JSON Feed: want feed as container, got %v", post.Container)
+	}
+	if post.Date.Published != "2023-06-02" || post.Date.Updated != "2023-06-03" || post.Language != "en" || len(post.Subjects) != 2 {
+		t.Errorf("Read JSON Feed: want dates, language and subjects, got %v %v %v", post.Date, post.Language, post.Subjects)
+	}
+
+	// items without DOI get a pid from their URL
+	other := results[1].Data
+	if other.ID != "https://blog.front-matter.io/posts/two" || other.Language != "de" {
+		t.Errorf("Read JSON Feed: want pid from URL, got %v %v", other.ID, other.Language)
+	}
+	if len(other.Contributors) != 1 || other.Contributors[0].FamilyName != "Doe" {
+		t.Errorf("Read JSON Feed: want item author, got %v", other.Contributors)
+	}
+
+	if results[2].Err == nil {
+		t.Errorf("Read JSON Feed: want error for invalid item, got nil")
+	}
+}
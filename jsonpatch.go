@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to a JSON document
+func MergePatch(document []byte, patch []byte) ([]byte, error) {
+	doc, err := decodeJSON(document)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodeJSON(patch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(doc, p))
+}
+
+func mergePatch(target any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], value)
+		}
+	}
+	return t
+}
+
+// PatchError is returned when a JSON Patch can't be applied to a document,
+// e.g. because a path doesn't exist or a test operation fails
+type PatchError struct {
+	Op      string
+	Path    string
+	Message string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Op, e.Path, e.Message)
+}
+
+// jsonPatchOperation is an operation of a JSON Patch. A missing value is
+// nil, a null value is the JSON null.
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// JSONPatch applies a JSON Patch (RFC 6902) to a JSON document. The
+// operations are applied in order, and the document is only changed if all
+// of them succeed.
+func JSONPatch(document []byte, patch []byte) ([]byte, error) {
+	doc, err := decodeJSON(document)
+	if err != nil {
+		return nil, err
+	}
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if doc, err = applyPatchOperation(doc, op); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(doc)
+}
+
+func applyPatchOperation(doc any, op jsonPatchOperation) (any, error) {
+	fail := func(message string, args ...any) error {
+		return &PatchError{Op: op.Op, Path: op.Path, Message: fmt.Sprintf(message, args...)}
+	}
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, fail(err.Error())
+	}
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fail("missing value")
+		}
+		if value, err = decodeJSON(op.Value); err != nil {
+			return nil, fail(err.Error())
+		}
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, fail(err.Error())
+		}
+		if value, err = pointerGet(doc, from); err != nil {
+			return nil, fail(err.Error())
+		}
+		if op.Op == "copy" {
+			// the copy must not share maps and slices with the original
+			b, _ := json.Marshal(value)
+			value, _ = decodeJSON(b)
+			break
+		}
+		if op.Path == op.From {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fail("can't move %s into itself", op.From)
+		}
+		if doc, err = pointerRemove(doc, from); err != nil {
+			return nil, fail(err.Error())
+		}
+	case "remove":
+	default:
+		return nil, fail("unknown operation")
+	}
+
+	switch op.Op {
+	case "add", "move", "copy":
+		doc, err = pointerAdd(doc, path, value)
+	case "remove":
+		doc, err = pointerRemove(doc, path)
+	case "replace":
+		if _, err = pointerGet(doc, path); err == nil {
+			doc, err = pointerReplace(doc, path, value)
+		}
+	case "test":
+		var current any
+		if current, err = pointerGet(doc, path); err == nil && !reflect.DeepEqual(current, value) {
+			return nil, fail("test failed")
+		}
+	}
+	if err != nil {
+		return nil, fail(err.Error())
+	}
+	return doc, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// pointerGet returns the value at a JSON Pointer
+func pointerGet(doc any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%s not found", token)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds a value at a JSON Pointer, inserting it into arrays
+func pointerAdd(doc any, tokens []string, value any) (any, error) {
+	return pointerUpdate(doc, tokens, value, func(node any, token string) (any, error) {
+		switch node := node.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			if token == "-" {
+				return append(node, value), nil
+			}
+			i, err := arrayIndex(token, len(node)+1)
+			if err != nil {
+				return nil, err
+			}
+			return slices.Insert(node, i, value), nil
+		}
+		return nil, fmt.Errorf("%s not found", token)
+	})
+}
+
+// pointerReplace replaces the existing value at a JSON Pointer
+func pointerReplace(doc any, tokens []string, value any) (any, error) {
+	return pointerUpdate(doc, tokens, value, func(node any, token string) (any, error) {
+		switch node := node.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("%s not found", token)
+	})
+}
+
+// pointerRemove removes the value at a JSON Pointer
+func pointerRemove(doc any, tokens []string) (any, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("can't remove the document")
+	}
+	return pointerUpdate(doc, tokens, nil, func(node any, token string) (any, error) {
+		switch node := node.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("%s not found", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			return slices.Delete(node, i, i+1), nil
+		}
+		return nil, fmt.Errorf("%s not found", token)
+	})
+}
+
+// pointerUpdate calls update with the parent of the value at a JSON Pointer
+// and the last token, and stores the updated parent in the document. The
+// empty pointer replaces the whole document with value.
+func pointerUpdate(doc any, tokens []string, value any, update func(node any, token string) (any, error)) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	if len(tokens) == 1 {
+		return update(doc, tokens[0])
+	}
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s not found", tokens[0])
+		}
+		child, err := pointerUpdate(child, tokens[1:], value, update)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = child
+		return node, nil
+	case []any:
+		i, err := arrayIndex(tokens[0], len(node))
+		if err != nil {
+			return nil, err
+		}
+		child, err := pointerUpdate(node[i], tokens[1:], value, update)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+	return nil, fmt.Errorf("%s not found", tokens[0])
+}
+
+// arrayIndex parses the index of an array element, which must be below n
+func arrayIndex(token string, n int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+	if i >= n {
+		return 0, fmt.Errorf("array index %s out of range", token)
+	}
+	return i, nil
+}
+
+// decodeJSON decodes a JSON value, keeping numbers as they are
+func decodeJSON(b []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
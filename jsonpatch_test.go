@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		document string
+		patch    string
+		want     string
+	}
+	// examples from RFC 7396, Appendix A
+	testCases := []testCase{
+		{document: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{document: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{document: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{document: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{document: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{document: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{document: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{document: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{document: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{document: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{document: `{"a":"foo"}`, patch: `null`, want: `null`},
+		{document: `{"e":null}`, patch: `{"a":1}`, want: `{"a":1,"e":null}`},
+		{document: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{document: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range testCases {
+		got, err := MergePatch([]byte(tc.document), []byte(tc.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(t, tc.want, string(got)) {
+			t.Errorf("MergePatch(%v, %v): want %v, got %v", tc.document, tc.patch, tc.want, string(got))
+		}
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"a":`)); err == nil {
+		t.Errorf("MergePatch(invalid JSON): want error, got nil")
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		document string
+		patch    string
+		want     string
+		wantErr  bool
+	}
+	// mostly examples from RFC 6902, Appendix A
+	testCases := []testCase{
+		{document: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz","value":"qux"}]`, want: `{"baz":"qux","foo":"bar"}`},
+		{document: `{"foo":["bar","baz"]}`, patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`, want: `{"foo":["bar","qux","baz"]}`},
+		{document: `{"foo":["bar"]}`, patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, want: `{"foo":["bar",["abc","def"]]}`},
+		{document: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`, want: `{"foo":"bar"}`},
+		{document: `{"foo":["bar","qux","baz"]}`, patch: `[{"op":"remove","path":"/foo/1"}]`, want: `{"foo":["bar","baz"]}`},
+		{document: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"replace","path":"/baz","value":"boo"}]`, want: `{"baz":"boo","foo":"bar"}`},
+		{document: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, want: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{document: `{"foo":["all","grass","cows","eat"]}`, patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, want: `{"foo":["all","cows","eat","grass"]}`},
+		{document: `{"baz":"qux","foo":["a",2,"c"]}`, patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, want: `{"baz":"qux","foo":["a",2,"c"]}`},
+		{document: `{"baz":"qux"}`, patch: `[{"op":"test","path":"/baz","value":"bar"}]`, wantErr: true},
+		{document: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, want: `{"foo":"bar","child":{"grandchild":{}}}`},
+		{document: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`, wantErr: true},
+		{document: `{"/":9,"~1":10}`, patch: `[{"op":"test","path":"/~01","value":10}]`, want: `{"/":9,"~1":10}`},
+		{document: `{"foo":["bar","baz"]}`, patch: `[{"op":"add","path":"/foo/-","value":"qux"},{"op":"copy","from":"/foo","path":"/bar"},{"op":"remove","path":"/bar/0"}]`, want: `{"foo":["bar","baz","qux"],"bar":["baz","qux"]}`},
+		{document: `{"foo":null}`, patch: `[{"op":"replace","path":"/foo","value":null}]`, want: `{"foo":null}`},
+		{document: `{"foo":"bar"}`, patch: `[{"op":"replace","path":"/baz","value":"qux"}]`, wantErr: true},
+		{document: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz"}]`, wantErr: true},
+		{document: `{"foo":["bar"]}`, patch: `[{"op":"add","path":"/foo/01","value":"qux"}]`, wantErr: true},
+		{document: `{"foo":["bar"]}`, patch: `[{"op":"remove","path":"/foo/1"}]`, wantErr: true},
+		{document: `{"foo":{"bar":1}}`, patch: `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, wantErr: true},
+		{document: `{"foo":"bar"}`, patch: `[{"op":"unknown","path":"/foo"}]`, wantErr: true},
+		{document: `{"foo":"bar"}`, patch: `[{"op":"add","path":"foo","value":1}]`, wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := JSONPatch([]byte(tc.document), []byte(tc.patch))
+		var perr *PatchError
+		if tc.wantErr {
+			if !errors.As(err, &perr) {
+				t.Errorf("JSONPatch(%v, %v): want PatchError, got %v", tc.document, tc.patch, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("JSONPatch(%v, %v): want %v, got error %v", tc.document, tc.patch, tc.want, err)
+			continue
+		}
+		if !jsonEqual(t, tc.want, string(got)) {
+			t.Errorf("JSONPatch(%v, %v): want %v, got %v", tc.document, tc.patch, tc.want, string(got))
+		}
+	}
+	if _, err := JSONPatch([]byte(`{}`), []byte(`{"op":"add"}`)); err == nil || errors.As(err, new(*PatchError)) {
+		t.Errorf("JSONPatch(not an array): want syntax error, got %v", err)
+	}
+}
+
+// jsonEqual reports whether two JSON documents have the same value
+func jsonEqual(t *testing.T, a string, b string) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
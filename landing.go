@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/schemaorg"
+)
+
+//go:embed resources/templates/landing.html
+var landingTemplate string
+
+var landingPage = template.Must(template.New("landing").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(landingTemplate))
+
+// LandingPage holds the values rendered in the landing page template
+type LandingPage struct {
+	ID           string
+	Type         string
+	Title        string
+	Subtitles    []string
+	Language     string
+	Contributors []LandingContributor
+	Container    string
+	Publisher    string
+	Date         string
+	Version      string
+	Abstract     string
+	Keywords     []string
+	Files        []LandingFile
+	References   []LandingReference
+	License      string
+	LicenseURL   string
+	Citation     string
+	Meta         []HighwireMeta
+	JSONLD       template.JS
+}
+
+// LandingContributor is a contributor with ORCID and roles other than author
+type LandingContributor struct {
+	Name  string
+	ORCID string
+	Roles []string
+}
+
+// LandingFile is a file attached to the work
+type LandingFile struct {
+	Name     string
+	URL      string
+	MimeType string
+}
+
+// LandingReference is a formatted reference
+type LandingReference struct {
+	Citation string
+	URL      string
+}
+
+// HighwireMeta is a Highwire Press citation_* meta tag, as used by Google Scholar and Zotero
+type HighwireMeta struct {
+	Name    string
+	Content string
+}
+
+// WriteLandingPage renders a HTML landing page for a work, with schema.org
+// JSON-LD and Highwire Press meta tags. The style and locale parameters select
+// the citation style of the "cite as" box.
+func WriteLandingPage(r *WriteRequest) ([]byte, error) {
+	data := r.Data
+	p := LandingPage{
+		ID:         data.ID,
+		Type:       data.Type,
+		Title:      stripTags(mainTitle(data)),
+		Language:   data.Language,
+		Container:  stripTags(data.Container.Title),
+		Publisher:  data.Publisher.Name,
+		Date:       publicationDate(data),
+		Version:    data.Version,
+		Abstract:   stripTags(abstract(data)),
+		Keywords:   keywords(data),
+		License:    data.License.ID,
+		LicenseURL: licenseURL(data),
+		Meta:       highwireMetaTags(data),
+	}
+	for _, t := range data.Titles {
+		if t.Type == "Subtitle" {
+			p.Subtitles = append(p.Subtitles, stripTags(t.Title))
+		}
+	}
+	for _, c := range data.Contributors {
+		contributor := LandingContributor{Name: c.Name}
+		if c.FamilyName != "" {
+			contributor.Name = strings.TrimSpace(c.GivenName + " " + c.FamilyName)
+		}
+		if strings.HasPrefix(c.ID, "https://orcid.org/") {
+			contributor.ORCID = c.ID
+		}
+		for _, role := range c.ContributorRoles {
+			if role != "Author" {
+				contributor.Roles = append(contributor.Roles, role)
+			}
+		}
+		p.Contributors = append(p.Contributors, contributor)
+	}
+	for _, f := range data.Files {
+		if f.URL == "" {
+			continue
+		}
+		name := f.Key
+		if name == "" {
+			name = path.Base(f.URL)
+		}
+		p.Files = append(p.Files, LandingFile{Name: name, URL: f.URL, MimeType: f.MimeType})
+	}
+	p.References = landingReferences(r)
+
+	citation, err := WriteCitation(data, r.Params["style"], r.Params["locale"])
+	if err != nil {
+		return nil, &WriteError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	p.Citation = citation
+
+	so, err := schemaorg.Convert(data)
+	if err != nil {
+		return nil, err
+	}
+	jsonld, err := json.Marshal(so)
+	if err != nil {
+		return nil, err
+	}
+	// json.Marshal escapes <, > and &, so the JSON-LD can't close the script element
+	p.JSONLD = template.JS(jsonld)
+
+	var b bytes.Buffer
+	if err := landingPage.Execute(&b, p); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// landingReferences formats the references of a work. References that were
+// looked up in the works collection are formatted as citations, all others
+// use their unstructured citation or title.
+func landingReferences(r *WriteRequest) []LandingReference {
+	var works []Work
+	if r.Work != nil {
+		_ = json.Unmarshal(r.Work.References, &works)
+	}
+	references := make([]LandingReference, 0)
+	for i, ref := range r.Data.References {
+		reference := LandingReference{URL: ref.ID}
+		if i < len(works) {
+			if data, err := WriteWorkToCommonmeta(&works[i]); err == nil && len(data.Titles) > 0 {
+				reference.Citation, _ = WriteCitation(data, r.Params["style"], r.Params["locale"])
+			}
+		}
+		if reference.Citation == "" {
+			reference.Citation = ref.Unstructured
+		}
+		if reference.Citation == "" {
+			reference.Citation = strings.TrimSpace(stripTags(ref.Title) + " " + ref.PublicationYear)
+		}
+		if reference.Citation == "" && reference.URL == "" {
+			continue
+		}
+		references = append(references, reference)
+	}
+	return references
+}
+
+// highwireMetaTags returns the Highwire Press citation_* meta tags of a work
+func highwireMetaTags(data commonmeta.Data) []HighwireMeta {
+	meta := make([]HighwireMeta, 0)
+	add := func(name string, content string) {
+		if content != "" {
+			meta = append(meta, HighwireMeta{Name: name, Content: content})
+		}
+	}
+	add("citation_title", stripTags(mainTitle(data)))
+	for _, a := range authors(data) {
+		add("citation_author", contributorName(a))
+		if strings.HasPrefix(a.ID, "https://orcid.org/") {
+			add("citation_author_orcid", a.ID)
+		}
+		for _, affiliation := range a.Affiliations {
+			if affiliation != nil {
+				add("citation_author_institution", affiliation.Name)
+			}
+		}
+	}
+	// Google Scholar expects dates as YYYY/MM/DD
+	date := publicationDate(data)
+	if len(date) > 10 {
+		date = date[:10]
+	}
+	add("citation_publication_date", strings.ReplaceAll(date, "-", "/"))
+	switch {
+	case slices.Contains([]string{"JournalArticle", "Article"}, data.Type):
+		add("citation_journal_title", stripTags(data.Container.Title))
+	case data.Type == "ProceedingsArticle":
+		add("citation_conference_title", stripTags(data.Container.Title))
+	case data.Type == "BookChapter":
+		add("citation_inbook_title", stripTags(data.Container.Title))
+	case data.Type == "Dissertation":
+		add("citation_dissertation_institution", data.Publisher.Name)
+	case data.Type == "Report":
+		add("citation_technical_report_institution", data.Publisher.Name)
+	}
+	switch data.Container.IdentifierType {
+	case "ISSN":
+		add("citation_issn", data.Container.Identifier)
+	case "ISBN":
+		add("citation_isbn", data.Container.Identifier)
+	}
+	add("citation_volume", data.Container.Volume)
+	add("citation_issue", data.Container.Issue)
+	add("citation_firstpage", data.Container.FirstPage)
+	add("citation_lastpage", data.Container.LastPage)
+	add("citation_publisher", data.Publisher.Name)
+	add("citation_doi", doiFromPid(data.ID))
+	add("citation_language", data.Language)
+	add("citation_keywords", strings.Join(keywords(data), "; "))
+	add("citation_abstract_html_url", data.URL)
+	for _, f := range data.Files {
+		if f.MimeType == "application/pdf" {
+			add("citation_pdf_url", f.URL)
+			break
+		}
+	}
+	return meta
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestWriteLandingPage(t *testing.T) {
+	t.Parallel()
+
+	data := catalogData
+	data.Files = []commonmeta.File{{URL: "https://elifesciences.org/articles/01567.pdf", MimeType: "application/pdf"}}
+	data.References = []commonmeta.Reference{
+		{Key: "ref1", ID: "https://doi.org/10.1038/nature12373"},
+		{Key: "ref2", Unstructured: "Sankar M, et al. 2013. <Unpublished>."},
+	}
+	reference := GetWorkFromCommonmeta(commonmeta.Data{
+		ID:           "https://doi.org/10.1038/nature12373",
+		Type:         "JournalArticle",
+		Titles:       []commonmeta.Title{{Title: "Nanometre-scale thermometry in a living cell"}},
+		Contributors: []commonmeta.Contributor{{Type: "Person", GivenName: "G.", FamilyName: "Kucsko", ContributorRoles: []string{"Author"}}},
+		Container:    commonmeta.Container{Title: "Nature", Volume: "500"},
+		Date:         commonmeta.Date{Published: "2013-07-31"},
+	})
+	references, err := json.Marshal([]any{reference, data.References[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	work := &Work{References: types.JsonRaw(references)}
+
+	out, err := WriteLandingPage(&WriteRequest{Work: work, Data: data, Params: map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	want := []string{
+		"<title>Automated quantitative histology</title>",
+		`<meta name="citation_title" content="Automated quantitative histology">`,
+		`<meta name="citation_author" content="Sankar, Martial">`,
+		`<meta name="citation_publication_date" content="2014/02/11">`,
+		`<meta name="citation_journal_title" content="eLife">`,
+		`<meta name="citation_doi" content="10.7554/elife.01567">`,
+		`<meta name="citation_pdf_url" content="https://elifesciences.org/articles/01567.pdf">`,
+		`<script type="application/ld+json">{"@context":"http://schema.org"`,
+		`<a href="https://orcid.org/0000-0002-8419-5237" title="ORCID https://orcid.org/0000-0002-8419-5237">ORCID</a>`,
+		"Detlef Weigel (Editor)",
+		"Among the most striking aspects",
+		"Sankar, M., &amp; Nieminen, K. (2014). Automated quantitative histology. eLife, 3, e01567.",
+		"Kucsko, G. (2013). Nanometre-scale thermometry in a living cell",
+		"Sankar M, et al. 2013. &lt;Unpublished&gt;.",
+		`<a href="https://creativecommons.org/licenses/by/3.0/legalcode" rel="license">CC-BY-3.0</a>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Write landing page: want %v, got\n%v", w, got)
+		}
+	}
+}
+
+func TestHighwireMetaTags(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		data commonmeta.Data
+		want HighwireMeta
+	}
+	testCases := []testCase{
+		{data: commonmeta.Data{Type: "BookChapter", Container: commonmeta.Container{Title: "Handbook"}}, want: HighwireMeta{"citation_inbook_title", "Handbook"}},
+		{data: commonmeta.Data{Type: "Dissertation", Publisher: commonmeta.Publisher{Name: "ETH Zürich"}}, want: HighwireMeta{"citation_dissertation_institution", "ETH Zürich"}},
+		{data: commonmeta.Data{Date: commonmeta.Date{Published: "2024-05-01T10:00:00Z"}}, want: HighwireMeta{"citation_publication_date", "2024/05/01"}},
+	}
+	for _, tc := range testCases {
+		got := highwireMetaTags(tc.data)
+		found := false
+		for _, m := range got {
+			if m == tc.want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Highwire meta tags(%v): want %v, got %v", tc.data.Type, tc.want, got)
+		}
+	}
+}
@@ -3,28 +3,31 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
-	"regexp"
+	"os"
 	"slices"
 	"strings"
 
+	_ "commonmeta/migrations"
+
 	"github.com/front-matter/commonmeta/commonmeta"
 	"github.com/front-matter/commonmeta/crossref"
-	"github.com/front-matter/commonmeta/crossrefxml"
-	"github.com/front-matter/commonmeta/csl"
 	"github.com/front-matter/commonmeta/datacite"
 	"github.com/front-matter/commonmeta/doiutils"
-	"github.com/front-matter/commonmeta/schemaorg"
 	"github.com/labstack/echo/v5"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/daos"
+	pbmigrations "github.com/pocketbase/pocketbase/migrations"
 	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/migrate"
 	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/spf13/cobra"
 )
 
 // ensures that the Work struct satisfy the models.Model interface
@@ -60,8 +63,9 @@ type Work struct {
 	Version           string        `db:"version" json:"version,omitempty"`
 
 	// database fields
-	Created types.DateTime `db:"created" json:"created"`
-	Updated types.DateTime `db:"updated" json:"updated"`
+	Created  types.DateTime `db:"created" json:"created"`
+	Updated  types.DateTime `db:"updated" json:"updated"`
+	Revision int            `db:"revision" json:"revision"`
 }
 
 func (m *Work) TableName() string {
@@ -85,6 +89,32 @@ func main() {
 		Unstructured    string `json:"unstructured,omitempty"`
 	}
 
+	// validate works against the commonmeta JSON Schema before saving them,
+	// configured with the COMMONMETA_VALIDATION (reject, warn or off) and
+	// COMMONMETA_SCHEMA_VERSION environment variables
+	validationMode, err := ParseValidationMode(os.Getenv("COMMONMETA_VALIDATION"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	schemaVersion := strings.TrimPrefix(os.Getenv("COMMONMETA_SCHEMA_VERSION"), "v")
+	if schemaVersion == "" {
+		schemaVersion = LatestSchemaVersion()
+	} else if !slices.Contains(SchemaVersions(), schemaVersion) {
+		log.Fatalf("unknown schema version %s", schemaVersion)
+	}
+	// tombstoned works are gone for good, deleting a work tombstones its pid
+	app.OnModelBeforeCreate("works").Add(RejectTombstonedHook)
+	app.OnModelAfterDelete("works").Add(TombstoneDeletedHook)
+	app.OnModelBeforeCreate("works").Add(ValidateWorkHook(validationMode, schemaVersion))
+	app.OnModelBeforeUpdate("works").Add(ValidateWorkHook(validationMode, schemaVersion))
+	app.OnModelAfterCreate("works").Add(RecordViolationsHook(validationMode, schemaVersion))
+	app.OnModelAfterUpdate("works").Add(RecordViolationsHook(validationMode, schemaVersion))
+	// optimistic concurrency, updates must start from the stored revision
+	app.OnModelBeforeUpdate("works").Add(CheckRevisionHook)
+
+	// bulk import of commonmeta files
+	app.RootCmd.AddCommand(exitOnError(NewImportCommand(app, validationMode, schemaVersion)))
+
 	// redirect hard-coded legacy urls to docs site
 	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
 		e.Router.GET("/", func(c echo.Context) error {
@@ -112,25 +142,52 @@ func main() {
 		return nil
 	})
 
+	// list the formats available from the resolver
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		e.Router.GET("/formats", func(c echo.Context) error {
+			return c.JSON(http.StatusOK, Writers.Writers())
+		})
+		return nil
+	})
+
+	// convert metadata posted in one format to another, without storing it
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		e.Router.POST("/convert", Convert)
+		return nil
+	})
+
+	// validate commonmeta documents against the JSON Schema
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		e.Router.POST("/validate", Validate)
+		return nil
+	})
+	app.RootCmd.AddCommand(exitOnError(NewValidateCommand()))
+
+	// write API for partner systems, authenticated with API keys, auth tokens
+	// of users with prefixes, or as admin
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		api := &WorksAPI{Dao: app.Dao(), Version: schemaVersion}
+		auth := RequireWriteAuth(app.Dao())
+		e.Router.POST("/works", api.Create, auth)
+		e.Router.PUT("/:str", api.Replace, auth)
+		e.Router.PATCH("/:str", api.Patch, auth)
+		e.Router.DELETE("/:str", api.Delete, auth)
+		return nil
+	})
+	app.OnRecordBeforeCreateRequest("users").Add(ProtectUserPrefixesOnCreate)
+	app.OnRecordBeforeUpdateRequest("users").Add(ProtectUserPrefixesOnUpdate)
+	app.RootCmd.AddCommand(exitOnError(NewApiKeyCommand(app)))
+
 	// retrieve a single works collection record and either redirect to its url
 	// or return metadata depending on the Accept header
 	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
-		e.Router.GET("/:str", func(c echo.Context) error {
+		resolve := func(c echo.Context) error {
 			// fetch the pid
 			str := c.PathParam("str")
 			if str == "" {
 				return c.NoContent(http.StatusNotFound)
 			}
-			isDoi, err := regexp.MatchString(`10\.\d{4,9}/.+`, str)
-			if err != nil {
-				return err
-			}
-			var pid string
-			if isDoi {
-				pid = fmt.Sprintf("https://doi.org/%s", str)
-			} else {
-				pid = fmt.Sprintf("https://%s", str)
-			}
+			pid, isDoi := pidFromPath(str)
 
 			// check if the pid is a valid URL
 			u, err := url.ParseRequestURI(pid)
@@ -155,64 +212,120 @@ func main() {
 				contentType = strings.Join(path[1:3], "/")
 			}
 
-			// alternatively extract the content type from the Accept header
-			if contentType == "" {
-				acceptHeaders := c.Request().Header.Get("Accept")
-				contentType = strings.Split(acceptHeaders, ",")[0]
+			// alternatively select the format with the format query parameter,
+			// by short name or media type
+			if format := c.QueryParam("format"); contentType == "" && format != "" {
+				w, ok := Writers.ByName(format)
+				if !ok {
+					w, ok = Writers.Lookup(format)
+				}
+				if !ok {
+					return notAcceptable(c, fmt.Sprintf("Format %s not supported", format))
+				}
+				contentType = w.MediaType
 			}
-			if contentType == "" || contentType == "*/*" {
-				contentType = "text/html"
+
+			// or with a file extension such as .bib. As pids may contain dots,
+			// the full string is tried first, then the pid without the extension.
+			type candidate struct {
+				pid         string
+				str         string
+				contentType string
 			}
-			work, err := FindWorkByPid(app.Dao(), pid)
-			if err != nil {
-				return err
+			candidates := []candidate{{pid: pid, str: str, contentType: contentType}}
+			if contentType == "" {
+				if base, w, ok := Writers.SplitExtension(str); ok {
+					candidates = append(candidates, candidate{
+						pid:         strings.TrimSuffix(pid, str) + base,
+						str:         base,
+						contentType: w.MediaType,
+					})
+				}
 			}
 
-			// create a new work record if not found and the pid is a Crossref DOI
-			if work == nil {
-				ra, err := FindDoiRegistrationAgency(app.Dao(), pid)
+			// look up the work in the works collection, or fetch it from Crossref
+			// or DataCite and store it. Tombstoned pids are never fetched.
+			var work *Work
+			selected := candidates[0]
+			for _, cand := range candidates {
+				tombstone, err := FindTombstoneByPid(app.Dao(), cand.pid)
 				if err != nil {
 					return err
+				} else if tombstone != nil {
+					return ServeTombstone(c, tombstone)
 				}
-				if isDoi && ra == "Crossref" {
-					log.Printf("%s not found, looking up metadata with Crossref ...", pid)
-					data, err := crossref.Fetch(pid)
-
-					if err != nil {
-						return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
-					}
-					newWork := GetWorkFromCommonmeta(data)
-					if err := app.Dao().Save(newWork); err != nil {
-						return err
-					}
-					work, err = FindWorkByPid(app.Dao(), newWork.Pid)
-					if err != nil {
-						return err
-					}
-				} else if isDoi && ra == "DataCite" {
-					log.Printf("%s not found, looking up metadata with DataCite ...", pid)
-					data, err := datacite.Fetch(pid)
-					if err != nil {
-						return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
-					}
-					newWork := GetWorkFromCommonmeta(data)
-					log.Printf("New work: %+v\n", newWork)
-					if err := app.Dao().Save(newWork); err != nil {
+				work, err = FindWorkByPid(app.Dao(), cand.pid)
+				if err != nil {
+					return err
+				}
+				if work != nil {
+					selected = cand
+					break
+				}
+			}
+			if work == nil && isDoi {
+				var fetchErr error
+				for _, cand := range candidates {
+					work, err = FetchWork(app.Dao(), cand.pid)
+					var ferr *FetchError
+					var verr *InvalidWorkError
+					if errors.As(err, &ferr) {
+						fetchErr = ferr
+						continue
+					} else if errors.As(err, &verr) {
+						return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+							"error":  verr.Error(),
+							"errors": verr.Errors,
+						})
+					} else if err != nil {
 						return err
 					}
-					work, err = FindWorkByPid(app.Dao(), newWork.Pid)
-					if err != nil {
-						return err
+					if work != nil {
+						selected = cand
+						break
 					}
 				}
+				if work == nil && fetchErr != nil {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": fetchErr.Error()})
+				}
+			}
+			pid, str, contentType = selected.pid, selected.str, selected.contentType
+
+			// alternatively negotiate the content type from the Accept header,
+			// keeping media type parameters such as the citation style
+			params := map[string]string{}
+			if contentType == "" {
+				c.Response().Header().Add("Vary", "Accept")
+				accept := c.Request().Header.Get("Accept")
+				if strings.TrimSpace(accept) == "" {
+					contentType = "text/html"
+				} else if offer, m, ok := NegotiateContentType(accept, Writers.MediaTypes()); ok {
+					contentType = offer
+					params = m.Params
+				} else if m, ok := PreferredMediaType(accept); ok {
+					// not produced here, but maybe via Crossref or DataCite content negotiation
+					contentType = m.MediaType()
+				} else {
+					return notAcceptable(c, fmt.Sprintf("No acceptable Content-Type in %s", accept))
+				}
 			}
 			if work == nil {
 				return c.JSON(http.StatusNotFound, map[string]string{"error": "Not found"})
 			}
+			data, err := WriteWorkToCommonmeta(work)
+			if err != nil {
+				log.Println("error:", err)
+			}
+
+			// FAIR Signposting links on all responses for the work
+			baseURL := c.Scheme() + "://" + c.Request().Host
+			for _, s := range Signposts(data, baseURL) {
+				c.Response().Header().Add("Link", s.String())
+			}
 
 			// redirect for content types supported by Crossref or DataCite DOI content negotiation
-			contentTypes := []string{"text/html", "application/vnd.commonmeta+json", "application/json", "application/vnd.datacite.datacite+json", "application/vnd.citationstyles.csl+json", "application/vnd.crossref.unixsd+xml", "application/vnd.schemaorg.ld+json", "text/markdown", "application/vnd.jats+xml", "application/xml", "application/pdf"}
-			if !slices.Contains(contentTypes, contentType) {
+			writer, ok := Writers.Lookup(contentType)
+			if !ok || writer.Delivery == Proxy {
 				// look up the DOI registration agency in works table and use link-based content negotiation
 				ra, err := FindDoiRegistrationAgency(app.Dao(), pid)
 				if err != nil {
@@ -224,22 +337,21 @@ func main() {
 				case "DataCite":
 					return c.Redirect(http.StatusFound, fmt.Sprintf("https://data.crosscite.org/%s/%s", contentType, str))
 				default:
-					return c.JSON(http.StatusNotFound, map[string]string{"error": "Work not found and content negotiation not supported"})
+					return notAcceptable(c, fmt.Sprintf("Content-Type %s not supported", contentType))
 				}
 			}
-
-			if contentType == "text/html" {
-				// redirect to resource if work found
-				return c.Redirect(http.StatusFound, work.Url)
+			if !writer.Supports(work.Type) {
+				return notAcceptable(c, fmt.Sprintf("Content-Type %s only supported for %s", contentType, strings.Join(writer.Types, ", ")))
 			}
 
-			// extract pids of references and look up their metadata
+			// extract pids of references and look up their metadata, only
+			// needed when generating metadata locally
 			var r []Reference
 			err = json.Unmarshal(work.References, &r)
 			if err != nil {
 				return err
 			}
-			if len(r) > 0 {
+			if writer.Delivery == Local && len(r) > 0 {
 				// generate a list of pid strings
 				refs := make([]string, 0)
 				for _, v := range r {
@@ -256,6 +368,9 @@ func main() {
 					if err != nil {
 						return err
 					}
+					if err := json.Unmarshal(work.References, &data.References); err != nil {
+						log.Println("error:", err)
+					}
 					// if err := app.Dao().Save(work); err != nil {
 					// 	return err
 					// }
@@ -282,76 +397,22 @@ func main() {
 			for _, v := range f {
 				files[v.MimeType] = v.Url
 			}
-			var markdownUrl, pdfUrl, jatsUrl string
-			markdownUrl = files["text/markdown"]
-			pdfUrl = files["application/pdf"]
-			jatsUrl = files["application/xml"]
-			if jatsUrl == "" {
-				jatsUrl = files["application/vnd.jats+xml"]
-			}
 
-			var data commonmeta.Data
-			if slices.Contains([]string{"application/vnd.commonmeta+json", "application/json", "application/vnd.datacite.datacite+json", "application/vnd.citationstyles.csl+json", "application/vnd.schemaorg.ld+json", "application/vnd.crossref.unixsd+xml"}, contentType) {
-				data, err = WriteWorkToCommonmeta(work)
-				if err != nil {
-					log.Println("error:", err)
-				}
+			// query parameters take precedence over media type parameters
+			for k, v := range c.QueryParams() {
+				params[k] = v[0]
 			}
-			switch contentType {
-			case "application/vnd.commonmeta+json", "application/json":
-				// return metadata in Commonmeta format
-				return c.JSON(http.StatusOK, work)
-			case "application/vnd.crossref.unixsd+xml":
-				// return metadata in Crossref UNIXREF xml format
-				out, err := crossrefxml.Convert(data)
-				if err != nil {
-					log.Println("error:", err)
-				}
-				return c.XML(http.StatusOK, out)
-			case "application/vnd.datacite.datacite+json":
-				// return metadata in Datacite format
-				out, err := datacite.Convert(data)
-				if err != nil {
-					log.Println("error:", err)
-				}
-				return c.JSON(http.StatusOK, out)
-			case "application/vnd.citationstyles.csl+json":
-				// return metadata in CSL format
-				out, err := csl.Convert(data)
-				if err != nil {
-					log.Println("error:", err)
-				}
-				return c.JSON(http.StatusOK, out)
-			case "application/vnd.schemaorg.ld+json":
-				// return metadata in SchemaOrg format
-				out, err := schemaorg.Convert(data)
-				if err != nil {
-					log.Println("error:", err)
-				}
-				return c.JSON(http.StatusOK, out)
-			case "text/markdown":
-				// redirect to markdown version of the resource if available
-				if markdownUrl == "" {
-					return c.JSON(http.StatusNotAcceptable, map[string]string{"error": "Markdown version not available"})
-				}
-				return c.Redirect(http.StatusFound, markdownUrl)
-			case "application/vnd.jats+xml", "application/xml":
-				// redirect to JATS XML version of the resource if available
-				if jatsUrl == "" {
-					return c.JSON(http.StatusNotAcceptable, map[string]string{"error": "JATS XML version not available"})
-				}
-				return c.Redirect(http.StatusFound, jatsUrl)
-			case "application/pdf":
-				// redirect to PDF version of the resource if available
-				if pdfUrl == "" {
-					return c.JSON(http.StatusNotAcceptable, map[string]string{"error": "PDF version not available"})
-				}
-				return c.Redirect(http.StatusFound, pdfUrl)
-			default:
-				// all other Content-Types not (yet) supported
-				return c.JSON(http.StatusNotAcceptable, map[string]string{"error": fmt.Sprintf("Content-Type %s not supported", contentType)})
-			}
-		})
+			return ServeWriter(c, writer, &WriteRequest{
+				Work:      work,
+				Data:      data,
+				MediaType: contentType,
+				Params:    params,
+				Files:     files,
+				BaseURL:   baseURL,
+			})
+		}
+		e.Router.GET("/:str", resolve)
+		e.Router.HEAD("/:str", resolve)
 
 		return nil
 	})
@@ -361,6 +422,45 @@ func main() {
 	}
 }
 
+// FetchError is returned when the metadata of a DOI can't be fetched from
+// its registration agency
+type FetchError struct {
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return e.Err.Error()
+}
+
+// FetchWork looks up the metadata of a Crossref or DataCite DOI with its
+// registration agency and stores it in the works collection. It returns nil
+// for DOIs registered with other agencies.
+func FetchWork(dao *daos.Dao, pid string) (*Work, error) {
+	ra, err := FindDoiRegistrationAgency(dao, pid)
+	if err != nil {
+		return nil, err
+	}
+	var data commonmeta.Data
+	switch ra {
+	case "Crossref":
+		log.Printf("%s not found, looking up metadata with Crossref ...", pid)
+		data, err = crossref.Fetch(pid)
+	case "DataCite":
+		log.Printf("%s not found, looking up metadata with DataCite ...", pid)
+		data, err = datacite.Fetch(pid)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &FetchError{Err: err}
+	}
+	newWork := GetWorkFromCommonmeta(data)
+	if err := dao.Save(newWork); err != nil {
+		return nil, err
+	}
+	return FindWorkByPid(dao, newWork.Pid)
+}
+
 // GetWorkFromCommonmeta returns a Work struct from a commonmeta.Data struct
 func GetWorkFromCommonmeta(data commonmeta.Data) *Work {
 	work := &Work{
@@ -425,6 +525,43 @@ func WriteWorkToCommonmeta(w *Work) (commonmeta.Data, error) {
 	return data, nil
 }
 
+// notAcceptable returns a 406 response listing the available content types
+func notAcceptable(c echo.Context, message string) error {
+	return c.JSON(http.StatusNotAcceptable, map[string]interface{}{
+		"error":     message,
+		"available": Writers.MediaTypes(),
+	})
+}
+
+// RunMigrations applies the pending migrations, as the serve command does, for
+// the commands that use the database
+func RunMigrations(app core.App) error {
+	runner, err := migrate.NewRunner(app.DB(), pbmigrations.AppMigrations)
+	if err != nil {
+		return err
+	}
+	_, err = runner.Up()
+	return err
+}
+
+// exitOnError makes a command and its subcommands exit with status 1 when
+// they fail, as PocketBase ignores the errors returned by commands
+func exitOnError(cmd *cobra.Command) *cobra.Command {
+	if runE := cmd.RunE; runE != nil {
+		cmd.RunE = func(c *cobra.Command, args []string) error {
+			if err := runE(c, args); err != nil {
+				c.PrintErrln("Error:", err)
+				os.Exit(1)
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		exitOnError(sub)
+	}
+	return cmd
+}
+
 func marshalSlice(data interface{}) types.JsonRaw {
 	b, err := json.Marshal(data)
 	if err != nil {
@@ -514,14 +651,21 @@ func FindWorksByCitation(dao *daos.Dao, pid string) ([]*Work, error) {
 	return works, nil
 }
 
-// find DOI registration agency from works collection
+// find DOI registration agency from prefixes or works collection
 func FindDoiRegistrationAgency(dao *daos.Dao, doi string) (string, error) {
 	prefix, ok := doiutils.ValidatePrefix(doi)
 	if !ok {
 		return "", fmt.Errorf("invalid DOI")
 	}
+	// prefixes are populated by the imports of data dumps
+	p, err := FindPrefix(dao, prefix)
+	if err != nil {
+		return "", err
+	} else if p != nil {
+		return p.Ra, nil
+	}
 	work := &Work{}
-	err := WorkQuery(dao).
+	err = WorkQuery(dao).
 		AndWhere(dbx.NewExp("pid LIKE {:substr}", dbx.Params{
 			"substr": "https://doi.org/" + prefix + "%",
 		})).
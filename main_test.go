@@ -2,6 +2,8 @@ package main_test
 
 import (
 	"testing"
+
+	"github.com/front-matter/commonmeta/dateutils"
 )
 
 // func TestGetDateFromDateParts(t *testing.T) {
@@ -37,15 +39,15 @@ func TestGetDateFromParts(t *testing.T) {
 	}
 
 	testCases := []testCase{
-		{got: [2012, 1, 1], want: "2012-01-01", err: nil},
-		{got: [2012, 1], want: "2012-01", err: nil},
-		{got: [2012], want: "2012", err: nil},
+		{got: []int{2012, 1, 1}, want: "2012-01-01", err: nil},
+		{got: []int{2012, 1}, want: "2012-01", err: nil},
+		{got: []int{2012}, want: "2012", err: nil},
 	}
 	for _, tc := range testCases {
-		got, err := dateutils.GetDateFromParts(tc.got)
-		if tc.want != got{
-			t.Errorf("Get date from date parts(%v): want %v, error %v",
-				tc.got, tc.want, err)
+		got := dateutils.GetDateFromParts(tc.got...)
+		if tc.want != got {
+			t.Errorf("Get date from date parts(%v): want %v, got %v",
+				tc.got, tc.want, got)
 		}
 	}
 }
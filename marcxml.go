@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/xml"
+	"slices"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+// MARCRelator is a term and code from the MARC relator list
+type MARCRelator struct {
+	Term string
+	Code string
+}
+
+// CMToMARCRelators maps commonmeta contributor roles to MARC relators,
+// used by both MODS and MARCXML. Roles not listed are contributors.
+var CMToMARCRelators = map[string]MARCRelator{
+	"Author":             {"author", "aut"},
+	"ContactPerson":      {"contact person", "ctp"},
+	"DataCuration":       {"data curator", "cur"},
+	"DataManager":        {"data manager", "dtm"},
+	"Distributor":        {"distributor", "dst"},
+	"Editor":             {"editor", "edt"},
+	"HostingInstitution": {"host institution", "his"},
+	"Producer":           {"producer", "pro"},
+	"ProjectLeader":      {"project director", "pdr"},
+	"Researcher":         {"researcher", "res"},
+	"Reviewer":           {"reviewer", "rev"},
+	"RightsHolder":       {"copyright holder", "cph"},
+	"Software":           {"programmer", "prg"},
+	"Sponsor":            {"sponsor", "spn"},
+	"Supervision":        {"thesis advisor", "ths"},
+	"Translator":         {"translator", "trl"},
+}
+
+// marcRelator returns the MARC relator for a commonmeta contributor role
+func marcRelator(role string) MARCRelator {
+	if r, ok := CMToMARCRelators[role]; ok {
+		return r
+	}
+	return MARCRelator{"contributor", "ctb"}
+}
+
+// ISO639ToMARCLanguages maps two-letter language codes to the three-letter
+// codes of the MARC code list for languages
+var ISO639ToMARCLanguages = map[string]string{
+	"ar": "ara",
+	"da": "dan",
+	"de": "ger",
+	"en": "eng",
+	"es": "spa",
+	"fi": "fin",
+	"fr": "fre",
+	"it": "ita",
+	"ja": "jpn",
+	"ko": "kor",
+	"nl": "dut",
+	"no": "nor",
+	"pl": "pol",
+	"pt": "por",
+	"ru": "rus",
+	"sv": "swe",
+	"tr": "tur",
+	"uk": "ukr",
+	"zh": "chi",
+}
+
+// marcLanguage returns the MARC language code for a language tag, or an empty string
+func marcLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if len(lang) == 3 {
+		return lang
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	return ISO639ToMARCLanguages[base]
+}
+
+// MARCRecord represents a bibliographic record in MARC 21 XML
+type MARCRecord struct {
+	XMLName        xml.Name           `xml:"record"`
+	Xmlns          string             `xml:"xmlns,attr"`
+	XmlnsXSI       string             `xml:"xmlns:xsi,attr"`
+	SchemaLocation string             `xml:"xsi:schemaLocation,attr"`
+	Leader         string             `xml:"leader"`
+	ControlFields  []MARCControlField `xml:"controlfield"`
+	DataFields     []MARCDataField    `xml:"datafield"`
+}
+
+// MARCControlField is a MARC control field (001-009)
+type MARCControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+// MARCDataField is a MARC data field with indicators and subfields
+type MARCDataField struct {
+	Tag       string         `xml:"tag,attr"`
+	Ind1      string         `xml:"ind1,attr"`
+	Ind2      string         `xml:"ind2,attr"`
+	Subfields []MARCSubfield `xml:"subfield"`
+}
+
+// MARCSubfield is a subfield of a MARC data field
+type MARCSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// marcField returns a data field, skipping subfields without a value.
+// Subfields are given as pairs of code and value.
+func marcField(tag string, ind1 string, ind2 string, subfields ...string) MARCDataField {
+	f := MARCDataField{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for i := 0; i+1 < len(subfields); i += 2 {
+		if subfields[i+1] != "" {
+			f.Subfields = append(f.Subfields, MARCSubfield{Code: subfields[i], Value: subfields[i+1]})
+		}
+	}
+	return f
+}
+
+// marcLeader returns the record leader, with type of record and bibliographic
+// level derived from the commonmeta type
+func marcLeader(data commonmeta.Data) string {
+	recordType := "a"
+	switch data.Type {
+	case "Dataset", "Software", "ComputationalNotebook":
+		recordType = "m"
+	case "Image", "Figure":
+		recordType = "k"
+	case "Audiovisual":
+		recordType = "g"
+	case "Sound":
+		recordType = "i"
+	case "PhysicalObject":
+		recordType = "r"
+	case "Collection":
+		recordType = "p"
+	}
+	level := "m"
+	switch data.Type {
+	case "JournalArticle", "BookChapter", "ProceedingsArticle", "Article", "BlogPost", "Entry", "Review":
+		level = "a"
+	case "Journal", "Blog", "Proceedings":
+		level = "s"
+	case "Collection":
+		level = "c"
+	}
+	return "00000n" + recordType + level + " a2200000uu 4500"
+}
+
+// WriteMARCXML converts commonmeta metadata to a MARC 21 bibliographic record
+// in MARCXML.
+func WriteMARCXML(data commonmeta.Data) ([]byte, error) {
+	if data.ID == "" {
+		return nil, invalidWork("missing id")
+	}
+	r := MARCRecord{
+		Xmlns:          "http://www.loc.gov/MARC21/slim",
+		XmlnsXSI:       xsiNS,
+		SchemaLocation: "http://www.loc.gov/MARC21/slim http://www.loc.gov/standards/marcxml/schema/MARC21slim.xsd",
+		Leader:         marcLeader(data),
+	}
+
+	// 008 fixed-length data elements: date type, year, unknown place, language
+	year := publicationYear(data)
+	dateType := "s"
+	if year == "" {
+		year = "uuuu"
+		dateType = "n"
+	}
+	lang := marcLanguage(data.Language)
+	if lang == "" {
+		lang = "und"
+	}
+	r.ControlFields = []MARCControlField{
+		{Tag: "001", Value: data.ID},
+		{Tag: "008", Value: "||||||" + dateType + year + "    " + "xx " + strings.Repeat("|", 17) + lang + " d"},
+	}
+
+	fields := make([]MARCDataField, 0)
+	if doi := doiFromPid(data.ID); doi != "" {
+		fields = append(fields, marcField("024", "7", " ", "a", doi, "2", "doi"))
+	} else {
+		fields = append(fields, marcField("024", "7", " ", "a", data.ID, "2", "uri"))
+	}
+	for _, i := range data.Identifiers {
+		if i.Identifier != "" && i.Identifier != data.ID {
+			fields = append(fields, marcField("024", "7", " ", "a", i.Identifier, "2", strings.ToLower(i.IdentifierType)))
+		}
+	}
+	if l := marcLanguage(data.Language); l != "" {
+		fields = append(fields, marcField("041", " ", " ", "a", l))
+	}
+
+	// the first author is the main entry, all other contributors are added entries
+	hasMainEntry := false
+	for _, c := range data.Contributors {
+		subfields := []string{"a", contributorName(c)}
+		for _, role := range c.ContributorRoles {
+			relator := marcRelator(role)
+			subfields = append(subfields, "e", relator.Term, "4", relator.Code)
+		}
+		for _, a := range c.Affiliations {
+			if a != nil {
+				subfields = append(subfields, "u", a.Name)
+			}
+		}
+		if isIRI(c.ID) {
+			subfields = append(subfields, "1", c.ID)
+		}
+		tag, ind1 := "00", "1"
+		if c.Type == "Organization" || c.FamilyName == "" {
+			tag, ind1 = "10", "2"
+		}
+		if !hasMainEntry && slices.Contains(c.ContributorRoles, "Author") {
+			hasMainEntry = true
+			fields = append(fields, marcField("1"+tag, ind1, " ", subfields...))
+		} else {
+			fields = append(fields, marcField("7"+tag, ind1, " ", subfields...))
+		}
+	}
+
+	titleInd1 := "0"
+	if hasMainEntry {
+		titleInd1 = "1"
+	}
+	subtitle := ""
+	for _, t := range data.Titles {
+		if t.Type == "Subtitle" {
+			subtitle = stripTags(t.Title)
+		}
+	}
+	fields = append(fields, marcField("245", titleInd1, "0", "a", stripTags(mainTitle(data)), "b", subtitle))
+	for _, t := range data.Titles {
+		if t.Type == "AlternativeTitle" || t.Type == "TranslatedTitle" {
+			fields = append(fields, marcField("246", "1", " ", "a", stripTags(t.Title)))
+		}
+	}
+	if data.Version != "" {
+		fields = append(fields, marcField("250", " ", " ", "a", data.Version))
+	}
+	if data.Publisher.Name != "" || publicationDate(data) != "" {
+		fields = append(fields, marcField("264", " ", "1", "b", data.Publisher.Name, "c", publicationDate(data)))
+	}
+	if a := abstract(data); a != "" {
+		fields = append(fields, marcField("520", "3", " ", "a", stripTags(a)))
+	}
+	if data.License.ID != "" || data.License.URL != "" {
+		fields = append(fields, marcField("540", " ", " ", "f", data.License.ID, "u", licenseURL(data)))
+	}
+	for _, k := range keywords(data) {
+		fields = append(fields, marcField("653", " ", " ", "a", k))
+	}
+	if data.Container.Title != "" {
+		related := []string{"t", stripTags(data.Container.Title)}
+		var parts []string
+		if data.Container.Volume != "" {
+			parts = append(parts, "Vol. "+data.Container.Volume)
+		}
+		if data.Container.Issue != "" {
+			parts = append(parts, "no. "+data.Container.Issue)
+		}
+		if data.Container.FirstPage != "" {
+			pages := data.Container.FirstPage
+			if data.Container.LastPage != "" {
+				pages += "-" + data.Container.LastPage
+			}
+			parts = append(parts, "p. "+pages)
+		}
+		related = append(related, "g", strings.Join(parts, ", "))
+		if data.Container.IdentifierType == "ISSN" {
+			related = append(related, "x", data.Container.Identifier)
+		} else if data.Container.IdentifierType == "ISBN" {
+			related = append(related, "z", data.Container.Identifier)
+		}
+		fields = append(fields, marcField("773", "0", " ", related...))
+	}
+	for _, rel := range data.Relations {
+		if rel.ID != "" {
+			fields = append(fields, marcField("787", "0", "8", "i", rel.Type, "o", rel.ID))
+		}
+	}
+	if data.URL != "" {
+		fields = append(fields, marcField("856", "4", "0", "u", data.URL))
+	}
+	if doi := doiFromPid(data.ID); doi != "" && data.ID != data.URL {
+		fields = append(fields, marcField("856", "4", "0", "u", data.ID, "z", "DOI"))
+	}
+
+	// data fields must be in tag order
+	slices.SortStableFunc(fields, func(a, b MARCDataField) int {
+		return strings.Compare(a.Tag, b.Tag)
+	})
+	r.DataFields = fields
+
+	out, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
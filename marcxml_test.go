@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+var catalogData = commonmeta.Data{
+	ID:   "https://doi.org/10.7554/elife.01567",
+	Type: "JournalArticle",
+	URL:  "https://elifesciences.org/articles/01567",
+	Contributors: []commonmeta.Contributor{
+		{ID: "https://orcid.org/0000-0002-8419-5237", Type: "Person", GivenName: "Martial", FamilyName: "Sankar", ContributorRoles: []string{"Author"}, Affiliations: []*commonmeta.Affiliation{{Name: "University of Lausanne"}}},
+		{Type: "Person", GivenName: "Kaisa", FamilyName: "Nieminen", ContributorRoles: []string{"Author"}},
+		{Type: "Person", GivenName: "Detlef", FamilyName: "Weigel", ContributorRoles: []string{"Editor"}},
+	},
+	Titles:       []commonmeta.Title{{Title: "Automated quantitative histology"}},
+	Container:    commonmeta.Container{Title: "eLife", Identifier: "2050-084X", IdentifierType: "ISSN", Volume: "3", FirstPage: "e01567"},
+	Publisher:    commonmeta.Publisher{Name: "eLife Sciences Publications, Ltd"},
+	Date:         commonmeta.Date{Published: "2014-02-11"},
+	Language:     "en",
+	Subjects:     []commonmeta.Subject{{Subject: "Plant Biology"}},
+	Descriptions: []commonmeta.Description{{Description: "Among the most striking aspects", Type: "Abstract"}},
+	License:      commonmeta.License{ID: "CC-BY-3.0", URL: "https://creativecommons.org/licenses/by/3.0/legalcode"},
+}
+
+func TestWriteMARCXML(t *testing.T) {
+	t.Parallel()
+
+	out, err := WriteMARCXML(catalogData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	want := []string{
+		"<leader>00000naa a2200000uu 4500</leader>",
+		`<controlfield tag="008">||||||s2014    xx |||||||||||||||||eng d</controlfield>`,
+		`<datafield tag="024" ind1="7" ind2=" ">
+    <subfield code="a">10.7554/elife.01567</subfield>
+    <subfield code="2">doi</subfield>`,
+		`<datafield tag="100" ind1="1" ind2=" ">
+    <subfield code="a">Sankar, Martial</subfield>
+    <subfield code="e">author</subfield>
+    <subfield code="4">aut</subfield>
+    <subfield code="u">University of Lausanne</subfield>
+    <subfield code="1">https://orcid.org/0000-0002-8419-5237</subfield>`,
+		`<datafield tag="245" ind1="1" ind2="0">
+    <subfield code="a">Automated quantitative histology</subfield>`,
+		`<subfield code="b">eLife Sciences Publications, Ltd</subfield>
+    <subfield code="c">2014-02-11</subfield>`,
+		`<subfield code="u">https://creativecommons.org/licenses/by/3.0/legalcode</subfield>`,
+		`<datafield tag="653" ind1=" " ind2=" ">
+    <subfield code="a">Plant Biology</subfield>`,
+		`<subfield code="a">Weigel, Detlef</subfield>
+    <subfield code="e">editor</subfield>
+    <subfield code="4">edt</subfield>`,
+		`<subfield code="g">Vol. 3, p. e01567</subfield>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Write MARCXML: want %v, got\n%v", w, got)
+		}
+	}
+
+	// data fields are in tag order
+	tags := []string{`tag="024"`, `tag="100"`, `tag="245"`, `tag="264"`, `tag="653"`, `tag="700"`, `tag="773"`, `tag="856"`}
+	last := 0
+	for _, tag := range tags {
+		i := strings.Index(got, tag)
+		if i < last {
+			t.Errorf("Write MARCXML: want %v after position %v, got %v", tag, last, i)
+		}
+		last = i
+	}
+}
+
+func TestMARCLeader(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input string
+		want  string
+	}
+	testCases := []testCase{
+		{input: "JournalArticle", want: "00000naa a2200000uu 4500"},
+		{input: "Book", want: "00000nam a2200000uu 4500"},
+		{input: "Dataset", want: "00000nmm a2200000uu 4500"},
+		{input: "Journal", want: "00000nas a2200000uu 4500"},
+	}
+	for _, tc := range testCases {
+		got := marcLeader(commonmeta.Data{Type: tc.input})
+		if tc.want != got {
+			t.Errorf("MARC leader(%v): want %v, got %v", tc.input, tc.want, got)
+		}
+		if len(got) != 24 {
+			t.Errorf("MARC leader(%v): want 24 characters, got %v", tc.input, len(got))
+		}
+	}
+}
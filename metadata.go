@@ -0,0 +1,250 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/front-matter/commonmeta/authorutils"
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/dateutils"
+	"github.com/front-matter/commonmeta/doiutils"
+	"github.com/front-matter/commonmeta/utils"
+)
+
+// helper functions shared by the metadata writers
+
+// mainTitle returns the main title of a work, ignoring subtitles and translated titles
+func mainTitle(data commonmeta.Data) string {
+	for _, t := range data.Titles {
+		if t.Type == "" {
+			return t.Title
+		}
+	}
+	if len(data.Titles) > 0 {
+		return data.Titles[0].Title
+	}
+	return ""
+}
+
+// authors returns the contributors with the Author role
+func authors(data commonmeta.Data) []commonmeta.Contributor {
+	a := make([]commonmeta.Contributor, 0)
+	for _, c := range data.Contributors {
+		if slices.Contains(c.ContributorRoles, "Author") {
+			a = append(a, c)
+		}
+	}
+	return a
+}
+
+// publicationDate returns the date a work was published, falling back to
+// the date it was made available or created
+func publicationDate(data commonmeta.Data) string {
+	for _, d := range []string{data.Date.Published, data.Date.Available, data.Date.Created} {
+		if d != "" {
+			return d
+		}
+	}
+	return ""
+}
+
+// publicationYear returns the year a work was published
+func publicationYear(data commonmeta.Data) string {
+	d := publicationDate(data)
+	if len(d) < 4 {
+		return ""
+	}
+	return d[:4]
+}
+
+// abstract returns the abstract of a work, or the first description
+func abstract(data commonmeta.Data) string {
+	for _, d := range data.Descriptions {
+		if d.Type == "Abstract" {
+			return d.Description
+		}
+	}
+	if len(data.Descriptions) > 0 {
+		return data.Descriptions[0].Description
+	}
+	return ""
+}
+
+// keywords returns the subjects of a work as a list of strings
+func keywords(data commonmeta.Data) []string {
+	k := make([]string, 0)
+	for _, s := range data.Subjects {
+		if s.Subject != "" {
+			k = append(k, s.Subject)
+		}
+	}
+	return k
+}
+
+// doiFromPid returns the DOI of a work without resolver, or an empty string
+// if the pid is not a DOI
+func doiFromPid(pid string) string {
+	doi, ok := doiutils.ValidateDOI(pid)
+	if !ok {
+		return ""
+	}
+	return doi
+}
+
+// code hosting platforms recognized as source code repositories
+var codeRepositoryHosts = []string{"github.com", "gitlab.com", "bitbucket.org", "codeberg.org", "git.sr.ht"}
+
+// codeRepository returns the source code repository of a software work: its
+// URL or a supplemented repository URL, as used e.g. by Zenodo GitHub releases
+func codeRepository(data commonmeta.Data) string {
+	if isCodeRepository(data.URL) {
+		return data.URL
+	}
+	for _, r := range data.Relations {
+		if r.Type == "IsSupplementTo" && isCodeRepository(r.ID) {
+			return r.ID
+		}
+	}
+	return ""
+}
+
+// isCodeRepository checks whether a URL points to a code hosting platform
+func isCodeRepository(str string) bool {
+	u, err := url.Parse(str)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(codeRepositoryHosts, strings.TrimPrefix(u.Host, "www."))
+}
+
+// licenseURL returns the URL of the license of a work, using the SPDX
+// license list if only the SPDX identifier is known
+func licenseURL(data commonmeta.Data) string {
+	if data.License.URL != "" {
+		return data.License.URL
+	}
+	if data.License.ID != "" {
+		return "https://spdx.org/licenses/" + data.License.ID
+	}
+	return ""
+}
+
+// contributorName returns the name of a contributor in inverted form for
+// persons, e.g. "Fenner, Martin"
+func contributorName(c commonmeta.Contributor) string {
+	if c.FamilyName == "" {
+		return c.Name
+	}
+	if c.GivenName == "" {
+		return c.FamilyName
+	}
+	return c.FamilyName + ", " + c.GivenName
+}
+
+// helper functions shared by the readers of reference manager formats
+
+// workPid returns the pid of a work read from a reference manager: the DOI
+// as URL, or a pid derived from the URL of works without DOI
+func workPid(doi string, str string) string {
+	if pid := doiutils.NormalizeDOI(strings.TrimSpace(doi)); pid != "" {
+		return pid
+	}
+	if pid := doiutils.NormalizeDOI(strings.TrimSpace(str)); pid != "" {
+		return pid
+	}
+	return pidFromURL(str)
+}
+
+// pidFromURL returns a stable pid for a URL, with https scheme, lowercase
+// host and without fragment or trailing slash, as the resolver serves
+// non-DOI pids with https
+func pidFromURL(str string) string {
+	u, err := url.Parse(strings.TrimSpace(str))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	u.Scheme = "https"
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment, u.RawFragment = "", ""
+	u.Path, u.RawPath = strings.TrimSuffix(u.Path, "/"), strings.TrimSuffix(u.RawPath, "/")
+	return u.String()
+}
+
+// pageRange splits a page range such as 123--130 into first and last page
+func pageRange(pages string) (string, string) {
+	pages = strings.NewReplacer("–", "-", "—", "-").Replace(strings.TrimSpace(pages))
+	first, last, _ := strings.Cut(pages, "-")
+	return strings.TrimSpace(first), strings.Trim(last, "- ")
+}
+
+// contributorFromName parses a personal name in inverted form, e.g.
+// "Fenner, Martin", or in natural order. Names that don't look like personal
+// names are read as organizations.
+func contributorFromName(name string, role string) commonmeta.Contributor {
+	name = strings.Join(strings.Fields(name), " ")
+	c := commonmeta.Contributor{Type: "Person", ContributorRoles: []string{role}}
+	if family, given, ok := strings.Cut(name, ","); ok {
+		c.FamilyName, c.GivenName = strings.TrimSpace(family), strings.TrimSpace(given)
+		return c
+	}
+	c.GivenName, c.FamilyName, c.Name = authorutils.ParseName(name)
+	if c.FamilyName == "" {
+		c.Type = "Organization"
+	}
+	return c
+}
+
+// licenseFromURL returns the license of a work from its URL, with the SPDX
+// identifier for common licenses
+func licenseFromURL(str string) commonmeta.License {
+	if !strings.HasPrefix(str, "http") {
+		return commonmeta.License{}
+	}
+	if normalized, ok := utils.NormalizeCCUrl(str); ok {
+		str = normalized
+	}
+	return commonmeta.License{ID: utils.URLToSPDX(str), URL: str}
+}
+
+// subjectsFromKeywords splits a list of keywords separated by commas or
+// semicolons into subjects
+func subjectsFromKeywords(keywords ...string) []commonmeta.Subject {
+	subjects := make([]commonmeta.Subject, 0)
+	for _, k := range keywords {
+		for _, s := range strings.FieldsFunc(k, func(r rune) bool { return r == ',' || r == ';' }) {
+			if s = strings.TrimSpace(s); s != "" {
+				subjects = append(subjects, commonmeta.Subject{Subject: s})
+			}
+		}
+	}
+	return subjects
+}
+
+// isoDate converts a partial date such as 2024/03/15/, 2024/// or 2024-03
+// to ISO 8601
+func isoDate(s string) string {
+	parts := make([]int, 0, 3)
+	for i, p := range strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == '-' }) {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || i > 2 || (i == 1 && (n < 1 || n > 12)) || (i == 2 && (n < 1 || n > 31)) {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return dateutils.GetDateFromParts(parts...)
+}
+
+// parseDate converts the date of a feed item or front matter, e.g.
+// 2024-03-15T10:00:00Z or March 15, 2024, to ISO 8601
+func parseDate(s string) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02", "January 2, 2006", "Jan 2, 2006", "2 January 2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.DateOnly)
+		}
+	}
+	return isoDate(s)
+}
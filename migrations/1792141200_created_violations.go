@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// creates the violations collection, which records the works that don't
+// validate against the commonmeta JSON Schema. Only admins can access it.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+		// the collection was created at startup by earlier versions
+		_, err := dao.FindCollectionByNameOrId("violations")
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		collection := &models.Collection{
+			Name: "violations",
+			Type: models.CollectionTypeBase,
+			Schema: schema.NewSchema(
+				&schema.SchemaField{Name: "pid", Type: schema.FieldTypeText, Required: true, Options: &schema.TextOptions{}},
+				&schema.SchemaField{Name: "version", Type: schema.FieldTypeText, Options: &schema.TextOptions{}},
+				&schema.SchemaField{Name: "errors", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 2000000}},
+				&schema.SchemaField{Name: "document", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 2000000}},
+				&schema.SchemaField{Name: "rejected", Type: schema.FieldTypeBool, Options: &schema.BoolOptions{}},
+			),
+			Indexes: types.JsonArray[string]{
+				"CREATE UNIQUE INDEX `idx_violations_pid` ON `violations` (`pid`)",
+			},
+		}
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		return deleteCollection(daos.New(db), "violations")
+	})
+}
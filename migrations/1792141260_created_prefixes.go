@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// creates the prefixes collection, which records the registration agency of
+// DOI prefixes. Only admins can access it.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+		// the collection was created at startup by earlier versions
+		_, err := dao.FindCollectionByNameOrId("prefixes")
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		collection := &models.Collection{
+			Name: "prefixes",
+			Type: models.CollectionTypeBase,
+			Schema: schema.NewSchema(
+				&schema.SchemaField{Name: "prefix", Type: schema.FieldTypeText, Required: true, Options: &schema.TextOptions{}},
+				&schema.SchemaField{Name: "ra", Type: schema.FieldTypeText, Required: true, Options: &schema.TextOptions{}},
+			),
+			Indexes: types.JsonArray[string]{
+				"CREATE UNIQUE INDEX `idx_prefixes_prefix` ON `prefixes` (`prefix`)",
+			},
+		}
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		return deleteCollection(daos.New(db), "prefixes")
+	})
+}
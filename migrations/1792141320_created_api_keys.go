@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// creates the api_keys collection, which grants partner systems write access
+// to the works of their prefixes. Only admins can access it.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+		// the collection was created at startup by earlier versions
+		_, err := dao.FindCollectionByNameOrId("api_keys")
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		collection := &models.Collection{
+			Name: "api_keys",
+			Type: models.CollectionTypeBase,
+			Schema: schema.NewSchema(
+				&schema.SchemaField{Name: "name", Type: schema.FieldTypeText, Required: true, Options: &schema.TextOptions{}},
+				&schema.SchemaField{Name: "key", Type: schema.FieldTypeText, Required: true, Options: &schema.TextOptions{}},
+				&schema.SchemaField{Name: "prefixes", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 2000000}},
+			),
+			Indexes: types.JsonArray[string]{
+				"CREATE UNIQUE INDEX `idx_api_keys_name` ON `api_keys` (`name`)",
+				"CREATE UNIQUE INDEX `idx_api_keys_key` ON `api_keys` (`key`)",
+			},
+		}
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		return deleteCollection(daos.New(db), "api_keys")
+	})
+}
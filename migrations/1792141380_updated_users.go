@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// adds the prefixes field to the users collection, granting users write
+// access to the works of their prefixes. Only admins may change it.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return addField(daos.New(db), "users", &schema.SchemaField{Name: "prefixes", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 2000000}})
+	}, func(db dbx.Builder) error {
+		return removeField(daos.New(db), "users", "prefixes")
+	})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// adds the revision field to the works collection, which counts the updates
+// of a work
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return addField(daos.New(db), "works", &schema.SchemaField{Name: "revision", Type: schema.FieldTypeNumber, Options: &schema.NumberOptions{NoDecimal: true}})
+	}, func(db dbx.Builder) error {
+		return removeField(daos.New(db), "works", "revision")
+	})
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// creates the tombstones collection, which records the pids of deleted and
+// withdrawn works. Only admins can access it.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+		// the collection was created at startup by earlier versions
+		_, err := dao.FindCollectionByNameOrId("tombstones")
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		collection := &models.Collection{
+			Name: "tombstones",
+			Type: models.CollectionTypeBase,
+			Schema: schema.NewSchema(
+				&schema.SchemaField{Name: "pid", Type: schema.FieldTypeText, Required: true, Options: &schema.TextOptions{}},
+				&schema.SchemaField{Name: "reason", Type: schema.FieldTypeText, Options: &schema.TextOptions{}},
+				&schema.SchemaField{Name: "date", Type: schema.FieldTypeDate, Options: &schema.DateOptions{}},
+				&schema.SchemaField{Name: "successor", Type: schema.FieldTypeText, Options: &schema.TextOptions{}},
+			),
+			Indexes: types.JsonArray[string]{
+				"CREATE UNIQUE INDEX `idx_tombstones_pid` ON `tombstones` (`pid`)",
+			},
+		}
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		return deleteCollection(daos.New(db), "tombstones")
+	})
+}
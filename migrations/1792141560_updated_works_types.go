@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// commonmeta types missing from the type select of the works collection,
+// e.g. Dissertation and Proceedings read from BibTeX
+var worksTypes = []string{
+	"BlogPost",
+	"BookPart",
+	"BookSeries",
+	"BookSet",
+	"Dissertation",
+	"Entry",
+	"Event",
+	"Grant",
+	"Instrument",
+	"InteractiveResource",
+	"JournalIssue",
+	"JournalVolume",
+	"Journal",
+	"PeerReview",
+	"PhysicalObject",
+	"ProceedingsSeries",
+	"Proceedings",
+	"ReportComponent",
+	"ReportSeries",
+	"StudyRegistration",
+}
+
+// adds all types of the commonmeta JSON Schema to the type select of the
+// works collection
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return updateTypeValues(daos.New(db), func(values []string) []string {
+			for _, t := range worksTypes {
+				if !slices.Contains(values, t) {
+					values = append(values, t)
+				}
+			}
+			return values
+		})
+	}, func(db dbx.Builder) error {
+		return updateTypeValues(daos.New(db), func(values []string) []string {
+			return slices.DeleteFunc(values, func(t string) bool {
+				return slices.Contains(worksTypes, t)
+			})
+		})
+	})
+}
+
+// updateTypeValues updates the values of the type select of the works
+// collection, unless the collection or the field doesn't exist
+func updateTypeValues(dao *daos.Dao, update func(values []string) []string) error {
+	collection, err := dao.FindCollectionByNameOrId("works")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	field := collection.Schema.GetFieldByName("type")
+	if field == nil {
+		return nil
+	}
+	options, ok := field.Options.(*schema.SelectOptions)
+	if !ok {
+		return nil
+	}
+	options.Values = update(options.Values)
+	return dao.SaveCollection(collection)
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// deleteCollection deletes a collection, unless it doesn't exist
+func deleteCollection(dao *daos.Dao, name string) error {
+	collection, err := dao.FindCollectionByNameOrId(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return dao.DeleteCollection(collection)
+}
+
+// addField adds a field to a collection, unless it exists already, as added at
+// startup by earlier versions, or the collection doesn't exist
+func addField(dao *daos.Dao, name string, field *schema.SchemaField) error {
+	collection, err := dao.FindCollectionByNameOrId(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if collection.Schema.GetFieldByName(field.Name) != nil {
+		return nil
+	}
+	collection.Schema.AddField(field)
+	return dao.SaveCollection(collection)
+}
+
+// removeField removes a field from a collection, unless it doesn't exist
+func removeField(dao *daos.Dao, name string, fieldName string) error {
+	collection, err := dao.FindCollectionByNameOrId(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	field := collection.Schema.GetFieldByName(fieldName)
+	if field == nil {
+		return nil
+	}
+	collection.Schema.RemoveField(field.Id)
+	return dao.SaveCollection(collection)
+}
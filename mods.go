@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+// CMToMODSResourceTypes maps commonmeta types to MODS typeOfResource.
+// All other types are text.
+var CMToMODSResourceTypes = map[string]string{
+	"Audiovisual":           "moving image",
+	"Collection":            "mixed material",
+	"ComputationalNotebook": "software, multimedia",
+	"Dataset":               "software, multimedia",
+	"Figure":                "still image",
+	"Image":                 "still image",
+	"PhysicalObject":        "three dimensional object",
+	"Software":              "software, multimedia",
+	"Sound":                 "sound recording",
+}
+
+// CMToMODSRelations maps commonmeta relation types to MODS relatedItem types.
+// Relations not listed are written with their commonmeta type as otherType.
+var CMToMODSRelations = map[string]string{
+	"HasVersion":          "otherVersion",
+	"IsVersionOf":         "otherVersion",
+	"IsNewVersionOf":      "preceding",
+	"IsPreviousVersionOf": "succeeding",
+	"IsPartOf":            "host",
+	"HasPart":             "constituent",
+	"IsSeriesOf":          "constituent",
+	"IsVariantFormOf":     "otherFormat",
+	"IsOriginalFormOf":    "otherFormat",
+	"IsIdenticalTo":       "otherFormat",
+	"References":          "references",
+	"IsReferencedBy":      "isReferencedBy",
+	"IsCitedBy":           "isReferencedBy",
+	"Cites":               "references",
+	"Reviews":             "reviewOf",
+}
+
+// MODS represents metadata in MODS 3.8
+type MODS struct {
+	XMLName         xml.Name             `xml:"mods"`
+	Xmlns           string               `xml:"xmlns,attr"`
+	XmlnsXlink      string               `xml:"xmlns:xlink,attr"`
+	XmlnsXSI        string               `xml:"xmlns:xsi,attr"`
+	Version         string               `xml:"version,attr"`
+	SchemaLocation  string               `xml:"xsi:schemaLocation,attr"`
+	TitleInfo       []MODSTitleInfo      `xml:"titleInfo"`
+	Name            []MODSName           `xml:"name"`
+	TypeOfResource  string               `xml:"typeOfResource,omitempty"`
+	Genre           *MODSTerm            `xml:"genre"`
+	OriginInfo      *MODSOriginInfo      `xml:"originInfo"`
+	Language        *MODSLanguage        `xml:"language"`
+	Abstract        []string             `xml:"abstract"`
+	Subject         []MODSSubject        `xml:"subject"`
+	RelatedItem     []MODSRelatedItem    `xml:"relatedItem"`
+	Identifier      []MODSTerm           `xml:"identifier"`
+	Location        *MODSLocation        `xml:"location"`
+	AccessCondition *MODSAccessCondition `xml:"accessCondition"`
+}
+
+// MODSTerm is an element with an optional type or authority
+type MODSTerm struct {
+	Type      string `xml:"type,attr,omitempty"`
+	Authority string `xml:"authority,attr,omitempty"`
+	Value     string `xml:",chardata"`
+}
+
+// MODSTitleInfo is a title with an optional subtitle
+type MODSTitleInfo struct {
+	Type     string `xml:"type,attr,omitempty"`
+	Lang     string `xml:"lang,attr,omitempty"`
+	Title    string `xml:"title"`
+	SubTitle string `xml:"subTitle,omitempty"`
+}
+
+// MODSName is a personal or corporate name with roles
+type MODSName struct {
+	Type           string     `xml:"type,attr"`
+	NamePart       []MODSTerm `xml:"namePart"`
+	Affiliation    []string   `xml:"affiliation"`
+	Role           []MODSRole `xml:"role"`
+	NameIdentifier *MODSTerm  `xml:"nameIdentifier"`
+}
+
+// MODSRole is a role given as MARC relator term and code
+type MODSRole struct {
+	RoleTerm []MODSTerm `xml:"roleTerm"`
+}
+
+// MODSOriginInfo holds publisher and dates
+type MODSOriginInfo struct {
+	Publisher   string    `xml:"publisher,omitempty"`
+	DateIssued  *MODSDate `xml:"dateIssued"`
+	DateCreated *MODSDate `xml:"dateCreated"`
+	Edition     string    `xml:"edition,omitempty"`
+}
+
+// MODSDate is a date in W3CDTF encoding
+type MODSDate struct {
+	Encoding string `xml:"encoding,attr"`
+	KeyDate  string `xml:"keyDate,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+// MODSLanguage is the language of a work
+type MODSLanguage struct {
+	LanguageTerm MODSTerm `xml:"languageTerm"`
+}
+
+// MODSSubject is a topical subject
+type MODSSubject struct {
+	Topic string `xml:"topic"`
+}
+
+// MODSRelatedItem is a related work, including the host of a component part
+type MODSRelatedItem struct {
+	Type       string         `xml:"type,attr,omitempty"`
+	Href       string         `xml:"xlink:href,attr,omitempty"`
+	OtherType  string         `xml:"otherType,attr,omitempty"`
+	TitleInfo  *MODSTitleInfo `xml:"titleInfo"`
+	Identifier []MODSTerm     `xml:"identifier"`
+	Part       *MODSPart      `xml:"part"`
+}
+
+// MODSPart describes the location of a work within its host
+type MODSPart struct {
+	Detail []MODSDetail `xml:"detail"`
+	Extent *MODSExtent  `xml:"extent"`
+}
+
+// MODSDetail is a volume or issue number
+type MODSDetail struct {
+	Type   string `xml:"type,attr"`
+	Number string `xml:"number"`
+}
+
+// MODSExtent is a page range
+type MODSExtent struct {
+	Unit  string `xml:"unit,attr"`
+	Start string `xml:"start,omitempty"`
+	End   string `xml:"end,omitempty"`
+}
+
+// MODSLocation holds the URL of a work
+type MODSLocation struct {
+	URL []MODSURL `xml:"url"`
+}
+
+// MODSURL is a URL with its intended usage
+type MODSURL struct {
+	Usage string `xml:"usage,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// MODSAccessCondition is the license of a work
+type MODSAccessCondition struct {
+	Type  string `xml:"type,attr"`
+	Href  string `xml:"xlink:href,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// WriteMODS converts commonmeta metadata to MODS 3.8.
+func WriteMODS(data commonmeta.Data) ([]byte, error) {
+	if data.ID == "" {
+		return nil, invalidWork("missing id")
+	}
+	m := MODS{
+		Xmlns:          "http://www.loc.gov/mods/v3",
+		XmlnsXlink:     "http://www.w3.org/1999/xlink",
+		XmlnsXSI:       xsiNS,
+		Version:        "3.8",
+		SchemaLocation: "http://www.loc.gov/mods/v3 http://www.loc.gov/standards/mods/v3/mods-3-8.xsd",
+		TypeOfResource: "text",
+	}
+
+	title := MODSTitleInfo{Title: stripTags(mainTitle(data))}
+	for _, t := range data.Titles {
+		switch t.Type {
+		case "Subtitle":
+			title.SubTitle = stripTags(t.Title)
+		case "AlternativeTitle":
+			m.TitleInfo = append(m.TitleInfo, MODSTitleInfo{Type: "alternative", Lang: t.Language, Title: stripTags(t.Title)})
+		case "TranslatedTitle":
+			m.TitleInfo = append(m.TitleInfo, MODSTitleInfo{Type: "translated", Lang: t.Language, Title: stripTags(t.Title)})
+		}
+	}
+	if title.Title != "" {
+		m.TitleInfo = append([]MODSTitleInfo{title}, m.TitleInfo...)
+	}
+
+	for _, c := range data.Contributors {
+		name := MODSName{Type: "personal"}
+		if c.Type == "Organization" || c.FamilyName == "" {
+			name.Type = "corporate"
+			name.NamePart = []MODSTerm{{Value: c.Name}}
+		} else {
+			name.NamePart = append(name.NamePart, MODSTerm{Type: "family", Value: c.FamilyName})
+			if c.GivenName != "" {
+				name.NamePart = append(name.NamePart, MODSTerm{Type: "given", Value: c.GivenName})
+			}
+		}
+		for _, a := range c.Affiliations {
+			if a != nil && a.Name != "" {
+				name.Affiliation = append(name.Affiliation, a.Name)
+			}
+		}
+		for _, role := range c.ContributorRoles {
+			relator := marcRelator(role)
+			name.Role = append(name.Role, MODSRole{RoleTerm: []MODSTerm{
+				{Type: "text", Authority: "marcrelator", Value: relator.Term},
+				{Type: "code", Authority: "marcrelator", Value: relator.Code},
+			}})
+		}
+		if strings.HasPrefix(c.ID, "https://orcid.org/") {
+			name.NameIdentifier = &MODSTerm{Type: "orcid", Value: c.ID}
+		} else if strings.HasPrefix(c.ID, "https://ror.org/") {
+			name.NameIdentifier = &MODSTerm{Type: "ror", Value: c.ID}
+		} else if c.ID != "" {
+			name.NameIdentifier = &MODSTerm{Type: "uri", Value: c.ID}
+		}
+		m.Name = append(m.Name, name)
+	}
+
+	if t, ok := CMToMODSResourceTypes[data.Type]; ok {
+		m.TypeOfResource = t
+	}
+	if data.Type != "" {
+		m.Genre = &MODSTerm{Authority: "commonmeta", Value: data.Type}
+	}
+	origin := MODSOriginInfo{Publisher: data.Publisher.Name, Edition: data.Version}
+	if d := publicationDate(data); d != "" {
+		origin.DateIssued = &MODSDate{Encoding: "w3cdtf", KeyDate: "yes", Value: d}
+	}
+	if data.Date.Created != "" && data.Date.Created != publicationDate(data) {
+		origin.DateCreated = &MODSDate{Encoding: "w3cdtf", Value: data.Date.Created}
+	}
+	if origin != (MODSOriginInfo{}) {
+		m.OriginInfo = &origin
+	}
+	if data.Language != "" {
+		m.Language = &MODSLanguage{LanguageTerm: MODSTerm{Type: "code", Authority: "rfc5646", Value: data.Language}}
+	}
+	for _, d := range data.Descriptions {
+		if d.Description != "" && (d.Type == "" || d.Type == "Abstract") {
+			m.Abstract = append(m.Abstract, stripTags(d.Description))
+		}
+	}
+	for _, k := range keywords(data) {
+		m.Subject = append(m.Subject, MODSSubject{Topic: k})
+	}
+
+	if data.Container.Title != "" || data.Container.Identifier != "" {
+		host := MODSRelatedItem{Type: "host"}
+		if data.Container.Title != "" {
+			host.TitleInfo = &MODSTitleInfo{Title: stripTags(data.Container.Title)}
+		}
+		if data.Container.Identifier != "" {
+			host.Identifier = []MODSTerm{{Type: strings.ToLower(data.Container.IdentifierType), Value: data.Container.Identifier}}
+		}
+		part := MODSPart{}
+		if data.Container.Volume != "" {
+			part.Detail = append(part.Detail, MODSDetail{Type: "volume", Number: data.Container.Volume})
+		}
+		if data.Container.Issue != "" {
+			part.Detail = append(part.Detail, MODSDetail{Type: "issue", Number: data.Container.Issue})
+		}
+		if data.Container.FirstPage != "" {
+			part.Extent = &MODSExtent{Unit: "pages", Start: data.Container.FirstPage, End: data.Container.LastPage}
+		}
+		if len(part.Detail) > 0 || part.Extent != nil {
+			host.Part = &part
+		}
+		m.RelatedItem = append(m.RelatedItem, host)
+	}
+	for _, r := range data.Relations {
+		if r.ID == "" {
+			continue
+		}
+		item := MODSRelatedItem{Type: CMToMODSRelations[r.Type], Href: r.ID}
+		if item.Type == "" {
+			item.OtherType = r.Type
+		}
+		item.Identifier = []MODSTerm{modsIdentifier(r.ID, "")}
+		m.RelatedItem = append(m.RelatedItem, item)
+	}
+
+	m.Identifier = append(m.Identifier, modsIdentifier(data.ID, ""))
+	for _, i := range data.Identifiers {
+		if i.Identifier != "" && i.Identifier != data.ID {
+			m.Identifier = append(m.Identifier, modsIdentifier(i.Identifier, i.IdentifierType))
+		}
+	}
+	if data.URL != "" {
+		m.Location = &MODSLocation{URL: []MODSURL{{Usage: "primary display", Value: data.URL}}}
+	}
+	if data.License.ID != "" || data.License.URL != "" {
+		value := data.License.ID
+		if value == "" {
+			value = data.License.URL
+		}
+		m.AccessCondition = &MODSAccessCondition{Type: "use and reproduction", Href: licenseURL(data), Value: value}
+	}
+
+	out, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// modsIdentifier returns a MODS identifier, using the DOI rather than the DOI URL
+func modsIdentifier(id string, identifierType string) MODSTerm {
+	if doi := doiFromPid(id); doi != "" {
+		return MODSTerm{Type: "doi", Value: doi}
+	}
+	if identifierType == "" || identifierType == "URL" {
+		return MODSTerm{Type: "uri", Value: id}
+	}
+	return MODSTerm{Type: strings.ToLower(identifierType), Value: id}
+}
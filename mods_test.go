@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+func TestWriteMODS(t *testing.T) {
+	t.Parallel()
+
+	out, err := WriteMODS(catalogData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	want := []string{
+		`<mods xmlns="http://www.loc.gov/mods/v3"`,
+		"<title>Automated quantitative histology</title>",
+		`<name type="personal">
+    <namePart type="family">Sankar</namePart>
+    <namePart type="given">Martial</namePart>
+    <affiliation>University of Lausanne</affiliation>
+    <role>
+      <roleTerm type="text" authority="marcrelator">author</roleTerm>
+      <roleTerm type="code" authority="marcrelator">aut</roleTerm>
+    </role>
+    <nameIdentifier type="orcid">https://orcid.org/0000-0002-8419-5237</nameIdentifier>
+  </name>`,
+		`<roleTerm type="code" authority="marcrelator">edt</roleTerm>`,
+		"<typeOfResource>text</typeOfResource>",
+		`<originInfo>
+    <publisher>eLife Sciences Publications, Ltd</publisher>
+    <dateIssued encoding="w3cdtf" keyDate="yes">2014-02-11</dateIssued>
+  </originInfo>`,
+		`<languageTerm type="code" authority="rfc5646">en</languageTerm>`,
+		"<topic>Plant Biology</topic>",
+		`<relatedItem type="host">`,
+		`<identifier type="issn">2050-084X</identifier>`,
+		`<identifier type="doi">10.7554/elife.01567</identifier>`,
+		`<accessCondition type="use and reproduction" xlink:href="https://creativecommons.org/licenses/by/3.0/legalcode">CC-BY-3.0</accessCondition>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Write MODS: want %v, got\n%v", w, got)
+		}
+	}
+}
+
+func TestModsIdentifier(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		id             string
+		identifierType string
+		want           MODSTerm
+	}
+	testCases := []testCase{
+		{id: "https://doi.org/10.5281/zenodo.1234", want: MODSTerm{Type: "doi", Value: "10.5281/zenodo.1234"}},
+		{id: "https://example.org/works/1", want: MODSTerm{Type: "uri", Value: "https://example.org/works/1"}},
+		{id: "2050-084X", identifierType: "ISSN", want: MODSTerm{Type: "issn", Value: "2050-084X"}},
+	}
+	for _, tc := range testCases {
+		got := modsIdentifier(tc.id, tc.identifierType)
+		if tc.want != got {
+			t.Errorf("MODS identifier(%v): want %v, got %v", tc.id, tc.want, got)
+		}
+	}
+}
+
+func TestWriteMODSSoftware(t *testing.T) {
+	t.Parallel()
+
+	out, err := WriteMODS(commonmeta.Data{ID: "https://example.org/software/1", Type: "Software", Titles: []commonmeta.Title{{Title: "commonmeta"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "<typeOfResource>software, multimedia</typeOfResource>") {
+		t.Errorf("Write MODS: want software, multimedia, got\n%v", string(out))
+	}
+}
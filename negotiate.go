@@ -0,0 +1,140 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaRange represents a single media range of an Accept header, as defined in RFC 9110
+type MediaRange struct {
+	Type    string
+	Subtype string
+	Params  map[string]string
+	Q       float64
+
+	// position in the Accept header, used to break ties
+	index int
+}
+
+// MediaType returns the media range as type/subtype string without parameters
+func (m MediaRange) MediaType() string {
+	return m.Type + "/" + m.Subtype
+}
+
+// specificity returns how specific the media range is: */* < type/* < type/subtype
+func (m MediaRange) specificity() int {
+	if m.Type == "*" {
+		return 0
+	}
+	if m.Subtype == "*" {
+		return 1
+	}
+	return 2
+}
+
+// matches checks whether the media range matches a media type without parameters
+func (m MediaRange) matches(mediaType string) bool {
+	t, s, ok := strings.Cut(mediaType, "/")
+	if !ok {
+		return false
+	}
+	if m.Type != "*" && m.Type != t {
+		return false
+	}
+	return m.Subtype == "*" || m.Subtype == s
+}
+
+// ParseAccept parses an Accept header into a list of media ranges, sorted by
+// descending q-value. Media ranges with equal q-values keep the order of the header.
+func ParseAccept(header string) []MediaRange {
+	ranges := make([]MediaRange, 0)
+	for i, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		// a single "*" is sent by some old clients and means */*
+		if mediaType == "*" {
+			mediaType = "*/*"
+		}
+		t, s, ok := strings.Cut(mediaType, "/")
+		if !ok || t == "" || s == "" || (t == "*" && s != "*") {
+			continue
+		}
+		m := MediaRange{Type: t, Subtype: s, Params: map[string]string{}, Q: 1, index: i}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			if key == "" {
+				continue
+			}
+			if key == "q" {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				m.Q = q
+				continue
+			}
+			m.Params[key] = value
+		}
+		ranges = append(ranges, m)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Q > ranges[j].Q
+	})
+	return ranges
+}
+
+// NegotiateContentType selects the best of the offered media types for an Accept
+// header. The q-value of an offer is taken from the most specific media range
+// matching it. Offers with equal q-values are ranked by the position of the
+// matching media range in the header, then by the order of the offers. Returns
+// the selected media type and the matching media range, or false if none of the
+// offers is acceptable.
+func NegotiateContentType(header string, offers []string) (string, MediaRange, bool) {
+	ranges := ParseAccept(header)
+	best := ""
+	var bestRange MediaRange
+	for _, offer := range offers {
+		m, ok := matchOffer(ranges, offer)
+		if !ok || m.Q == 0 {
+			continue
+		}
+		if best == "" || m.Q > bestRange.Q || (m.Q == bestRange.Q && m.index < bestRange.index) {
+			best = offer
+			bestRange = m
+		}
+	}
+	return best, bestRange, best != ""
+}
+
+// PreferredMediaType returns the acceptable concrete media type (no wildcards)
+// with the highest q-value, used when none of the server offers is acceptable.
+func PreferredMediaType(header string) (MediaRange, bool) {
+	for _, m := range ParseAccept(header) {
+		if m.Q > 0 && m.specificity() == 2 {
+			return m, true
+		}
+	}
+	return MediaRange{}, false
+}
+
+// matchOffer returns the most specific media range matching an offer
+func matchOffer(ranges []MediaRange, offer string) (MediaRange, bool) {
+	var match MediaRange
+	found := false
+	for _, m := range ranges {
+		if !m.matches(offer) {
+			continue
+		}
+		if !found || m.specificity() > match.specificity() {
+			match = m
+			found = true
+		}
+	}
+	return match, found
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		accept string
+		offers []string
+		want   string
+		ok     bool
+	}
+
+	offers := []string{"text/html", "application/vnd.commonmeta+json", "application/vnd.citationstyles.csl+json", "text/x-bibliography"}
+	testCases := []testCase{
+		{accept: "*/*", offers: offers, want: "text/html", ok: true},
+		{accept: "application/vnd.citationstyles.csl+json", offers: offers, want: "application/vnd.citationstyles.csl+json", ok: true},
+		{accept: "application/x-bibtex;q=0.9, application/vnd.citationstyles.csl+json", offers: offers, want: "application/vnd.citationstyles.csl+json", ok: true},
+		{accept: "text/html;q=0.5, application/*;q=0.8", offers: offers, want: "application/vnd.commonmeta+json", ok: true},
+		{accept: "text/x-bibliography; style=apa; locale=de-DE", offers: offers, want: "text/x-bibliography", ok: true},
+		{accept: "application/*, application/vnd.commonmeta+json;q=0", offers: offers, want: "application/vnd.citationstyles.csl+json", ok: true},
+		{accept: "TEXT/HTML", offers: offers, want: "text/html", ok: true},
+		{accept: "image/png, application/x-bibtex", offers: offers, want: "", ok: false},
+		{accept: "text/html;q=0", offers: offers, want: "", ok: false},
+	}
+	for _, tc := range testCases {
+		got, _, ok := NegotiateContentType(tc.accept, tc.offers)
+		if tc.want != got || tc.ok != ok {
+			t.Errorf("Negotiate content type(%v): want %v %v, got %v %v",
+				tc.accept, tc.want, tc.ok, got, ok)
+		}
+	}
+}
+
+func TestParseAcceptParams(t *testing.T) {
+	t.Parallel()
+
+	_, m, ok := NegotiateContentType(`text/x-bibliography; style="apa"; locale=de-DE; q=0.7`, []string{"text/x-bibliography"})
+	if !ok {
+		t.Fatal("Parse accept params: no match")
+	}
+	if m.Params["style"] != "apa" || m.Params["locale"] != "de-DE" || m.Q != 0.7 {
+		t.Errorf("Parse accept params: got %v q=%v", m.Params, m.Q)
+	}
+}
+
+func TestPreferredMediaType(t *testing.T) {
+	t.Parallel()
+
+	m, ok := PreferredMediaType("text/*, application/x-bibtex;q=0.9, application/x-research-info-systems;q=0.1")
+	if !ok || m.MediaType() != "application/x-bibtex" {
+		t.Errorf("Preferred media type: want application/x-bibtex, got %v", m.MediaType())
+	}
+}
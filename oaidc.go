@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/xml"
+	"slices"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+// CMToDCMITypeMappings maps commonmeta types to the DCMI Type Vocabulary
+var CMToDCMITypeMappings = map[string]string{
+	"Audiovisual":           "MovingImage",
+	"Collection":            "Collection",
+	"ComputationalNotebook": "Software",
+	"Dataset":               "Dataset",
+	"Event":                 "Event",
+	"Figure":                "StillImage",
+	"Image":                 "StillImage",
+	"InteractiveResource":   "InteractiveResource",
+	"PhysicalObject":        "PhysicalObject",
+	"Service":               "Service",
+	"Software":              "Software",
+	"Sound":                 "Sound",
+}
+
+// OAIDC represents metadata in the oai_dc format used by OAI-PMH, a subset of
+// the Dublin Core elements
+type OAIDC struct {
+	XMLName        xml.Name `xml:"oai_dc:dc"`
+	XmlnsOAIDC     string   `xml:"xmlns:oai_dc,attr"`
+	XmlnsDC        string   `xml:"xmlns:dc,attr"`
+	XmlnsXSI       string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Title          []string `xml:"dc:title"`
+	Creator        []string `xml:"dc:creator"`
+	Contributor    []string `xml:"dc:contributor"`
+	Subject        []string `xml:"dc:subject"`
+	Description    []string `xml:"dc:description"`
+	Publisher      []string `xml:"dc:publisher"`
+	Date           []string `xml:"dc:date"`
+	Type           []string `xml:"dc:type"`
+	Format         []string `xml:"dc:format"`
+	Identifier     []string `xml:"dc:identifier"`
+	Source         []string `xml:"dc:source"`
+	Language       []string `xml:"dc:language"`
+	Relation       []string `xml:"dc:relation"`
+	Rights         []string `xml:"dc:rights"`
+}
+
+// WriteOAIDC converts commonmeta metadata to Dublin Core in oai_dc format.
+func WriteOAIDC(data commonmeta.Data) ([]byte, error) {
+	if data.ID == "" {
+		return nil, invalidWork("missing id")
+	}
+	dc := OAIDC{
+		XmlnsOAIDC:     "http://www.openarchives.org/OAI/2.0/oai_dc/",
+		XmlnsDC:        "http://purl.org/dc/elements/1.1/",
+		XmlnsXSI:       xsiNS,
+		SchemaLocation: "http://www.openarchives.org/OAI/2.0/oai_dc/ http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
+	}
+	add := func(list *[]string, value string) {
+		if value != "" && !slices.Contains(*list, value) {
+			*list = append(*list, value)
+		}
+	}
+
+	for _, t := range data.Titles {
+		add(&dc.Title, stripTags(t.Title))
+	}
+	for _, c := range data.Contributors {
+		if slices.Contains(c.ContributorRoles, "Author") {
+			add(&dc.Creator, contributorName(c))
+		} else {
+			add(&dc.Contributor, contributorName(c))
+		}
+	}
+	for _, k := range keywords(data) {
+		add(&dc.Subject, k)
+	}
+	for _, d := range data.Descriptions {
+		add(&dc.Description, stripTags(d.Description))
+	}
+	add(&dc.Publisher, data.Publisher.Name)
+	add(&dc.Date, publicationDate(data))
+	add(&dc.Type, CMToDCMITypeMappings[data.Type])
+	if _, ok := CMToDCMITypeMappings[data.Type]; !ok {
+		add(&dc.Type, "Text")
+	}
+	add(&dc.Type, data.Type)
+	for _, f := range data.Files {
+		add(&dc.Format, f.MimeType)
+	}
+	add(&dc.Identifier, data.ID)
+	for _, i := range data.Identifiers {
+		add(&dc.Identifier, i.Identifier)
+	}
+	add(&dc.Identifier, data.URL)
+	if data.Container.Title != "" {
+		source := stripTags(data.Container.Title)
+		if data.Container.IdentifierType == "ISSN" && data.Container.Identifier != "" {
+			source += " (ISSN " + data.Container.Identifier + ")"
+		}
+		add(&dc.Source, source)
+	}
+	add(&dc.Language, data.Language)
+	for _, r := range data.Relations {
+		add(&dc.Relation, r.ID)
+	}
+	add(&dc.Rights, licenseURL(data))
+
+	out, err := xml.MarshalIndent(dc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
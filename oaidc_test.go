@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteOAIDC(t *testing.T) {
+	t.Parallel()
+
+	out, err := WriteOAIDC(catalogData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.TrimPrefix(string(out), `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	want := `<oai_dc:dc xmlns:oai_dc="http://www.openarchives.org/OAI/2.0/oai_dc/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/oai_dc/ http://www.openarchives.org/OAI/2.0/oai_dc.xsd">
+  <dc:title>Automated quantitative histology</dc:title>
+  <dc:creator>Sankar, Martial</dc:creator>
+  <dc:creator>Nieminen, Kaisa</dc:creator>
+  <dc:contributor>Weigel, Detlef</dc:contributor>
+  <dc:subject>Plant Biology</dc:subject>
+  <dc:description>Among the most striking aspects</dc:description>
+  <dc:publisher>eLife Sciences Publications, Ltd</dc:publisher>
+  <dc:date>2014-02-11</dc:date>
+  <dc:type>Text</dc:type>
+  <dc:type>JournalArticle</dc:type>
+  <dc:identifier>https://doi.org/10.7554/elife.01567</dc:identifier>
+  <dc:identifier>https://elifesciences.org/articles/01567</dc:identifier>
+  <dc:source>eLife (ISSN 2050-084X)</dc:source>
+  <dc:language>en</dc:language>
+  <dc:rights>https://creativecommons.org/licenses/by/3.0/legalcode</dc:rights>
+</oai_dc:dc>`
+	if want != got {
+		t.Errorf("Write OAI DC: want\n%v\ngot\n%v", want, got)
+	}
+}
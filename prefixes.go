@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"
+
+	"github.com/front-matter/commonmeta/doiutils"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// ensures that the Prefix struct satisfy the models.Model interface
+var _ models.Model = (*Prefix)(nil)
+
+// Prefix maps a DOI prefix to its registration agency, e.g. Crossref
+type Prefix struct {
+	models.BaseModel
+
+	Prefix string `db:"prefix" json:"prefix"`
+	Ra     string `db:"ra" json:"ra"`
+}
+
+func (m *Prefix) TableName() string {
+	return "prefixes"
+}
+
+// SavePrefix records the registration agency of a DOI prefix
+func SavePrefix(dao *daos.Dao, prefix string, ra string) error {
+	p, err := FindPrefix(dao, prefix)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		p = &Prefix{Prefix: prefix}
+	} else if p.Ra == ra {
+		return nil
+	}
+	p.Ra = ra
+	return dao.Save(p)
+}
+
+// find the registration agency of a DOI prefix
+func FindPrefix(dao *daos.Dao, prefix string) (*Prefix, error) {
+	p := &Prefix{}
+
+	err := dao.ModelQuery(&Prefix{}).
+		AndWhere(dbx.HashExp{"prefix": prefix}).
+		Limit(1).
+		One(p)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// registrationAgency returns the DOI prefix and registration agency of a
+// work read from Crossref or DataCite metadata
+func registrationAgency(pid string, provider string) (string, string, bool) {
+	if provider != "Crossref" && provider != "DataCite" {
+		return "", "", false
+	}
+	prefix, ok := doiutils.ValidatePrefix(pid)
+	return prefix, provider, ok
+}
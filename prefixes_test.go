@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestRegistrationAgency(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		pid      string
+		provider string
+		prefix   string
+		ok       bool
+	}
+	testCases := []testCase{
+		{pid: "https://doi.org/10.7554/elife.01567", provider: "Crossref", prefix: "10.7554", ok: true},
+		{pid: "https://doi.org/10.5281/zenodo.5244404", provider: "DataCite", prefix: "10.5281", ok: true},
+		{pid: "https://doi.org/10.5281/zenodo.5244404", provider: "", ok: false},
+		{pid: "https://example.org/post/1", provider: "Crossref", ok: false},
+	}
+	for _, tc := range testCases {
+		prefix, ra, ok := registrationAgency(tc.pid, tc.provider)
+		if ok != tc.ok || (ok && (prefix != tc.prefix || ra != tc.provider)) {
+			t.Errorf("Registration agency(%v, %v): want %v %v, got %v %v %v", tc.pid, tc.provider, tc.prefix, tc.ok, prefix, ra, ok)
+		}
+	}
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/schemaorg"
+)
+
+// RDF vocabularies used by the commonmeta to RDF mapping
+const (
+	schemaNS  = "http://schema.org/"
+	dctermsNS = "http://purl.org/dc/terms/"
+	rdfNS     = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xsdNS     = "http://www.w3.org/2001/XMLSchema#"
+)
+
+// rdfPrefixes lists the prefixes used in Turtle and RDF/XML, in output order
+var rdfPrefixes = []struct{ Prefix, Namespace string }{
+	{"schema", schemaNS},
+	{"dcterms", dctermsNS},
+	{"rdf", rdfNS},
+	{"xsd", xsdNS},
+}
+
+// RDFMediaTypes maps the supported RDF media types to the content type returned
+var RDFMediaTypes = map[string]string{
+	"text/turtle":           "text/turtle; charset=utf-8",
+	"application/rdf+xml":   "application/rdf+xml; charset=utf-8",
+	"application/n-triples": "application/n-triples; charset=utf-8",
+	"application/ld+json":   "application/ld+json; charset=utf-8",
+}
+
+// CMToRDFRelations maps commonmeta relation types to RDF properties. Relation
+// types not listed here are written as dcterms:relation.
+var CMToRDFRelations = map[string]string{
+	"IsPartOf":            schemaNS + "isPartOf",
+	"HasPart":             schemaNS + "hasPart",
+	"IsIdenticalTo":       schemaNS + "sameAs",
+	"IsTranslationOf":     schemaNS + "translationOfWork",
+	"HasTranslation":      schemaNS + "workTranslation",
+	"IsReviewedBy":        schemaNS + "review",
+	"HasReview":           schemaNS + "review",
+	"Reviews":             schemaNS + "itemReviewed",
+	"IsDerivedFrom":       schemaNS + "isBasedOn",
+	"References":          schemaNS + "citation",
+	"IsReferencedBy":      dctermsNS + "isReferencedBy",
+	"IsVersionOf":         dctermsNS + "isVersionOf",
+	"HasVersion":          dctermsNS + "hasVersion",
+	"IsNewVersionOf":      dctermsNS + "replaces",
+	"IsPreviousVersionOf": dctermsNS + "isReplacedBy",
+	"Requires":            dctermsNS + "requires",
+	"IsRequiredBy":        dctermsNS + "isRequiredBy",
+}
+
+// commonmeta contributor roles and the matching schema.org properties
+var cmToRDFRoles = map[string]string{
+	"Author": schemaNS + "author",
+	"Editor": schemaNS + "editor",
+}
+
+type rdfTermKind int
+
+const (
+	rdfIRI rdfTermKind = iota
+	rdfBlank
+	rdfLiteral
+)
+
+// rdfTerm is an IRI, blank node or literal. Literals have an optional
+// datatype or language tag.
+type rdfTerm struct {
+	Kind     rdfTermKind
+	Value    string
+	Datatype string
+	Language string
+}
+
+type rdfTriple struct {
+	Subject   rdfTerm
+	Predicate string
+	Object    rdfTerm
+}
+
+// rdfGraph is a list of triples in insertion order
+type rdfGraph struct {
+	triples []rdfTriple
+	blanks  int
+}
+
+func iri(s string) rdfTerm {
+	return rdfTerm{Kind: rdfIRI, Value: s}
+}
+
+func literal(s string) rdfTerm {
+	return rdfTerm{Kind: rdfLiteral, Value: s}
+}
+
+// isIRI checks whether an identifier can be used as IRI
+func isIRI(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+var (
+	yearRegexp      = regexp.MustCompile(`^\d{4}$`)
+	yearMonthRegexp = regexp.MustCompile(`^\d{4}-\d{2}$`)
+	dayRegexp       = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// dateLiteral returns a date literal typed with the matching XML Schema datatype
+func dateLiteral(s string) rdfTerm {
+	t := rdfTerm{Kind: rdfLiteral, Value: s}
+	switch {
+	case yearRegexp.MatchString(s):
+		t.Datatype = xsdNS + "gYear"
+	case yearMonthRegexp.MatchString(s):
+		t.Datatype = xsdNS + "gYearMonth"
+	case dayRegexp.MatchString(s):
+		t.Datatype = xsdNS + "date"
+	case strings.Contains(s, "T"):
+		t.Datatype = xsdNS + "dateTime"
+	}
+	return t
+}
+
+func (g *rdfGraph) blank() rdfTerm {
+	t := rdfTerm{Kind: rdfBlank, Value: fmt.Sprintf("b%d", g.blanks)}
+	g.blanks++
+	return t
+}
+
+// add adds a triple, ignoring empty literals and duplicate triples
+func (g *rdfGraph) add(s rdfTerm, p string, o rdfTerm) {
+	if o.Value == "" {
+		return
+	}
+	t := rdfTriple{Subject: s, Predicate: p, Object: o}
+	for _, v := range g.triples {
+		if v == t {
+			return
+		}
+	}
+	g.triples = append(g.triples, t)
+}
+
+// node returns the IRI of a linked node, or a new blank node if it has no IRI
+func (g *rdfGraph) node(id string) rdfTerm {
+	if isIRI(id) {
+		return iri(id)
+	}
+	return g.blank()
+}
+
+// subjects returns the subjects of the graph in order of first appearance
+func (g *rdfGraph) subjects() []rdfTerm {
+	subjects := make([]rdfTerm, 0)
+	seen := map[rdfTerm]bool{}
+	for _, t := range g.triples {
+		if !seen[t.Subject] {
+			seen[t.Subject] = true
+			subjects = append(subjects, t.Subject)
+		}
+	}
+	return subjects
+}
+
+// properties returns the triples with the given subject
+func (g *rdfGraph) properties(s rdfTerm) []rdfTriple {
+	triples := make([]rdfTriple, 0)
+	for _, t := range g.triples {
+		if t.Subject == s {
+			triples = append(triples, t)
+		}
+	}
+	return triples
+}
+
+// RDFGraph maps commonmeta metadata to an RDF graph using the schema.org
+// vocabulary, with the pid of the work as subject. Contributors, references,
+// relations and funding are linked nodes, using their identifier as IRI if
+// available.
+func RDFGraph(data commonmeta.Data) (*rdfGraph, error) {
+	if !isIRI(data.ID) {
+		return nil, invalidWork("pid %q is not an IRI", data.ID)
+	}
+	g := &rdfGraph{}
+	work := iri(data.ID)
+	soType := schemaorg.CMToSOMappings[data.Type]
+	if soType == "" {
+		soType = "CreativeWork"
+	}
+	g.add(work, rdfNS+"type", iri(schemaNS+soType))
+	g.add(work, schemaNS+"additionalType", literal(data.AdditionalType))
+
+	for _, t := range data.Titles {
+		title := rdfTerm{Kind: rdfLiteral, Value: stripTags(t.Title), Language: t.Language}
+		if t.Type == "Subtitle" {
+			g.add(work, schemaNS+"alternativeHeadline", title)
+		} else {
+			g.add(work, schemaNS+"name", title)
+		}
+	}
+	for _, d := range data.Descriptions {
+		description := rdfTerm{Kind: rdfLiteral, Value: stripTags(d.Description), Language: d.Language}
+		if d.Type == "Abstract" {
+			g.add(work, schemaNS+"abstract", description)
+		} else {
+			g.add(work, schemaNS+"description", description)
+		}
+	}
+	for _, c := range data.Contributors {
+		contributor := g.node(c.ID)
+		if c.Type == "Organization" {
+			g.add(contributor, rdfNS+"type", iri(schemaNS+"Organization"))
+		} else {
+			g.add(contributor, rdfNS+"type", iri(schemaNS+"Person"))
+		}
+		g.add(contributor, schemaNS+"givenName", literal(c.GivenName))
+		g.add(contributor, schemaNS+"familyName", literal(c.FamilyName))
+		g.add(contributor, schemaNS+"name", literal(c.Name))
+		for _, a := range c.Affiliations {
+			if a == nil || (a.ID == "" && a.Name == "") {
+				continue
+			}
+			affiliation := g.node(a.ID)
+			g.add(affiliation, rdfNS+"type", iri(schemaNS+"Organization"))
+			g.add(affiliation, schemaNS+"name", literal(a.Name))
+			g.add(contributor, schemaNS+"affiliation", affiliation)
+		}
+		roles := make([]string, 0)
+		for _, role := range c.ContributorRoles {
+			if p, ok := cmToRDFRoles[role]; ok {
+				roles = append(roles, p)
+			}
+		}
+		if len(roles) == 0 {
+			roles = append(roles, schemaNS+"contributor")
+		}
+		for _, p := range roles {
+			g.add(work, p, contributor)
+		}
+	}
+
+	if data.Publisher.Name != "" || data.Publisher.ID != "" {
+		publisher := g.node(data.Publisher.ID)
+		g.add(publisher, rdfNS+"type", iri(schemaNS+"Organization"))
+		g.add(publisher, schemaNS+"name", literal(data.Publisher.Name))
+		g.add(work, schemaNS+"publisher", publisher)
+	}
+	if data.Container.Title != "" || data.Container.Identifier != "" {
+		var container rdfTerm
+		if data.Container.IdentifierType == "ISSN" {
+			container = g.blank()
+			g.add(container, rdfNS+"type", iri(schemaNS+"Periodical"))
+			g.add(container, schemaNS+"issn", literal(data.Container.Identifier))
+		} else {
+			container = g.node(data.Container.Identifier)
+			g.add(container, rdfNS+"type", iri(schemaNS+"CreativeWork"))
+		}
+		g.add(container, schemaNS+"name", literal(data.Container.Title))
+		g.add(work, schemaNS+"isPartOf", container)
+	}
+	g.add(work, schemaNS+"volumeNumber", literal(data.Container.Volume))
+	g.add(work, schemaNS+"issueNumber", literal(data.Container.Issue))
+	g.add(work, schemaNS+"pageStart", literal(data.Container.FirstPage))
+	g.add(work, schemaNS+"pageEnd", literal(data.Container.LastPage))
+
+	if data.Date.Published != "" {
+		g.add(work, schemaNS+"datePublished", dateLiteral(data.Date.Published))
+	}
+	if data.Date.Created != "" {
+		g.add(work, schemaNS+"dateCreated", dateLiteral(data.Date.Created))
+	}
+	if data.Date.Updated != "" {
+		g.add(work, schemaNS+"dateModified", dateLiteral(data.Date.Updated))
+	}
+	g.add(work, schemaNS+"inLanguage", literal(data.Language))
+	g.add(work, schemaNS+"version", literal(data.Version))
+	for _, s := range data.Subjects {
+		g.add(work, schemaNS+"keywords", literal(s.Subject))
+	}
+	if data.License.URL != "" {
+		g.add(work, schemaNS+"license", iri(data.License.URL))
+	}
+	if data.URL != "" {
+		g.add(work, schemaNS+"url", iri(data.URL))
+	}
+	for _, i := range data.Identifiers {
+		if i.Identifier != data.ID {
+			g.add(work, schemaNS+"identifier", literal(i.Identifier))
+		}
+	}
+
+	for _, r := range data.References {
+		if r.ID == "" && r.Title == "" && r.Unstructured == "" {
+			continue
+		}
+		reference := g.node(r.ID)
+		if reference.Kind == rdfBlank {
+			g.add(reference, rdfNS+"type", iri(schemaNS+"CreativeWork"))
+			g.add(reference, schemaNS+"name", literal(stripTags(r.Title)))
+			g.add(reference, schemaNS+"datePublished", dateLiteral(r.PublicationYear))
+			g.add(reference, schemaNS+"description", literal(stripTags(r.Unstructured)))
+		}
+		g.add(work, schemaNS+"citation", reference)
+	}
+	for _, r := range data.Relations {
+		if !isIRI(r.ID) {
+			continue
+		}
+		p, ok := CMToRDFRelations[r.Type]
+		if !ok {
+			p = dctermsNS + "relation"
+		}
+		g.add(work, p, iri(r.ID))
+	}
+	for _, f := range data.FundingReferences {
+		if f.FunderName == "" && f.FunderIdentifier == "" && f.AwardNumber == "" {
+			continue
+		}
+		grant := g.node(f.AwardURI)
+		g.add(grant, rdfNS+"type", iri(schemaNS+"Grant"))
+		g.add(grant, schemaNS+"identifier", literal(f.AwardNumber))
+		if f.FunderName != "" || f.FunderIdentifier != "" {
+			funder := g.node(f.FunderIdentifier)
+			g.add(funder, rdfNS+"type", iri(schemaNS+"Organization"))
+			g.add(funder, schemaNS+"name", literal(f.FunderName))
+			if funder.Kind == rdfBlank {
+				g.add(funder, schemaNS+"identifier", literal(f.FunderIdentifier))
+			}
+			g.add(grant, schemaNS+"funder", funder)
+		}
+		g.add(work, schemaNS+"funding", grant)
+	}
+	return g, nil
+}
+
+// WriteRDF converts commonmeta metadata to one of the RDFMediaTypes
+func WriteRDF(data commonmeta.Data, mediaType string) (string, error) {
+	g, err := RDFGraph(data)
+	if err != nil {
+		return "", err
+	}
+	switch mediaType {
+	case "text/turtle":
+		return g.Turtle(), nil
+	case "application/rdf+xml":
+		return g.RDFXML(), nil
+	case "application/n-triples":
+		return g.NTriples(), nil
+	case "application/ld+json":
+		out, err := json.MarshalIndent(g.JSONLD(), "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(out) + "\n", nil
+	default:
+		return "", fmt.Errorf("media type %s is not an RDF format", mediaType)
+	}
+}
+
+var ntriplesReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// ntriplesTerm serializes a term in N-Triples syntax, also used by Turtle
+func ntriplesTerm(t rdfTerm) string {
+	switch t.Kind {
+	case rdfIRI:
+		return "<" + t.Value + ">"
+	case rdfBlank:
+		return "_:" + t.Value
+	}
+	s := `"` + ntriplesReplacer.Replace(t.Value) + `"`
+	if t.Language != "" {
+		return s + "@" + t.Language
+	}
+	if t.Datatype != "" {
+		return s + "^^<" + t.Datatype + ">"
+	}
+	return s
+}
+
+// NTriples serializes the graph as N-Triples
+func (g *rdfGraph) NTriples() string {
+	var b strings.Builder
+	for _, t := range g.triples {
+		fmt.Fprintf(&b, "%s <%s> %s .\n", ntriplesTerm(t.Subject), t.Predicate, ntriplesTerm(t.Object))
+	}
+	return b.String()
+}
+
+var localNameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// qname abbreviates an IRI with one of the rdfPrefixes, or returns false
+func qname(s string) (string, bool) {
+	for _, p := range rdfPrefixes {
+		if local, ok := strings.CutPrefix(s, p.Namespace); ok && localNameRegexp.MatchString(local) {
+			return p.Prefix + ":" + local, true
+		}
+	}
+	return "", false
+}
+
+// Turtle serializes the graph as Turtle. Blank nodes are only referenced once
+// in graphs built by RDFGraph and are written inline.
+func (g *rdfGraph) Turtle() string {
+	var b strings.Builder
+	for _, p := range rdfPrefixes {
+		fmt.Fprintf(&b, "@prefix %s: <%s> .\n", p.Prefix, p.Namespace)
+	}
+	for _, s := range g.subjects() {
+		if s.Kind == rdfBlank {
+			continue
+		}
+		b.WriteString("\n" + ntriplesTerm(s))
+		g.turtleProperties(&b, s, 1)
+		b.WriteString(" .\n")
+	}
+	return b.String()
+}
+
+func (g *rdfGraph) turtleProperties(b *strings.Builder, s rdfTerm, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for i, t := range g.properties(s) {
+		if i > 0 {
+			b.WriteString(" ;")
+		}
+		p := "<" + t.Predicate + ">"
+		if t.Predicate == rdfNS+"type" {
+			p = "a"
+		} else if q, ok := qname(t.Predicate); ok {
+			p = q
+		}
+		fmt.Fprintf(b, "\n%s%s %s", indent, p, g.turtleTerm(t.Object, depth))
+	}
+}
+
+func (g *rdfGraph) turtleTerm(t rdfTerm, depth int) string {
+	switch t.Kind {
+	case rdfBlank:
+		var b strings.Builder
+		b.WriteString("[")
+		g.turtleProperties(&b, t, depth+1)
+		b.WriteString("\n" + strings.Repeat("    ", depth) + "]")
+		return b.String()
+	case rdfIRI:
+		if q, ok := qname(t.Value); ok {
+			return q
+		}
+	case rdfLiteral:
+		if q, ok := qname(t.Datatype); ok && t.Language == "" {
+			return `"` + ntriplesReplacer.Replace(t.Value) + `"^^` + q
+		}
+	}
+	return ntriplesTerm(t)
+}
+
+// RDFXML serializes the graph as RDF/XML. Blank nodes are written as nested
+// node elements.
+func (g *rdfGraph) RDFXML() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString("<rdf:RDF")
+	for _, p := range rdfPrefixes {
+		fmt.Fprintf(&b, "\n    xmlns:%s=\"%s\"", p.Prefix, p.Namespace)
+	}
+	b.WriteString(">\n")
+	for _, s := range g.subjects() {
+		if s.Kind != rdfBlank {
+			g.rdfxmlNode(&b, s, 1)
+		}
+	}
+	b.WriteString("</rdf:RDF>\n")
+	return b.String()
+}
+
+func (g *rdfGraph) rdfxmlNode(b *strings.Builder, s rdfTerm, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if s.Kind == rdfIRI {
+		fmt.Fprintf(b, "%s<rdf:Description rdf:about=\"%s\">\n", indent, xmlEscape(s.Value))
+	} else {
+		fmt.Fprintf(b, "%s<rdf:Description>\n", indent)
+	}
+	for _, t := range g.properties(s) {
+		p, ok := qname(t.Predicate)
+		if !ok {
+			continue
+		}
+		switch t.Object.Kind {
+		case rdfIRI:
+			fmt.Fprintf(b, "%s  <%s rdf:resource=\"%s\"/>\n", indent, p, xmlEscape(t.Object.Value))
+		case rdfBlank:
+			fmt.Fprintf(b, "%s  <%s>\n", indent, p)
+			g.rdfxmlNode(b, t.Object, depth+2)
+			fmt.Fprintf(b, "%s  </%s>\n", indent, p)
+		default:
+			attr := ""
+			if t.Object.Language != "" {
+				attr = fmt.Sprintf(" xml:lang=\"%s\"", xmlEscape(t.Object.Language))
+			} else if t.Object.Datatype != "" {
+				attr = fmt.Sprintf(" rdf:datatype=\"%s\"", xmlEscape(t.Object.Datatype))
+			}
+			fmt.Fprintf(b, "%s  <%s%s>%s</%s>\n", indent, p, attr, xmlEscape(t.Object.Value), p)
+		}
+	}
+	fmt.Fprintf(b, "%s</rdf:Description>\n", indent)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// JSONLD serializes the graph as compacted JSON-LD using schema.org as
+// default vocabulary. Blank nodes are embedded in the node referencing them.
+func (g *rdfGraph) JSONLD() map[string]any {
+	nodes := make([]any, 0)
+	for _, s := range g.subjects() {
+		if s.Kind != rdfBlank {
+			nodes = append(nodes, g.jsonldNode(s))
+		}
+	}
+	context := map[string]string{
+		"@vocab":  schemaNS,
+		"dcterms": dctermsNS,
+		"xsd":     xsdNS,
+	}
+	if len(nodes) == 1 {
+		node := nodes[0].(map[string]any)
+		node["@context"] = context
+		return node
+	}
+	return map[string]any{"@context": context, "@graph": nodes}
+}
+
+func (g *rdfGraph) jsonldNode(s rdfTerm) map[string]any {
+	node := map[string]any{}
+	if s.Kind == rdfIRI {
+		node["@id"] = s.Value
+	}
+	values := map[string][]any{}
+	for _, t := range g.properties(s) {
+		var key string
+		var value any
+		if t.Predicate == rdfNS+"type" {
+			key = "@type"
+			value = jsonldCompact(t.Object.Value)
+		} else {
+			key = jsonldCompact(t.Predicate)
+			switch t.Object.Kind {
+			case rdfIRI:
+				value = map[string]any{"@id": t.Object.Value}
+			case rdfBlank:
+				value = g.jsonldNode(t.Object)
+			default:
+				if t.Object.Language != "" {
+					value = map[string]any{"@value": t.Object.Value, "@language": t.Object.Language}
+				} else if t.Object.Datatype != "" {
+					value = map[string]any{"@value": t.Object.Value, "@type": jsonldCompact(t.Object.Datatype)}
+				} else {
+					value = t.Object.Value
+				}
+			}
+		}
+		values[key] = append(values[key], value)
+	}
+	for k, v := range values {
+		if len(v) == 1 {
+			node[k] = v[0]
+		} else {
+			node[k] = v
+		}
+	}
+	return node
+}
+
+// jsonldCompact shortens an IRI to a term of the JSON-LD context
+func jsonldCompact(s string) string {
+	if local, ok := strings.CutPrefix(s, schemaNS); ok {
+		return local
+	}
+	if q, ok := qname(s); ok {
+		return q
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+var rdfData = commonmeta.Data{
+	ID:   "https://doi.org/10.7554/elife.01567",
+	Type: "JournalArticle",
+	Contributors: []commonmeta.Contributor{
+		{ID: "https://orcid.org/0000-0002-8419-5237", Type: "Person", GivenName: "Martial", FamilyName: "Sankar", ContributorRoles: []string{"Author"}},
+		{Type: "Organization", Name: "Plant Biology Consortium", ContributorRoles: []string{"Author"}},
+	},
+	Titles:            []commonmeta.Title{{Title: "Automated quantitative histology"}},
+	Container:         commonmeta.Container{Title: "eLife", Identifier: "2050-084X", IdentifierType: "ISSN", Volume: "3"},
+	Date:              commonmeta.Date{Published: "2014-02-11"},
+	References:        []commonmeta.Reference{{Key: "ref1", ID: "https://doi.org/10.1038/nature02100"}, {Key: "ref2", Title: "A \"quoted\" title", PublicationYear: "2008"}},
+	Relations:         []commonmeta.Relation{{ID: "https://portal.issn.org/resource/ISSN/2050-084X", Type: "IsPartOf"}, {ID: "https://doi.org/10.1101/000001", Type: "IsPreprintOf"}},
+	FundingReferences: []commonmeta.FundingReference{{FunderIdentifier: "https://doi.org/10.13039/501100001711", FunderName: "Swiss National Science Foundation", AwardNumber: "CRSII3_136278"}},
+	License:           commonmeta.License{URL: "https://creativecommons.org/licenses/by/3.0/legalcode"},
+	URL:               "https://elifesciences.org/articles/01567",
+}
+
+func TestWriteNTriples(t *testing.T) {
+	t.Parallel()
+
+	got, err := WriteRDF(rdfData, "application/n-triples")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`<https://doi.org/10.7554/elife.01567> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://schema.org/ScholarlyArticle> .`,
+		`<https://doi.org/10.7554/elife.01567> <http://schema.org/author> <https://orcid.org/0000-0002-8419-5237> .`,
+		`<https://orcid.org/0000-0002-8419-5237> <http://schema.org/familyName> "Sankar" .`,
+		`<https://doi.org/10.7554/elife.01567> <http://schema.org/author> _:b0 .`,
+		`_:b0 <http://schema.org/name> "Plant Biology Consortium" .`,
+		`<https://doi.org/10.7554/elife.01567> <http://schema.org/datePublished> "2014-02-11"^^<http://www.w3.org/2001/XMLSchema#date> .`,
+		`<https://doi.org/10.7554/elife.01567> <http://schema.org/citation> <https://doi.org/10.1038/nature02100> .`,
+		`_:b2 <http://schema.org/name> "A \"quoted\" title" .`,
+		`<https://doi.org/10.7554/elife.01567> <http://purl.org/dc/terms/relation> <https://doi.org/10.1101/000001> .`,
+		`<https://doi.org/10.13039/501100001711> <http://schema.org/name> "Swiss National Science Foundation" .`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w+"\n") {
+			t.Errorf("Write N-Triples: want %v, got\n%v", w, got)
+		}
+	}
+}
+
+func TestWriteTurtle(t *testing.T) {
+	t.Parallel()
+
+	got, err := WriteRDF(rdfData, "text/turtle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"@prefix schema: <http://schema.org/> .",
+		"<https://doi.org/10.7554/elife.01567>\n    a schema:ScholarlyArticle ;\n    schema:name \"Automated quantitative histology\" ;",
+		"    schema:isPartOf [\n        a schema:Periodical ;\n        schema:issn \"2050-084X\" ;\n        schema:name \"eLife\"\n    ] ;",
+		"    schema:datePublished \"2014-02-11\"^^xsd:date ;",
+		"<https://orcid.org/0000-0002-8419-5237>\n    a schema:Person ;\n    schema:givenName \"Martial\" ;\n    schema:familyName \"Sankar\" .",
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Write Turtle: want %v, got\n%v", w, got)
+		}
+	}
+}
+
+func TestWriteRDFXML(t *testing.T) {
+	t.Parallel()
+
+	got, err := WriteRDF(rdfData, "application/rdf+xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`<rdf:Description rdf:about="https://doi.org/10.7554/elife.01567">`,
+		`<rdf:type rdf:resource="http://schema.org/ScholarlyArticle"/>`,
+		`<schema:datePublished rdf:datatype="http://www.w3.org/2001/XMLSchema#date">2014-02-11</schema:datePublished>`,
+		`<schema:name>A &#34;quoted&#34; title</schema:name>`,
+		`<schema:funding>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Write RDF/XML: want %v, got\n%v", w, got)
+		}
+	}
+}
+
+func TestWriteJSONLD(t *testing.T) {
+	t.Parallel()
+
+	out, err := WriteRDF(rdfData, "application/ld+json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatal(err)
+	}
+	// the work and the linked contributor and funder have an IRI
+	graph, ok := got["@graph"].([]any)
+	if !ok || len(graph) != 3 {
+		t.Fatalf("Write JSON-LD: want graph with 3 nodes, got %v", out)
+	}
+	work := graph[0].(map[string]any)
+	if work["@id"] != rdfData.ID || work["@type"] != "ScholarlyArticle" {
+		t.Errorf("Write JSON-LD: want %v, got %v", rdfData.ID, work)
+	}
+	if authors, ok := work["author"].([]any); !ok || len(authors) != 2 {
+		t.Errorf("Write JSON-LD: want 2 authors, got %v", work["author"])
+	}
+}
+
+func TestWriteRDFErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := WriteRDF(commonmeta.Data{ID: "10.7554/elife.01567"}, "text/turtle"); err == nil {
+		t.Errorf("Write RDF: want error for pid that is not an IRI")
+	}
+	if _, err := WriteRDF(rdfData, "application/json"); err == nil {
+		t.Errorf("Write RDF: want error for unsupported media type")
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/crossref"
+	"github.com/front-matter/commonmeta/crossrefxml"
+	"github.com/front-matter/commonmeta/datacite"
+	"github.com/front-matter/commonmeta/schemaorg"
+)
+
+// Reader parses a work in one format into commonmeta. Readers are registered
+// with a ReaderRegistry, which drives the conversion endpoint.
+type Reader struct {
+	// Name is the short name of the format, e.g. crossref
+	Name string `json:"name"`
+	// MediaType is the canonical media type, e.g. application/vnd.crossref+json
+	MediaType string `json:"mediaType"`
+	// Aliases are other media types accepted by this reader
+	Aliases []string `json:"aliases,omitempty"`
+	// Extension is the file extension including the dot, e.g. .xml
+	Extension string `json:"extension,omitempty"`
+	// Extensions are other file extensions accepted by this reader
+	Extensions []string `json:"extensions,omitempty"`
+	// Description is a human-readable description of the format
+	Description string `json:"description,omitempty"`
+
+	// Read parses a single work
+	Read func(b []byte) (commonmeta.Data, error) `json:"-"`
+	// ReadAll parses a file with several works, if the format has them
+	ReadAll func(b []byte) ([]ReadResult, error) `json:"-"`
+	// ReadFile parses a file that refers to other files, e.g. a bibliography,
+	// if the format has them
+	ReadFile func(filename string) ([]ReadResult, error) `json:"-"`
+}
+
+// ReadResult is a work read from a file with several works. Raw is the
+// record of the work in the file, used to report records that can't be read.
+// Key is the citation key of the work, if the format has one.
+type ReadResult struct {
+	Raw  []byte
+	Key  string
+	Data commonmeta.Data
+	Err  error
+}
+
+// Parse reads a single work, turning panics of the commonmeta readers on
+// incomplete input into errors
+func (rd *Reader) Parse(b []byte) (data commonmeta.Data, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%s reader failed: %v", rd.Name, p)
+		}
+	}()
+	return rd.Read(b)
+}
+
+// ParseAll reads all works of a file, or a single work for formats without
+// ReadAll. Errors of single works are returned with their ReadResult.
+func (rd *Reader) ParseAll(b []byte) (results []ReadResult, err error) {
+	if rd.ReadAll == nil {
+		data, err := rd.Parse(b)
+		return []ReadResult{{Raw: b, Data: data, Err: err}}, nil
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%s reader failed: %v", rd.Name, p)
+		}
+	}()
+	return rd.ReadAll(b)
+}
+
+// ParseFile reads all works of a file, decompressing .gz files. Formats
+// referring to other files read them relative to the file.
+func (rd *Reader) ParseFile(filename string) (results []ReadResult, err error) {
+	if rd.ReadFile == nil {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if b, err = gunzip(dumpFile{name: filename, b: b}); err != nil {
+			return nil, err
+		}
+		return rd.ParseAll(b)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%s reader failed: %v", rd.Name, p)
+		}
+	}()
+	return rd.ReadFile(filename)
+}
+
+// ReaderRegistry is a registry of readers keyed by media type, alias,
+// extension and short name, kept in registration order
+type ReaderRegistry struct {
+	mu      sync.RWMutex
+	readers []*Reader
+	index   map[string]*Reader
+}
+
+// NewReaderRegistry returns an empty registry
+func NewReaderRegistry() *ReaderRegistry {
+	return &ReaderRegistry{index: make(map[string]*Reader)}
+}
+
+// Register adds a reader to the registry. The media type, aliases, extensions
+// and short name must not be registered already.
+func (r *ReaderRegistry) Register(rd Reader) error {
+	if rd.Name == "" || rd.MediaType == "" {
+		return fmt.Errorf("reader needs a name and media type")
+	}
+	if rd.Read == nil {
+		return fmt.Errorf("reader %s needs a Read function", rd.Name)
+	}
+	rd.MediaType = strings.ToLower(rd.MediaType)
+	keys := []string{rd.MediaType, "name:" + strings.ToLower(rd.Name)}
+	rd.Aliases = slices.Clone(rd.Aliases)
+	for i, alias := range rd.Aliases {
+		rd.Aliases[i] = strings.ToLower(alias)
+		keys = append(keys, rd.Aliases[i])
+	}
+	if rd.Extension != "" {
+		rd.Extension = normalizeExtension(rd.Extension)
+		keys = append(keys, "ext:"+rd.Extension)
+	}
+	rd.Extensions = slices.Clone(rd.Extensions)
+	for i, ext := range rd.Extensions {
+		rd.Extensions[i] = normalizeExtension(ext)
+		keys = append(keys, "ext:"+rd.Extensions[i])
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		if existing, ok := r.index[key]; ok {
+			return fmt.Errorf("reader %s conflicts with %s on %s", rd.Name, existing.Name, key)
+		}
+	}
+	r.readers = append(r.readers, &rd)
+	for _, key := range keys {
+		r.index[key] = &rd
+	}
+	return nil
+}
+
+// MustRegister adds a reader to the registry and panics on conflicts
+func (r *ReaderRegistry) MustRegister(rd Reader) {
+	if err := r.Register(rd); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the reader for a media type or alias
+func (r *ReaderRegistry) Lookup(mediaType string) (*Reader, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rd, ok := r.index[strings.ToLower(mediaType)]
+	return rd, ok
+}
+
+// ByName returns the reader with the given short name
+func (r *ReaderRegistry) ByName(name string) (*Reader, bool) {
+	return r.Lookup("name:" + strings.ToLower(name))
+}
+
+// ByExtension returns the reader for a file extension, with or without the dot
+func (r *ReaderRegistry) ByExtension(ext string) (*Reader, bool) {
+	return r.Lookup("ext:" + normalizeExtension(ext))
+}
+
+func normalizeExtension(ext string) string {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return strings.ToLower(ext)
+}
+
+// Readers returns all readers in registration order
+func (r *ReaderRegistry) Readers() []*Reader {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.readers)
+}
+
+// MediaTypes returns the media types and aliases of all readers
+func (r *ReaderRegistry) MediaTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mediaTypes := make([]string, 0, len(r.readers))
+	for _, rd := range r.readers {
+		mediaTypes = append(mediaTypes, rd.MediaType)
+		mediaTypes = append(mediaTypes, rd.Aliases...)
+	}
+	return mediaTypes
+}
+
+// Readers is the registry of readers used for conversions
+var Readers = NewReaderRegistry()
+
+func init() {
+	for _, rd := range builtinReaders {
+		Readers.MustRegister(rd)
+	}
+}
+
+// builtinReaders are the readers registered by default
+var builtinReaders = []Reader{
+	{
+		Name:        "commonmeta",
+		MediaType:   "application/vnd.commonmeta+json",
+		Aliases:     []string{"application/json"},
+		Extension:   ".json",
+		Description: "Commonmeta JSON",
+		Read:        readJSON(commonmeta.Read),
+	},
+	{
+		Name:        "crossref",
+		MediaType:   "application/vnd.crossref+json",
+		Aliases:     []string{"application/vnd.crossref-api-message+json"},
+		Description: "Crossref REST API JSON, a single work with or without the message envelope",
+		Read:        readJSON(crossref.Read, "message"),
+	},
+	{
+		Name:        "datacite",
+		MediaType:   "application/vnd.datacite.datacite+json",
+		Description: "DataCite JSON, a single DOI with or without the REST API or JSON:API envelope",
+		Read:        readJSON(datacite.Read, "data", "attributes"),
+	},
+	{
+		Name:        "csl",
+		MediaType:   "application/vnd.citationstyles.csl+json",
+		Description: "Citation Style Language (CSL) JSON, a single item or an array of items",
+		Read:        readOne(ReadCSLAll),
+		ReadAll:     ReadCSLAll,
+	},
+	{
+		Name:        "schemaorg",
+		MediaType:   "application/vnd.schemaorg.ld+json",
+		Aliases:     []string{"application/ld+json"},
+		Description: "Schema.org JSON-LD, the id only",
+		Read:        readJSON(schemaorg.Read),
+	},
+	{
+		Name:        "crossref-xml",
+		MediaType:   "application/vnd.crossref.unixsd+xml",
+		Aliases:     []string{"application/xml"},
+		Extension:   ".xml",
+		Description: "Crossref UNIXSD XML, a single query result with or without the crossref_result envelope",
+		Read:        readCrossrefXML,
+	},
+	{
+		Name:        "bibtex",
+		MediaType:   "application/x-bibtex",
+		Aliases:     []string{"text/x-bibtex"},
+		Extension:   ".bib",
+		Description: "BibTeX, one or more entries",
+		Read:        readOne(ReadBibtexAll),
+		ReadAll:     ReadBibtexAll,
+	},
+	{
+		Name:        "ris",
+		MediaType:   "application/x-research-info-systems",
+		Extension:   ".ris",
+		Description: "RIS, one or more records",
+		Read:        readOne(ReadRISAll),
+		ReadAll:     ReadRISAll,
+	},
+	{
+		Name:        "jsonfeed",
+		MediaType:   "application/feed+json",
+		Description: "JSON Feed, the items of a feed",
+		Read:        readOne(ReadJSONFeedAll),
+		ReadAll:     ReadJSONFeedAll,
+	},
+	{
+		Name:        "frontmatter",
+		MediaType:   "text/markdown",
+		Aliases:     []string{"text/x-markdown"},
+		Extension:   ".md",
+		Extensions:  []string{".qmd"},
+		Description: "YAML front matter of a Markdown or Quarto file, e.g. a blog post",
+		Read:        ReadFrontMatter,
+		ReadFile:    ReadFrontMatterFile,
+	},
+}
+
+// readOne returns a read function for a single work of a format with
+// several works per file
+func readOne(readAll func(b []byte) ([]ReadResult, error)) func(b []byte) (commonmeta.Data, error) {
+	return func(b []byte) (commonmeta.Data, error) {
+		results, err := readAll(b)
+		if err != nil {
+			return commonmeta.Data{}, err
+		}
+		if len(results) != 1 {
+			return commonmeta.Data{}, fmt.Errorf("expected a single work, got %d", len(results))
+		}
+		return results[0].Data, results[0].Err
+	}
+}
+
+// readJSON returns a read function that decodes JSON into the content type of
+// a commonmeta reader. The keys unwrap the content from API envelopes, each
+// key if present, e.g. both a DataCite REST API response and a JSON:API record.
+func readJSON[T any](read func(T) (commonmeta.Data, error), keys ...string) func(b []byte) (commonmeta.Data, error) {
+	return func(b []byte) (commonmeta.Data, error) {
+		for _, key := range keys {
+			var envelope map[string]json.RawMessage
+			if err := json.Unmarshal(b, &envelope); err != nil {
+				return commonmeta.Data{}, err
+			}
+			if inner, ok := envelope[key]; ok {
+				b = inner
+			}
+		}
+		var content T
+		if err := json.Unmarshal(b, &content); err != nil {
+			return commonmeta.Data{}, err
+		}
+		return read(content)
+	}
+}
+
+// readCrossrefXML reads a Crossref UNIXSD query, optionally wrapped in the
+// crossref_result envelope returned by Crossref content negotiation
+func readCrossrefXML(b []byte) (commonmeta.Data, error) {
+	var query crossrefxml.Query
+	var result struct {
+		QueryResult struct {
+			Body struct {
+				Query crossrefxml.Query `xml:"query"`
+			} `xml:"body"`
+		} `xml:"query_result"`
+	}
+	root, err := xmlRoot(b)
+	if err != nil {
+		return commonmeta.Data{}, err
+	}
+	if root == "crossref_result" {
+		if err := xml.Unmarshal(b, &result); err != nil {
+			return commonmeta.Data{}, err
+		}
+		query = result.QueryResult.Body.Query
+	} else if err := xml.Unmarshal(b, &query); err != nil {
+		return commonmeta.Data{}, err
+	}
+	return crossrefxml.Read(query)
+}
+
+// xmlRoot returns the local name of the root element of an XML document
+func xmlRoot(b []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
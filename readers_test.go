@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+func TestReaderRegistryLookup(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input string
+		want  string
+	}
+	testCases := []testCase{
+		{input: "application/vnd.crossref+json", want: "crossref"},
+		{input: "application/vnd.crossref-api-message+json", want: "crossref"},
+		{input: "Application/JSON", want: "commonmeta"},
+		{input: "application/xml", want: "crossref-xml"},
+		{input: "application/x-unknown", want: ""},
+	}
+	for _, tc := range testCases {
+		got := ""
+		if rd, ok := Readers.Lookup(tc.input); ok {
+			got = rd.Name
+		}
+		if tc.want != got {
+			t.Errorf("Lookup reader(%v): want %v, got %v", tc.input, tc.want, got)
+		}
+	}
+
+	read := func(b []byte) (commonmeta.Data, error) { return commonmeta.Data{}, nil }
+	registry := NewReaderRegistry()
+	if err := registry.Register(Reader{Name: "plain", MediaType: "text/plain", Extension: "txt", Read: read}); err != nil {
+		t.Errorf("Register reader(plain): want no error, got %v", err)
+	}
+	if err := registry.Register(Reader{Name: "other", MediaType: "text/x-other", Extension: ".TXT", Read: read}); err == nil {
+		t.Errorf("Register reader(other): want conflict, got nil")
+	}
+	if err := registry.Register(Reader{Name: "noread", MediaType: "text/x-noread"}); err == nil {
+		t.Errorf("Register reader(noread): want error, got nil")
+	}
+}
+
+func TestReadCrossrefXML(t *testing.T) {
+	t.Parallel()
+
+	query := `<query status="resolved"><doi type="journal_article">10.7554/elife.01567</doi>
+<doi_record><crossref><journal><journal_metadata><full_title>eLife</full_title></journal_metadata>
+<journal_article><titles><title>Automated quantitative histology</title></titles>
+<publisher_item><item_number>e01567</item_number></publisher_item>
+<doi_data><doi>10.7554/elife.01567</doi><resource>https://elifesciences.org/articles/01567</resource></doi_data>
+</journal_article></journal></crossref></doi_record></query>`
+	result := `<crossref_result xmlns="http://www.crossref.org/qrschema/3.0" version="3.0"><query_result><head><doi_batch_id>none</doi_batch_id></head><body>` + query + `</body></query_result></crossref_result>`
+	for _, input := range []string{query, result} {
+		data, err := readCrossrefXML([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.ID != "https://doi.org/10.7554/elife.01567" {
+			t.Errorf("Read Crossref XML: want https://doi.org/10.7554/elife.01567, got %v", data.ID)
+		}
+	}
+
+	// the commonmeta reader panics without publisher_item
+	rd, _ := Readers.ByName("crossref-xml")
+	if _, err := rd.Parse([]byte(strings.Replace(query, "<publisher_item><item_number>e01567</item_number></publisher_item>", "", 1))); err == nil {
+		t.Errorf("Parse Crossref XML: want error for incomplete input, got nil")
+	}
+}
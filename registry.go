@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/labstack/echo/v5"
+)
+
+// Delivery describes how a writer delivers a work in its format
+type Delivery string
+
+const (
+	// Local writers generate the output from the stored metadata
+	Local Delivery = "local"
+	// Redirect writers redirect to the resource or to one of its files
+	Redirect Delivery = "redirect"
+	// Proxy writers hand the request to the DOI registration agency, using
+	// Crossref or DataCite link-based content negotiation
+	Proxy Delivery = "proxy"
+)
+
+// Writer produces a work in one format. Writers are registered with a
+// WriterRegistry, which drives content negotiation in the resolver.
+type Writer struct {
+	// Name is the short name of the format, e.g. bibtex
+	Name string `json:"name"`
+	// MediaType is the canonical media type, e.g. application/x-bibtex
+	MediaType string `json:"mediaType"`
+	// Aliases are other media types served by this writer
+	Aliases []string `json:"aliases,omitempty"`
+	// Extension is the file extension including the dot, e.g. .bib
+	Extension string `json:"extension,omitempty"`
+	// Description is a human-readable description of the format
+	Description string   `json:"description,omitempty"`
+	Delivery    Delivery `json:"delivery"`
+	// Types restricts the writer to the listed commonmeta types, all types if empty
+	Types []string `json:"types,omitempty"`
+	// Signposting lists the format in the describedby links of FAIR Signposting
+	Signposting bool `json:"signposting,omitempty"`
+	// ContentType of the response, defaults to the requested media type with charset utf-8
+	ContentType string `json:"-"`
+	// Filename suggested for the response in the Content-Disposition header
+	Filename string `json:"-"`
+
+	// Write generates the output of local writers
+	Write func(r *WriteRequest) ([]byte, error) `json:"-"`
+	// Location returns the redirect URL of redirect writers. Local writers can
+	// also set it to prefer a file attached to the work, falling back to Write
+	// when it returns an empty string.
+	Location func(r *WriteRequest) (string, error) `json:"-"`
+}
+
+// WriteRequest holds what a writer needs to produce a work
+type WriteRequest struct {
+	// Work is the stored record, nil for stateless conversions
+	Work *Work
+	Data commonmeta.Data
+	// MediaType that was requested, the canonical media type or an alias
+	MediaType string
+	// Params are the query parameters, falling back to the parameters of the
+	// requested media range, e.g. style and locale
+	Params map[string]string
+	// Files maps media types to the URLs of files attached to the work
+	Files map[string]string
+	// BaseURL is the scheme and host of the resolver, used for links to other formats
+	BaseURL string
+}
+
+// WriteError is returned by writers to respond with a specific status code.
+// Fields are added to the JSON error response.
+type WriteError struct {
+	Status  int
+	Message string
+	Fields  map[string]any
+}
+
+func (e *WriteError) Error() string {
+	return e.Message
+}
+
+// notAvailable returns a 406 error for a format that has no file attached to the work
+func notAvailable(format string) error {
+	return &WriteError{Status: http.StatusNotAcceptable, Message: format + " version not available"}
+}
+
+// invalidWork returns a 422 error for a work that a writer can't represent,
+// e.g. because it has no id
+func invalidWork(format string, a ...any) error {
+	return &WriteError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf(format, a...)}
+}
+
+// ResponseContentType returns the content type of the writer's response to a
+// request for the media type or one of its aliases
+func (w *Writer) ResponseContentType(mediaType string) string {
+	if w.ContentType != "" {
+		return w.ContentType
+	}
+	if mediaType == "" {
+		mediaType = w.MediaType
+	}
+	return mediaType + "; charset=utf-8"
+}
+
+// Supports checks whether the writer supports the commonmeta type of a work
+func (w *Writer) Supports(workType string) bool {
+	return len(w.Types) == 0 || slices.Contains(w.Types, workType)
+}
+
+// WriterRegistry is a registry of writers keyed by media type, alias,
+// extension and short name. Writers are kept in registration order, which
+// is the order of server preference in content negotiation.
+type WriterRegistry struct {
+	mu      sync.RWMutex
+	writers []*Writer
+	index   map[string]*Writer
+}
+
+// NewWriterRegistry returns an empty registry
+func NewWriterRegistry() *WriterRegistry {
+	return &WriterRegistry{index: make(map[string]*Writer)}
+}
+
+// Register adds a writer to the registry. The media type, aliases, extension
+// and short name must not be registered already.
+func (r *WriterRegistry) Register(w Writer) error {
+	if w.Name == "" || w.MediaType == "" {
+		return fmt.Errorf("writer needs a name and media type")
+	}
+	switch w.Delivery {
+	case Local:
+		if w.Write == nil {
+			return fmt.Errorf("local writer %s needs a Write function", w.Name)
+		}
+	case Redirect:
+		if w.Location == nil {
+			return fmt.Errorf("redirect writer %s needs a Location function", w.Name)
+		}
+	case Proxy:
+	default:
+		return fmt.Errorf("writer %s has unknown delivery %q", w.Name, w.Delivery)
+	}
+	w.MediaType = strings.ToLower(w.MediaType)
+	keys := []string{w.MediaType, "name:" + strings.ToLower(w.Name)}
+	w.Aliases = slices.Clone(w.Aliases)
+	for i, alias := range w.Aliases {
+		w.Aliases[i] = strings.ToLower(alias)
+		keys = append(keys, w.Aliases[i])
+	}
+	if w.Extension != "" {
+		if !strings.HasPrefix(w.Extension, ".") {
+			w.Extension = "." + w.Extension
+		}
+		w.Extension = strings.ToLower(w.Extension)
+		keys = append(keys, "ext:"+w.Extension)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		if existing, ok := r.index[key]; ok {
+			return fmt.Errorf("writer %s conflicts with %s on %s", w.Name, existing.Name, key)
+		}
+	}
+	r.writers = append(r.writers, &w)
+	for _, key := range keys {
+		r.index[key] = &w
+	}
+	return nil
+}
+
+// MustRegister adds a writer to the registry and panics on conflicts
+func (r *WriterRegistry) MustRegister(w Writer) {
+	if err := r.Register(w); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the writer for a media type or alias
+func (r *WriterRegistry) Lookup(mediaType string) (*Writer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	w, ok := r.index[strings.ToLower(mediaType)]
+	return w, ok
+}
+
+// ByName returns the writer with the given short name
+func (r *WriterRegistry) ByName(name string) (*Writer, bool) {
+	return r.Lookup("name:" + strings.ToLower(name))
+}
+
+// ByExtension returns the writer for a file extension, with or without the dot
+func (r *WriterRegistry) ByExtension(ext string) (*Writer, bool) {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return r.Lookup("ext:" + strings.ToLower(ext))
+}
+
+// SplitExtension splits a registered file extension from the end of a path,
+// e.g. 10.5555/abc.bib into 10.5555/abc and the writer for .bib
+func (r *WriterRegistry) SplitExtension(str string) (string, *Writer, bool) {
+	i := strings.LastIndex(str, ".")
+	if i <= 0 || strings.Contains(str[i:], "/") {
+		return str, nil, false
+	}
+	w, ok := r.ByExtension(str[i:])
+	if !ok {
+		return str, nil, false
+	}
+	return str[:i], w, true
+}
+
+// Writers returns all writers in registration order
+func (r *WriterRegistry) Writers() []*Writer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.writers)
+}
+
+// MediaTypes returns the media types and aliases of all writers, in order of
+// server preference
+func (r *WriterRegistry) MediaTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mediaTypes := make([]string, 0, len(r.writers))
+	for _, w := range r.writers {
+		mediaTypes = append(mediaTypes, w.MediaType)
+		mediaTypes = append(mediaTypes, w.Aliases...)
+	}
+	return mediaTypes
+}
+
+// ServeWriter responds with the output of a local or redirect writer. Errors
+// returned by the writer are sent as JSON, using the status of a WriteError.
+// Local output carries ETag and Last-Modified validators.
+func ServeWriter(c echo.Context, w *Writer, r *WriteRequest) error {
+	var location string
+	var out []byte
+	var err error
+	if w.Location != nil {
+		location, err = w.Location(r)
+	}
+	if err == nil && location == "" && w.Write != nil {
+		out, err = w.Write(r)
+	}
+	var werr *WriteError
+	if errors.As(err, &werr) {
+		if werr.Status == http.StatusNotAcceptable {
+			return notAcceptable(c, werr.Message)
+		}
+		body := map[string]any{"error": werr.Message}
+		for k, v := range werr.Fields {
+			body[k] = v
+		}
+		return c.JSON(werr.Status, body)
+	} else if err != nil {
+		log.Println("error:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if location != "" || w.Write == nil {
+		return c.Redirect(http.StatusFound, location)
+	}
+	if w.Filename != "" {
+		c.Response().Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", w.Filename))
+	}
+
+	// caching validators, answering conditional requests with 304 Not Modified
+	contentType := w.ResponseContentType(r.MediaType)
+	etag := ETag(contentType, out)
+	c.Response().Header().Set("ETag", etag)
+	if r.Work != nil {
+		c.Response().Header().Set(RevisionHeader, strconv.Itoa(r.Work.Revision))
+	}
+	var modified time.Time
+	if r.Work != nil && !r.Work.Updated.IsZero() {
+		modified = r.Work.Updated.Time().UTC()
+		c.Response().Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	}
+	if NotModified(c.Request(), etag, modified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, contentType, out)
+}
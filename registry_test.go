@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/labstack/echo/v5"
+)
+
+func TestWriterRegistryLookup(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input string
+		want  string
+	}
+	testCases := []testCase{
+		{input: "application/x-bibtex", want: "bibtex"},
+		{input: "Application/X-BibTeX", want: "bibtex"},
+		{input: "application/json", want: "commonmeta"},
+		{input: "application/xml", want: "jats"},
+		{input: "text/turtle", want: "turtle"},
+		{input: "application/vnd.crossref.unixref+xml", want: "crossref-unixref"},
+		{input: "application/x-unknown", want: ""},
+	}
+	for _, tc := range testCases {
+		got := ""
+		if w, ok := Writers.Lookup(tc.input); ok {
+			got = w.Name
+		}
+		if tc.want != got {
+			t.Errorf("Lookup writer(%v): want %v, got %v", tc.input, tc.want, got)
+		}
+	}
+
+	if w, ok := Writers.ByExtension("bib"); !ok || w.MediaType != "application/x-bibtex" {
+		t.Errorf("Lookup writer by extension: want application/x-bibtex, got %v", w)
+	}
+	if w, ok := Writers.ByName("ris"); !ok || w.MediaType != "application/x-research-info-systems" {
+		t.Errorf("Lookup writer by name: want application/x-research-info-systems, got %v", w)
+	}
+	if got := Writers.MediaTypes()[0]; got != "text/html" {
+		t.Errorf("Media types: want text/html first, got %v", got)
+	}
+}
+
+func TestWriterRegistryRegister(t *testing.T) {
+	t.Parallel()
+
+	write := func(r *WriteRequest) ([]byte, error) { return []byte(r.Data.ID), nil }
+	type testCase struct {
+		input   Writer
+		wantErr bool
+	}
+	testCases := []testCase{
+		{input: Writer{Name: "plain", MediaType: "text/plain", Extension: "txt", Delivery: Local, Write: write}},
+		{input: Writer{Name: "plain", MediaType: "text/x-plain", Delivery: Local, Write: write}, wantErr: true},
+		{input: Writer{Name: "other", MediaType: "TEXT/PLAIN", Delivery: Local, Write: write}, wantErr: true},
+		{input: Writer{Name: "other", MediaType: "text/x-other", Extension: ".txt", Delivery: Local, Write: write}, wantErr: true},
+		{input: Writer{Name: "nowrite", MediaType: "text/x-nowrite", Delivery: Local}, wantErr: true},
+		{input: Writer{Name: "nolocation", MediaType: "text/x-nolocation", Delivery: Redirect}, wantErr: true},
+		{input: Writer{Name: "unknown", MediaType: "text/x-unknown", Delivery: "copy", Write: write}, wantErr: true},
+	}
+	registry := NewWriterRegistry()
+	for _, tc := range testCases {
+		err := registry.Register(tc.input)
+		if tc.wantErr != (err != nil) {
+			t.Errorf("Register writer(%v): want error %v, got %v", tc.input.Name, tc.wantErr, err)
+		}
+	}
+	if w, ok := registry.ByExtension(".txt"); !ok || w.Name != "plain" {
+		t.Errorf("Lookup writer by extension: want plain, got %v", w)
+	}
+}
+
+func TestServeWriter(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		mediaType   string
+		data        commonmeta.Data
+		files       map[string]string
+		params      map[string]string
+		wantStatus  int
+		contentType string
+		location    string
+	}
+	article := commonmeta.Data{ID: "https://doi.org/10.7554/elife.01567", Type: "JournalArticle", URL: "https://elifesciences.org/articles/01567", Titles: []commonmeta.Title{{Title: "Automated quantitative histology"}}}
+	testCases := []testCase{
+		{mediaType: "application/x-bibtex", data: article, wantStatus: http.StatusOK, contentType: "application/x-bibtex; charset=utf-8"},
+		{mediaType: "application/xml", data: article, wantStatus: http.StatusOK, contentType: "application/xml; charset=utf-8"},
+		{mediaType: "application/xml", data: article, files: map[string]string{"application/xml": "https://example.org/article.xml"}, wantStatus: http.StatusFound, location: "https://example.org/article.xml"},
+		{mediaType: "text/html", data: article, wantStatus: http.StatusFound, location: "https://elifesciences.org/articles/01567"},
+		{mediaType: "text/html", data: article, params: map[string]string{"landing": ""}, wantStatus: http.StatusOK, contentType: "text/html; charset=utf-8"},
+		{mediaType: "text/html", data: commonmeta.Data{ID: "https://example.org/works/1", Type: "Dataset"}, wantStatus: http.StatusOK, contentType: "text/html; charset=utf-8"},
+		{mediaType: "application/pdf", data: article, wantStatus: http.StatusNotAcceptable},
+		{mediaType: "application/vnd.datacite.datacite+xml", data: article, wantStatus: http.StatusUnprocessableEntity},
+		{mediaType: "text/turtle", data: commonmeta.Data{ID: "10.7554/elife.01567"}, wantStatus: http.StatusUnprocessableEntity},
+		{mediaType: "application/x-bibtex", data: commonmeta.Data{}, wantStatus: http.StatusUnprocessableEntity},
+		{mediaType: "application/mods+xml", data: commonmeta.Data{}, wantStatus: http.StatusUnprocessableEntity},
+	}
+	e := echo.New()
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+		w, _ := Writers.Lookup(tc.mediaType)
+		if tc.params == nil {
+			tc.params = map[string]string{}
+		}
+		err := ServeWriter(c, w, &WriteRequest{Data: tc.data, MediaType: tc.mediaType, Params: tc.params, Files: tc.files})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantStatus != rec.Code {
+			t.Errorf("Serve writer(%v): want status %v, got %v", tc.mediaType, tc.wantStatus, rec.Code)
+		}
+		if tc.contentType != "" && tc.contentType != rec.Header().Get("Content-Type") {
+			t.Errorf("Serve writer(%v): want Content-Type %v, got %v", tc.mediaType, tc.contentType, rec.Header().Get("Content-Type"))
+		}
+		if tc.location != rec.Header().Get("Location") {
+			t.Errorf("Serve writer(%v): want Location %v, got %v", tc.mediaType, tc.location, rec.Header().Get("Location"))
+		}
+		if rec.Code == http.StatusUnprocessableEntity {
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := body["error"]; !ok {
+				t.Errorf("Serve writer(%v): want error, got %v", tc.mediaType, body)
+			}
+			if _, ok := body["missing"]; !ok && tc.mediaType == "application/vnd.datacite.datacite+xml" {
+				t.Errorf("Serve writer(%v): want missing properties, got %v", tc.mediaType, body)
+			}
+		}
+	}
+}
+
+func TestWriterRegistrySplitExtension(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input string
+		base  string
+		want  string
+	}
+	testCases := []testCase{
+		{input: "10.7554/elife.01567.bib", base: "10.7554/elife.01567", want: "bibtex"},
+		{input: "10.7554/elife.01567.RIS", base: "10.7554/elife.01567", want: "ris"},
+		{input: "10.7554/elife.01567.ttl", base: "10.7554/elife.01567", want: "turtle"},
+		{input: "10.7554/elife.01567", base: "10.7554/elife.01567", want: ""},
+		{input: "10.5281/zenodo.1234", base: "10.5281/zenodo.1234", want: ""},
+		{input: "example.org/works.json/1", base: "example.org/works.json/1", want: ""},
+		{input: ".json", base: ".json", want: ""},
+	}
+	for _, tc := range testCases {
+		base, w, ok := Writers.SplitExtension(tc.input)
+		got := ""
+		if ok {
+			got = w.Name
+		}
+		if tc.want != got || tc.base != base {
+			t.Errorf("Split extension(%v): want %v %v, got %v %v", tc.input, tc.base, tc.want, base, got)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// RevisionConflictError is returned when a work is saved with another
+// revision than the stored one, i.e. it was changed since it was read
+type RevisionConflictError struct {
+	Pid      string
+	Revision int
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("%s was changed since revision %d", e.Pid, e.Revision)
+}
+
+// CheckRevisionHook rejects updates of works read at an older revision and
+// increments the revision saved with the update, for works saved with the
+// write API, the admin UI and imports alike. The stored revision is checked
+// with a conditional UPDATE that changes nothing, so a failed save leaves the
+// revision as it was. The UPDATE takes the write lock of the database, so
+// that the check and the save can't interleave with other writers when they
+// run in one transaction, as in the write API and imports.
+func CheckRevisionHook(e *core.ModelEvent) error {
+	var pid string
+	var revision int
+	switch m := e.Model.(type) {
+	case *Work:
+		pid, revision = m.Pid, m.Revision
+	case *models.Record:
+		pid, revision = m.GetString("pid"), m.GetInt("revision")
+	default:
+		return nil
+	}
+	result, err := e.Dao.NonconcurrentDB().
+		NewQuery("UPDATE {{works}} SET [[revision]] = [[revision]] WHERE [[id]] = {:id} AND [[revision]] = {:revision}").
+		Bind(dbx.Params{"id": e.Model.GetId(), "revision": revision}).
+		Execute()
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &RevisionConflictError{Pid: pid, Revision: revision}
+	}
+	switch m := e.Model.(type) {
+	case *Work:
+		m.Revision = revision + 1
+	case *models.Record:
+		m.Set("revision", revision+1)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+// CMToRISMappings maps the commonmeta types used in the works collection to RIS types
+var CMToRISMappings = map[string]string{
+	"Article":               "JOUR",
+	"JournalArticle":        "JOUR",
+	"ProceedingsArticle":    "CPAPER",
+	"Software":              "COMP",
+	"Dataset":               "DATA",
+	"Database":              "DBASE",
+	"Document":              "GEN",
+	"ComputationalNotebook": "COMP",
+	"Book":                  "BOOK",
+	"BookChapter":           "CHAP",
+	"BookSection":           "CHAP",
+	"Report":                "RPRT",
+	"Collection":            "CTLG",
+	"Presentation":          "SLIDE",
+	"Other":                 "GEN",
+	"Image":                 "FIGURE",
+	"Component":             "GEN",
+	"Standard":              "STAND",
+	"WebPage":               "WEB",
+	"Audiovisual":           "VIDEO",
+}
+
+// WriteRIS converts commonmeta metadata to a RIS record.
+func WriteRIS(data commonmeta.Data) (string, error) {
+	if data.ID == "" {
+		return "", invalidWork("missing id")
+	}
+	ty := CMToRISMappings[data.Type]
+	if ty == "" {
+		ty = "GEN"
+	}
+
+	var b strings.Builder
+	add := func(tag string, value string) {
+		// RIS values can't span multiple lines
+		value = strings.Join(strings.Fields(stripTags(value)), " ")
+		if value != "" {
+			fmt.Fprintf(&b, "%s  - %s\n", tag, value)
+		}
+	}
+
+	add("TY", ty)
+	for _, c := range authors(data) {
+		if c.FamilyName != "" {
+			name := c.FamilyName
+			if c.GivenName != "" {
+				name += ", " + c.GivenName
+			}
+			add("AU", name)
+		} else {
+			add("AU", c.Name)
+		}
+	}
+	add("TI", mainTitle(data))
+	add("T2", data.Container.Title)
+	add("PY", publicationYear(data))
+	if date := publicationDate(data); len(date) >= 10 {
+		// RIS dates are formatted as YYYY/MM/DD/
+		add("DA", strings.ReplaceAll(date[:10], "-", "/")+"/")
+	}
+	add("VL", data.Container.Volume)
+	add("IS", data.Container.Issue)
+	add("SP", data.Container.FirstPage)
+	add("EP", data.Container.LastPage)
+	add("PB", data.Publisher.Name)
+	if data.Container.IdentifierType == "ISSN" {
+		add("SN", data.Container.Identifier)
+	}
+	add("ET", data.Version)
+	add("DO", doiFromPid(data.ID))
+	add("UR", data.URL)
+	add("AB", abstract(data))
+	for _, k := range keywords(data) {
+		add("KW", k)
+	}
+	add("LA", data.Language)
+	b.WriteString("ER  - \n")
+	return b.String(), nil
+}
+
+// RISToCMMappings maps RIS types to commonmeta types
+var RISToCMMappings = map[string]string{
+	"JOUR":    "JournalArticle",
+	"JFULL":   "JournalArticle",
+	"EJOUR":   "JournalArticle",
+	"MGZN":    "Article",
+	"NEWS":    "Article",
+	"BLOG":    "BlogPost",
+	"CPAPER":  "ProceedingsArticle",
+	"CONF":    "Proceedings",
+	"BOOK":    "Book",
+	"EBOOK":   "Book",
+	"EDBOOK":  "Book",
+	"CHAP":    "BookChapter",
+	"ECHAP":   "BookChapter",
+	"RPRT":    "Report",
+	"THES":    "Dissertation",
+	"COMP":    "Software",
+	"DATA":    "Dataset",
+	"DBASE":   "Database",
+	"CTLG":    "Collection",
+	"SLIDE":   "Presentation",
+	"FIGURE":  "Image",
+	"STAND":   "Standard",
+	"WEB":     "WebPage",
+	"ELEC":    "WebPage",
+	"VIDEO":   "Audiovisual",
+	"UNPB":    "Document",
+	"MANSCPT": "Document",
+	"GEN":     "Other",
+}
+
+var risTagRegexp = regexp.MustCompile(`^([A-Z][A-Z0-9])  -(?: (.*))?$`)
+
+// ReadRISAll reads the records of a RIS file. Lines without tag continue
+// the value of the previous tag.
+func ReadRISAll(b []byte) ([]ReadResult, error) {
+	results := make([]ReadResult, 0)
+	var record map[string][]string
+	var raw []string
+	var last string
+	flush := func() {
+		if record != nil {
+			result := ReadResult{Raw: []byte(strings.Join(raw, "\n")), Data: readRISRecord(record)}
+			if ids := record["ID"]; len(ids) > 0 {
+				result.Key = ids[0]
+			}
+			results = append(results, result)
+		}
+		record, raw, last = nil, nil, ""
+	}
+	for _, line := range strings.Split(strings.TrimPrefix(string(b), "\ufeff"), "\n") {
+		line = strings.TrimRight(line, "\r ")
+		m := risTagRegexp.FindStringSubmatch(line)
+		if m == nil {
+			if record != nil && last != "" && strings.TrimSpace(line) != "" {
+				values := record[last]
+				values[len(values)-1] += " " + strings.TrimSpace(line)
+				raw = append(raw, line)
+			}
+			continue
+		}
+		tag, value := m[1], strings.TrimSpace(m[2])
+		switch {
+		case tag == "TY":
+			flush()
+			record = make(map[string][]string)
+		case record == nil:
+			continue
+		}
+		raw = append(raw, line)
+		if tag == "ER" {
+			flush()
+			continue
+		}
+		record[tag] = append(record[tag], value)
+		last = tag
+	}
+	flush()
+	return results, nil
+}
+
+// readRISRecord converts the tags of a RIS record to commonmeta
+func readRISRecord(record map[string][]string) commonmeta.Data {
+	first := func(tags ...string) string {
+		for _, tag := range tags {
+			for _, v := range record[tag] {
+				if v != "" {
+					return v
+				}
+			}
+		}
+		return ""
+	}
+	data := commonmeta.Data{
+		ID:   workPid(first("DO"), first("UR")),
+		Type: RISToCMMappings[first("TY")],
+		URL:  first("UR"),
+	}
+	if data.Type == "" {
+		data.Type = "Other"
+	}
+	for _, tag := range []string{"AU", "A1", "A2", "ED"} {
+		role := "Author"
+		if tag == "A2" || tag == "ED" {
+			role = "Editor"
+		}
+		for _, name := range record[tag] {
+			if name != "" {
+				data.Contributors = append(data.Contributors, contributorFromName(name, role))
+			}
+		}
+	}
+	if title := first("TI", "T1"); title != "" {
+		data.Titles = append(data.Titles, commonmeta.Title{Title: title})
+	}
+
+	container := commonmeta.Container{
+		Title:     first("T2", "JF", "JO", "JA", "BT"),
+		Volume:    first("VL"),
+		Issue:     first("IS"),
+		FirstPage: first("SP"),
+		LastPage:  first("EP"),
+	}
+	if container.LastPage == "" {
+		container.FirstPage, container.LastPage = pageRange(container.FirstPage)
+	}
+	switch data.Type {
+	case "JournalArticle":
+		container.Type = "Journal"
+	case "ProceedingsArticle":
+		container.Type = "Proceedings"
+	case "BookChapter":
+		container.Type = "Book"
+	}
+	if container.Title == "" {
+		container.Type = ""
+	}
+	if sn := first("SN"); sn != "" {
+		if data.Type == "Book" || data.Type == "BookChapter" {
+			data.Identifiers = append(data.Identifiers, commonmeta.Identifier{Identifier: sn, IdentifierType: "ISBN"})
+		} else {
+			container.Identifier, container.IdentifierType = sn, "ISSN"
+		}
+	}
+	data.Container = container
+
+	if publisher := first("PB"); publisher != "" {
+		data.Publisher = commonmeta.Publisher{Name: publisher}
+	}
+	data.Date.Published = isoDate(first("DA"))
+	if year := isoDate(first("PY", "Y1")); len(year) >= 4 && !strings.HasPrefix(data.Date.Published, year[:4]) {
+		data.Date.Published = year
+	}
+	if abstract := first("AB", "N2"); abstract != "" {
+		data.Descriptions = append(data.Descriptions, commonmeta.Description{Description: abstract, Type: "Abstract"})
+	}
+	if subjects := subjectsFromKeywords(record["KW"]...); len(subjects) > 0 {
+		data.Subjects = subjects
+	}
+	data.Language = first("LA")
+	data.Version = first("ET")
+	return data
+}
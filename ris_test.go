@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+func TestWriteRIS(t *testing.T) {
+	t.Parallel()
+
+	data := commonmeta.Data{
+		ID:   "https://doi.org/10.7554/elife.01567",
+		Type: "JournalArticle",
+		Contributors: []commonmeta.Contributor{
+			{Type: "Person", GivenName: "Martial", FamilyName: "Sankar", ContributorRoles: []string{"Author"}},
+			{Type: "Person", GivenName: "Kaisa", FamilyName: "Nieminen", ContributorRoles: []string{"Editor"}},
+		},
+		Titles:       []commonmeta.Title{{Title: "Automated quantitative histology reveals vascular morphodynamics during <i>Arabidopsis</i> hypocotyl secondary growth"}},
+		Container:    commonmeta.Container{Title: "eLife", Identifier: "2050-084X", IdentifierType: "ISSN", Volume: "3"},
+		Publisher:    commonmeta.Publisher{Name: "eLife Sciences Publications, Ltd"},
+		Date:         commonmeta.Date{Published: "2014-02-11"},
+		Descriptions: []commonmeta.Description{{Description: "Among the most\nstriking aspects", Type: "Abstract"}},
+		Subjects:     []commonmeta.Subject{{Subject: "Plant Biology"}, {Subject: "Cell Biology"}},
+		URL:          "https://elifesciences.org/articles/01567",
+		Language:     "en",
+	}
+	want := `TY  - JOUR
+AU  - Sankar, Martial
+TI  - Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth
+T2  - eLife
+PY  - 2014
+DA  - 2014/02/11/
+VL  - 3
+PB  - eLife Sciences Publications, Ltd
+SN  - 2050-084X
+DO  - 10.7554/elife.01567
+UR  - https://elifesciences.org/articles/01567
+AB  - Among the most striking aspects
+KW  - Plant Biology
+KW  - Cell Biology
+LA  - en
+ER  - 
+`
+	got, err := WriteRIS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != got {
+		t.Errorf("Write RIS: want\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestReadRISAll(t *testing.T) {
+	t.Parallel()
+
+	input := "\ufeffTY  - JOUR\r\n" +
+		"AU  - Sankar, Martial\r\n" +
+		"A1  - Nieminen, Kaisa\r\n" +
+		"ED  - Hardtke, Christian S\r\n" +
+		"TI  - Automated quantitative histology reveals vascular morphodynamics\r\n" +
+		"during Arabidopsis hypocotyl secondary growth\r\n" +
+		"T2  - eLife\r\n" +
+		"SN  - 2050-084X\r\n" +
+		"VL  - 3\r\n" +
+		"SP  - e01567\r\n" +
+		"PY  - 2014\r\n" +
+		"DA  - 2014/02/11\r\n" +
+		"DO  - 10.7554/eLife.01567\r\n" +
+		"KW  - plant biology; computational biology\r\n" +
+		"ER  - \r\n" +
+		"\r\n" +
+		"TY  - BOOK\n" +
+		"AU  - Fenner, Martin\n" +
+		"TI  - A book\n" +
+		"SN  - 978-3-16-148410-0\n" +
+		"PY  - 2020///\n" +
+		"UR  - https://example.org/books/1\n" +
+		"ER  -\n"
+	results, err := ReadRISAll([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Read RIS: want 2 records, got %v", len(results))
+	}
+
+	article := results[0].Data
+	if article.ID != "https://doi.org/10.7554/elife.01567" || article.Type != "JournalArticle" {
+		t.Errorf("Read RIS: want eLife article, got %v %v", article.ID, article.Type)
+	}
+	if len(article.Contributors) != 3 || article.Contributors[2].ContributorRoles[0] != "Editor" {
+		t.Errorf("Read RIS: want 2 authors and an editor, got %v", article.Contributors)
+	}
+	if want := "Automated quantitative histology reveals vascular morphodynamics during Arabidopsis hypocotyl secondary growth"; mainTitle(article) != want {
+		t.Errorf("Read RIS: want %v, got %v", want, mainTitle(article))
+	}
+	if c := article.Container; c.Title != "eLife" || c.Identifier != "2050-084X" || c.FirstPage != "e01567" {
+		t.Errorf("Read RIS: want container eLife, got %v", c)
+	}
+	if article.Date.Published != "2014-02-11" || len(article.Subjects) != 2 {
+		t.Errorf("Read RIS: want date and subjects, got %v %v", article.Date.Published, article.Subjects)
+	}
+
+	book := results[1].Data
+	if book.ID != "https://example.org/books/1" || book.Type != "Book" || book.Date.Published != "2020" {
+		t.Errorf("Read RIS: want book with pid from URL, got %v %v %v", book.ID, book.Type, book.Date.Published)
+	}
+	if len(book.Identifiers) != 1 || book.Identifiers[0].IdentifierType != "ISBN" {
+		t.Errorf("Read RIS: want ISBN, got %v", book.Identifiers)
+	}
+}
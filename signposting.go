@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/schemaorg"
+)
+
+// Signpost is a typed link of FAIR Signposting, see https://signposting.org/FAIR/
+type Signpost struct {
+	Rel  string `json:"-"`
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+// String returns the signpost as link-value of a Link header, as defined in RFC 8288
+func (s Signpost) String() string {
+	link := fmt.Sprintf(`<%s>; rel="%s"`, s.Href, s.Rel)
+	if s.Type != "" {
+		link += fmt.Sprintf(`; type="%s"`, s.Type)
+	}
+	return link
+}
+
+// ResolverURL returns the URL of a work in the format of a writer at the
+// resolver at baseURL, selected with the format query parameter. Unlike
+// link-based content negotiation, this works for DOIs and URLs alike.
+func ResolverURL(baseURL string, pid string, format string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + pathFromPid(pid) + "?format=" + url.QueryEscape(format)
+}
+
+// Signposts returns the signposts of a work: cite-as, type, author, license,
+// item for each file, and describedby for each metadata format. The
+// describedby and linkset links are omitted without the baseURL of the resolver.
+func Signposts(data commonmeta.Data, baseURL string) []Signpost {
+	signposts := make([]Signpost, 0)
+	if data.ID == "" {
+		return signposts
+	}
+	signposts = append(signposts, Signpost{Rel: "cite-as", Href: data.ID})
+	schemaType := schemaorg.CMToSOMappings[data.Type]
+	if schemaType == "" {
+		schemaType = "CreativeWork"
+	}
+	signposts = append(signposts, Signpost{Rel: "type", Href: "https://schema.org/" + schemaType})
+	for _, a := range authors(data) {
+		if strings.HasPrefix(a.ID, "https://orcid.org/") {
+			signposts = append(signposts, Signpost{Rel: "author", Href: a.ID})
+		}
+	}
+	if license := licenseURL(data); license != "" {
+		signposts = append(signposts, Signpost{Rel: "license", Href: license})
+	}
+	for _, f := range data.Files {
+		if f.URL != "" {
+			signposts = append(signposts, Signpost{Rel: "item", Href: f.URL, Type: f.MimeType})
+		}
+	}
+	if baseURL == "" {
+		return signposts
+	}
+	for _, w := range Writers.Writers() {
+		if w.Signposting && w.Supports(data.Type) {
+			signposts = append(signposts, Signpost{Rel: "describedby", Href: ResolverURL(baseURL, data.ID, w.Name), Type: w.MediaType})
+		}
+	}
+	signposts = append(signposts, Signpost{Rel: "linkset", Href: ResolverURL(baseURL, data.ID, "linkset"), Type: LinksetMediaType})
+	return signposts
+}
+
+// LinksetMediaType is the media type of a linkset in JSON format
+const LinksetMediaType = "application/linkset+json"
+
+// WriteLinkset returns the signposts of a work as linkset in JSON format, as
+// defined in RFC 9264. The work is the anchor of all links.
+func WriteLinkset(r *WriteRequest) ([]byte, error) {
+	context := map[string]any{"anchor": r.Data.ID}
+	for _, s := range Signposts(r.Data, r.BaseURL) {
+		if s.Rel == "linkset" {
+			continue
+		}
+		targets, _ := context[s.Rel].([]Signpost)
+		context[s.Rel] = append(targets, s)
+	}
+	return json.Marshal(map[string]any{"linkset": []any{context}})
+}
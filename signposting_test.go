@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+)
+
+func TestSignposts(t *testing.T) {
+	t.Parallel()
+
+	data := catalogData
+	data.Files = []commonmeta.File{{URL: "https://elifesciences.org/articles/01567.pdf", MimeType: "application/pdf"}}
+	got := make([]string, 0)
+	for _, s := range Signposts(data, "https://commonmeta.org") {
+		got = append(got, s.String())
+	}
+	want := []string{
+		`<https://doi.org/10.7554/elife.01567>; rel="cite-as"`,
+		`<https://schema.org/ScholarlyArticle>; rel="type"`,
+		`<https://orcid.org/0000-0002-8419-5237>; rel="author"`,
+		`<https://creativecommons.org/licenses/by/3.0/legalcode>; rel="license"`,
+		`<https://elifesciences.org/articles/01567.pdf>; rel="item"; type="application/pdf"`,
+		`<https://commonmeta.org/10.7554/elife.01567?format=bibtex>; rel="describedby"; type="application/x-bibtex"`,
+		`<https://commonmeta.org/10.7554/elife.01567?format=datacite-xml>; rel="describedby"; type="application/vnd.datacite.datacite+xml"`,
+		`<https://commonmeta.org/10.7554/elife.01567?format=linkset>; rel="linkset"; type="application/linkset+json"`,
+	}
+	for _, w := range want {
+		if !slices.Contains(got, w) {
+			t.Errorf("Signposts: want %v, got %v", w, got)
+		}
+	}
+	// Codemeta is only available for software, the landing page is not metadata
+	for _, notWant := range []string{"application/vnd.codemeta.ld+json", "text/html", "text/x-bibliography"} {
+		for _, s := range Signposts(data, "https://commonmeta.org") {
+			if s.Type == notWant {
+				t.Errorf("Signposts: want no %v, got %v", notWant, s)
+			}
+		}
+	}
+}
+
+func TestResolverURL(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		pid  string
+		want string
+	}
+	testCases := []testCase{
+		{pid: "https://doi.org/10.7554/elife.01567", want: "https://commonmeta.org/10.7554/elife.01567?format=turtle"},
+		{pid: "https://example.org/works/1", want: "https://commonmeta.org/example.org/works/1?format=turtle"},
+	}
+	for _, tc := range testCases {
+		got := ResolverURL("https://commonmeta.org/", tc.pid, "turtle")
+		if tc.want != got {
+			t.Errorf("Resolver URL(%v): want %v, got %v", tc.pid, tc.want, got)
+		}
+	}
+}
+
+func TestSignpostsResolve(t *testing.T) {
+	t.Parallel()
+
+	// the describedby and linkset links of works with DOI or URL as pid lead
+	// back to the work in the format of the link
+	for _, pid := range []string{"https://doi.org/10.7554/elife.01567", "https://blog.example.org/posts/1"} {
+		data := commonmeta.Data{ID: pid, Type: "BlogPost"}
+		for _, s := range Signposts(data, "https://commonmeta.org") {
+			if s.Rel != "describedby" && s.Rel != "linkset" {
+				continue
+			}
+			u, err := url.Parse(s.Href)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := pidFromPath(u.Path[1:]); got != pid {
+				t.Errorf("Signposts(%v): want %v resolved to %v, got %v", pid, s.Href, pid, got)
+			}
+			w, ok := Writers.ByName(u.Query().Get("format"))
+			if !ok || w.MediaType != s.Type {
+				t.Errorf("Signposts(%v): want %v resolved to format %v, got %v", pid, s.Href, s.Type, u.Query().Get("format"))
+			}
+		}
+	}
+}
+
+func TestWriteLinkset(t *testing.T) {
+	t.Parallel()
+
+	out, err := WriteLinkset(&WriteRequest{Data: catalogData, BaseURL: "https://commonmeta.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var linkset struct {
+		Linkset []map[string]json.RawMessage `json:"linkset"`
+	}
+	if err := json.Unmarshal(out, &linkset); err != nil {
+		t.Fatal(err)
+	}
+	if len(linkset.Linkset) != 1 {
+		t.Fatalf("Write linkset: want 1 link context, got %v", len(linkset.Linkset))
+	}
+	context := linkset.Linkset[0]
+	if got := string(context["anchor"]); got != `"https://doi.org/10.7554/elife.01567"` {
+		t.Errorf("Write linkset: want anchor https://doi.org/10.7554/elife.01567, got %v", got)
+	}
+	if got := string(context["cite-as"]); got != `[{"href":"https://doi.org/10.7554/elife.01567"}]` {
+		t.Errorf("Write linkset: want cite-as, got %v", got)
+	}
+	var describedby []Signpost
+	if err := json.Unmarshal(context["describedby"], &describedby); err != nil {
+		t.Fatal(err)
+	}
+	if len(describedby) < 10 || describedby[0].Type == "" {
+		t.Errorf("Write linkset: want describedby links with type, got %v", describedby)
+	}
+	if _, ok := context["linkset"]; ok {
+		t.Errorf("Write linkset: want no link to itself, got %v", string(context["linkset"]))
+	}
+}
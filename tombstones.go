@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// ensures that the Tombstone struct satisfy the models.Model interface
+var _ models.Model = (*Tombstone)(nil)
+
+// Tombstone marks the pid of a withdrawn or deleted work, one record per pid.
+// Tombstoned pids are gone from the resolver, and works with their pid can't
+// be created again until the tombstone is removed.
+type Tombstone struct {
+	models.BaseModel
+
+	Pid    string         `db:"pid" json:"pid"`
+	Reason string         `db:"reason" json:"reason"`
+	Date   types.DateTime `db:"date" json:"date"`
+	// Successor is the pid of the work replacing the withdrawn work, if any
+	Successor string `db:"successor" json:"successor"`
+}
+
+func (m *Tombstone) TableName() string {
+	return "tombstones"
+}
+
+// SaveTombstone tombstones a pid, replacing an earlier tombstone
+func SaveTombstone(dao *daos.Dao, pid string, reason string, successor string) (*Tombstone, error) {
+	tombstone, err := FindTombstoneByPid(dao, pid)
+	if err != nil {
+		return nil, err
+	}
+	if tombstone == nil {
+		tombstone = &Tombstone{Pid: pid}
+	}
+	tombstone.Reason = reason
+	tombstone.Date = types.NowDateTime()
+	tombstone.Successor = successor
+	return tombstone, dao.Save(tombstone)
+}
+
+// find the tombstone of a pid
+func FindTombstoneByPid(dao *daos.Dao, pid string) (*Tombstone, error) {
+	tombstone := &Tombstone{}
+
+	err := dao.ModelQuery(&Tombstone{}).
+		// case insensitive match
+		AndWhere(dbx.NewExp("LOWER(pid)={:pid}", dbx.Params{
+			"pid": strings.ToLower(pid),
+		})).
+		Limit(1).
+		One(tombstone)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return tombstone, nil
+}
+
+// TombstonedError is returned when creating a work with a tombstoned pid
+type TombstonedError struct {
+	Tombstone *Tombstone
+}
+
+func (e *TombstonedError) Error() string {
+	return fmt.Sprintf("%s is tombstoned: %s", e.Tombstone.Pid, e.Tombstone.Reason)
+}
+
+// modelPid returns the pid of a work saved as Work or as record in the admin UI
+func modelPid(m models.Model) (string, bool) {
+	switch m := m.(type) {
+	case *Work:
+		return m.Pid, true
+	case *models.Record:
+		return m.GetString("pid"), true
+	}
+	return "", false
+}
+
+// RejectTombstonedHook rejects the creation of works with a tombstoned pid,
+// so that neither lazy fetching nor imports bring them back
+func RejectTombstonedHook(e *core.ModelEvent) error {
+	pid, ok := modelPid(e.Model)
+	if !ok {
+		return nil
+	}
+	tombstone, err := FindTombstoneByPid(e.Dao, pid)
+	if err != nil {
+		return err
+	} else if tombstone != nil {
+		return &TombstonedError{Tombstone: tombstone}
+	}
+	return nil
+}
+
+// TombstoneDeletedHook tombstones the pid of deleted works, unless it is
+// tombstoned already, e.g. with the reason given to the write API
+func TombstoneDeletedHook(e *core.ModelEvent) error {
+	pid, ok := modelPid(e.Model)
+	if !ok {
+		return nil
+	}
+	tombstone, err := FindTombstoneByPid(e.Dao, pid)
+	if err != nil || tombstone != nil {
+		return err
+	}
+	_, err = SaveTombstone(e.Dao, pid, "deleted", "")
+	return err
+}
+
+// ServeTombstone responds with 410 Gone and the tombstone of a pid, or
+// redirects permanently to the successor of the withdrawn work
+func ServeTombstone(c echo.Context, tombstone *Tombstone) error {
+	if tombstone.Successor != "" {
+		location := c.Scheme() + "://" + c.Request().Host + "/" + pathFromPid(tombstone.Successor)
+		if query := c.QueryString(); query != "" {
+			location += "?" + query
+		}
+		return c.Redirect(http.StatusMovedPermanently, location)
+	}
+	return c.JSON(http.StatusGone, tombstoneResponse(tombstone))
+}
+
+// tombstoneResponse returns the body of 410 Gone responses for a tombstone
+func tombstoneResponse(tombstone *Tombstone) map[string]string {
+	body := map[string]string{
+		"error":  "Gone",
+		"id":     tombstone.Pid,
+		"reason": tombstone.Reason,
+		"date":   tombstone.Date.Time().UTC().Format(time.DateOnly),
+	}
+	if tombstone.Successor != "" {
+		body["successor"] = tombstone.Successor
+	}
+	return body
+}
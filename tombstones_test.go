@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestServeTombstone(t *testing.T) {
+	t.Parallel()
+
+	date, _ := types.ParseDateTime("2024-05-01 10:00:00.000Z")
+	type testCase struct {
+		target       string
+		tombstone    Tombstone
+		wantStatus   int
+		wantLocation string
+		wantBody     map[string]string
+	}
+	testCases := []testCase{
+		{
+			target:     "/10.5555/12345678",
+			tombstone:  Tombstone{Pid: "https://doi.org/10.5555/12345678", Reason: "retracted", Date: date},
+			wantStatus: http.StatusGone,
+			wantBody:   map[string]string{"error": "Gone", "id": "https://doi.org/10.5555/12345678", "reason": "retracted", "date": "2024-05-01"},
+		},
+		{
+			target:       "/10.5555/12345678?format=bibtex",
+			tombstone:    Tombstone{Pid: "https://doi.org/10.5555/12345678", Reason: "duplicate", Date: date, Successor: "https://doi.org/10.5555/87654321"},
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "http://example.com/10.5555/87654321?format=bibtex",
+		},
+		{
+			target:       "/blog.example.org/posts/1",
+			tombstone:    Tombstone{Pid: "https://blog.example.org/posts/1", Reason: "moved", Date: date, Successor: "https://blog.example.org/posts/2"},
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "http://example.com/blog.example.org/posts/2",
+		},
+	}
+	e := echo.New()
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		rec := httptest.NewRecorder()
+		if err := ServeTombstone(e.NewContext(req, rec), &tc.tombstone); err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantStatus != rec.Code {
+			t.Errorf("ServeTombstone(%v): want status %v, got %v", tc.target, tc.wantStatus, rec.Code)
+		}
+		if location := rec.Header().Get("Location"); tc.wantLocation != location {
+			t.Errorf("ServeTombstone(%v): want Location %v, got %v", tc.target, tc.wantLocation, location)
+		}
+		if tc.wantBody == nil {
+			continue
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range tc.wantBody {
+			if body[k] != v {
+				t.Errorf("ServeTombstone(%v): want %v %v, got %v", tc.target, k, v, body[k])
+			}
+		}
+		if _, ok := body["successor"]; ok {
+			t.Errorf("ServeTombstone(%v): want no successor, got %v", tc.target, body["successor"])
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v5"
+	"github.com/spf13/cobra"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// commonmetaSchemas are the published commonmeta JSON Schemas, one file per version
+//
+//go:embed docs/commonmeta_v*.json
+var commonmetaSchemas embed.FS
+
+// SchemaVersions returns the versions of the commonmeta JSON Schema, oldest first
+func SchemaVersions() []string {
+	entries, _ := commonmetaSchemas.ReadDir("docs")
+	versions := make([]string, 0, len(entries))
+	for _, e := range entries {
+		v := strings.TrimSuffix(strings.TrimPrefix(e.Name(), "commonmeta_v"), ".json")
+		versions = append(versions, v)
+	}
+	slices.SortFunc(versions, compareVersions)
+	return versions
+}
+
+// compareVersions compares two dotted version numbers such as 0.10.4 and 0.12
+func compareVersions(a string, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// LatestSchemaVersion returns the most recent version of the commonmeta JSON Schema
+func LatestSchemaVersion() string {
+	versions := SchemaVersions()
+	return versions[len(versions)-1]
+}
+
+var (
+	schemaMu    sync.Mutex
+	schemaCache = map[string]*gojsonschema.Schema{}
+)
+
+// loadSchema returns the compiled JSON Schema for a commonmeta version, e.g.
+// 0.15. Since v0.14 the root of the schema is under definitions/commonmeta.
+func loadSchema(version string) (*gojsonschema.Schema, error) {
+	version = strings.TrimPrefix(version, "v")
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+	if s, ok := schemaCache[version]; ok {
+		return s, nil
+	}
+	b, err := commonmetaSchemas.ReadFile(path.Join("docs", "commonmeta_v"+version+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown schema version %s", version)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if _, ok := doc["commonmeta"]; ok {
+		delete(doc, "commonmeta")
+		doc["$ref"] = "#/definitions/commonmeta"
+	}
+	s, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(doc))
+	if err != nil {
+		return nil, err
+	}
+	schemaCache[version] = s
+	return s, nil
+}
+
+// ValidationError is a schema violation, located with a JSON pointer (RFC 6901)
+type ValidationError struct {
+	Pointer string `json:"pointer"`
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) String() string {
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return pointer + ": " + e.Message
+}
+
+// ValidateCommonmeta validates a commonmeta document, or an array of
+// documents, against a version of the commonmeta JSON Schema. Errors are
+// sorted by document and pointer, pointers of errors in an array start with
+// the index of the document.
+func ValidateCommonmeta(document []byte, version string) ([]ValidationError, error) {
+	schema, err := loadSchema(version)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(document, &v); err != nil {
+		return nil, err
+	}
+	items, isArray := v.([]any)
+	if !isArray {
+		items = []any{v}
+	}
+	errs := make([]ValidationError, 0)
+	for i, item := range items {
+		result, err := schema.Validate(gojsonschema.NewGoLoader(item))
+		if err != nil {
+			return nil, err
+		}
+		prefix := ""
+		if isArray {
+			prefix = fmt.Sprintf("/%d", i)
+		}
+		itemErrs := make([]ValidationError, 0)
+		for _, re := range result.Errors() {
+			itemErrs = append(itemErrs, ValidationError{
+				Pointer: prefix + jsonPointer(re.Context()),
+				Keyword: re.Type(),
+				Message: re.Description(),
+			})
+		}
+		slices.SortStableFunc(itemErrs, func(a, b ValidationError) int {
+			return strings.Compare(a.Pointer, b.Pointer)
+		})
+		errs = append(errs, itemErrs...)
+	}
+	return errs, nil
+}
+
+// jsonPointer converts the context of a validation error to a JSON pointer
+func jsonPointer(context *gojsonschema.JsonContext) string {
+	tokens := strings.Split(context.String("\x00"), "\x00")
+	pointer := ""
+	for _, token := range tokens[1:] {
+		token = strings.ReplaceAll(token, "~", "~0")
+		pointer += "/" + strings.ReplaceAll(token, "/", "~1")
+	}
+	return pointer
+}
+
+// Validate validates the commonmeta document posted in the request body
+// against the JSON Schema version given with the version query parameter,
+// defaulting to the latest version
+func Validate(c echo.Context) error {
+	version := c.QueryParam("version")
+	if version == "" {
+		version = LatestSchemaVersion()
+	}
+	version = strings.TrimPrefix(version, "v")
+	if !slices.Contains(SchemaVersions(), version) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     fmt.Sprintf("Schema version %s not supported", version),
+			"available": SchemaVersions(),
+		})
+	}
+	body, err := readBody(c)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Payload too large"})
+	}
+	errs, err := ValidateCommonmeta(body, version)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid JSON: %s", err)})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"valid":   len(errs) == 0,
+		"version": version,
+		"errors":  errs,
+	})
+}
+
+// NewValidateCommand returns the validate command of the command line
+// interface, which validates commonmeta files against the JSON Schema
+func NewValidateCommand() *cobra.Command {
+	var version string
+	cmd := &cobra.Command{
+		Use:   "validate [files]",
+		Short: "Validate commonmeta JSON files against the commonmeta JSON Schema",
+		Args:  cobra.MinimumNArgs(1),
+
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			invalid := 0
+			for _, filename := range args {
+				b, err := os.ReadFile(filename)
+				if err != nil {
+					return err
+				}
+				errs, err := ValidateCommonmeta(b, version)
+				if err != nil {
+					return fmt.Errorf("%s: %w", filename, err)
+				}
+				for _, e := range errs {
+					cmd.Printf("%s %s\n", filename, e)
+				}
+				if len(errs) > 0 {
+					invalid++
+				}
+			}
+			if invalid > 0 {
+				return fmt.Errorf("%d of %d files not valid against commonmeta v%s", invalid, len(args), version)
+			}
+			cmd.Printf("%d files valid against commonmeta v%s\n", len(args), version)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&version, "version", LatestSchemaVersion(), "version of the commonmeta JSON Schema")
+	return cmd
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+)
+
+const validCommonmeta = `{"id":"https://doi.org/10.7554/elife.01567","type":"JournalArticle","url":"https://elifesciences.org/articles/01567",
+	"contributors":[{"type":"Person","contributorRoles":["Author"],"givenName":"Martial","familyName":"Sankar"}],
+	"titles":[{"title":"Automated quantitative histology"}],
+	"publisher":{"name":"eLife Sciences Publications, Ltd"},
+	"date":{"published":"2014-02-11"}}`
+
+func TestValidateCommonmeta(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input   string
+		version string
+		want    []string
+	}
+	testCases := []testCase{
+		{input: validCommonmeta, version: "0.15", want: []string{}},
+		{input: validCommonmeta, version: "0.10.4", want: []string{}},
+		{input: `{"id":"https://doi.org/10.7554/elife.01567"}`, version: "0.15", want: []string{":required"}},
+		{input: `{"id":"https://doi.org/10.7554/elife.01567","type":"Foo","titles":[{"title":1}]}`, version: "v0.15", want: []string{"/titles/0/title:invalid_type", "/type:enum"}},
+		{input: `[` + validCommonmeta + `,{"type":"JournalArticle"}]`, version: "0.15", want: []string{"/1:required"}},
+	}
+	for _, tc := range testCases {
+		errs, err := ValidateCommonmeta([]byte(tc.input), tc.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, e := range errs {
+			got = append(got, e.Pointer+":"+e.Keyword)
+		}
+		if strings.Join(tc.want, " ") != strings.Join(got, " ") {
+			t.Errorf("Validate commonmeta(%v %v): want %v, got %v", tc.version, tc.input, tc.want, errs)
+		}
+	}
+
+	if _, err := ValidateCommonmeta([]byte(validCommonmeta), "0.9"); err == nil {
+		t.Errorf("Validate commonmeta(0.9): want unknown version error, got nil")
+	}
+}
+
+func TestSchemaVersions(t *testing.T) {
+	t.Parallel()
+
+	want := "0.10.4 0.10.5 0.10.8 0.12 0.13 0.14 0.15"
+	if got := strings.Join(SchemaVersions(), " "); want != got {
+		t.Errorf("Schema versions: want %v, got %v", want, got)
+	}
+	for _, v := range SchemaVersions() {
+		if _, err := loadSchema(v); err != nil {
+			t.Errorf("Load schema(%v): want no error, got %v", v, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		query      string
+		body       string
+		wantStatus int
+		wantValid  bool
+	}
+	testCases := []testCase{
+		{body: validCommonmeta, wantStatus: http.StatusOK, wantValid: true},
+		{query: "?version=0.12", body: `{"id":"https://doi.org/10.7554/elife.01567"}`, wantStatus: http.StatusOK, wantValid: false},
+		{query: "?version=0.9", body: validCommonmeta, wantStatus: http.StatusBadRequest},
+		{body: `{"id":`, wantStatus: http.StatusBadRequest},
+	}
+	e := echo.New()
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/validate"+tc.query, strings.NewReader(tc.body))
+		rec := httptest.NewRecorder()
+		if err := Validate(e.NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantStatus != rec.Code {
+			t.Errorf("Validate(%v): want status %v, got %v", tc.query, tc.wantStatus, rec.Code)
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var result struct {
+			Valid  bool              `json:"valid"`
+			Errors []ValidationError `json:"errors"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantValid != result.Valid || tc.wantValid != (len(result.Errors) == 0) {
+			t.Errorf("Validate(%v): want valid %v, got %v", tc.query, tc.wantValid, rec.Body.String())
+		}
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filename := dir + "/invalid.json"
+	if err := os.WriteFile(filename, []byte(`{"type":"JournalArticle"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	cmd := NewValidateCommand()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"--version", "0.15", filename})
+	if err := cmd.Execute(); err == nil {
+		t.Errorf("Validate command: want error for invalid file, got nil")
+	}
+	if !strings.Contains(out.String(), filename+" /: id is required") {
+		t.Errorf("Validate command: want error with pointer, got %v", out.String())
+	}
+}
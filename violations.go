@@ -0,0 +1,225 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// ValidationMode controls what happens to works that don't validate against
+// the commonmeta JSON Schema when they are saved
+type ValidationMode string
+
+const (
+	// Reject keeps invalid works out of the works collection. Their document
+	// is quarantined with the violations, so that curators can fix them.
+	Reject ValidationMode = "reject"
+	// Warn saves invalid works and records their violations
+	Warn ValidationMode = "warn"
+	// Off disables validation
+	Off ValidationMode = "off"
+)
+
+// ParseValidationMode parses a validation mode, defaulting to Reject
+func ParseValidationMode(str string) (ValidationMode, error) {
+	switch mode := ValidationMode(strings.ToLower(str)); mode {
+	case "":
+		return Reject, nil
+	case Reject, Warn, Off:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown validation mode %s", str)
+	}
+}
+
+// ensures that the Violation struct satisfy the models.Model interface
+var _ models.Model = (*Violation)(nil)
+
+// Violation records the schema violations of a work, one record per pid
+type Violation struct {
+	models.BaseModel
+
+	Pid      string        `db:"pid" json:"pid"`
+	Version  string        `db:"version" json:"version"`
+	Errors   types.JsonRaw `db:"errors" json:"errors"`
+	Document types.JsonRaw `db:"document" json:"document"`
+	Rejected bool          `db:"rejected" json:"rejected"`
+}
+
+func (m *Violation) TableName() string {
+	return "violations"
+}
+
+// InvalidWorkError is returned when saving a work that doesn't validate
+// against the commonmeta JSON Schema
+type InvalidWorkError struct {
+	Pid     string
+	Version string
+	Errors  []ValidationError
+}
+
+func (e *InvalidWorkError) Error() string {
+	return fmt.Sprintf("%s not valid against commonmeta v%s: %d errors", e.Pid, e.Version, len(e.Errors))
+}
+
+// ValidateWork validates the commonmeta document of a work against a version
+// of the commonmeta JSON Schema. It returns the document and its violations.
+func ValidateWork(work *Work, version string) ([]byte, []ValidationError, error) {
+	data, err := WriteWorkToCommonmeta(work)
+	if err != nil {
+		log.Println("error:", err)
+	}
+	document, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	errs, err := ValidateCommonmeta(document, version)
+	return document, errs, err
+}
+
+// workFromRecord returns the work of a record of the works collection, as
+// saved with the admin UI or the records API
+func workFromRecord(record *models.Record) *Work {
+	work := &Work{
+		Pid:               record.GetString("pid"),
+		Type:              record.GetString("type"),
+		AdditionalType:    record.GetString("additionalType"),
+		ArchiveLocations:  types.JsonRaw(record.GetString("archiveLocations")),
+		Container:         types.JsonRaw(record.GetString("container")),
+		Contributors:      types.JsonRaw(record.GetString("contributors")),
+		Date:              types.JsonRaw(record.GetString("date")),
+		Descriptions:      types.JsonRaw(record.GetString("descriptions")),
+		Files:             types.JsonRaw(record.GetString("files")),
+		FundingReferences: types.JsonRaw(record.GetString("fundingReferences")),
+		GeoLocations:      types.JsonRaw(record.GetString("geoLocations")),
+		Identifiers:       types.JsonRaw(record.GetString("identifiers")),
+		Language:          record.GetString("language"),
+		License:           types.JsonRaw(record.GetString("license")),
+		Provider:          record.GetString("provider"),
+		Publisher:         types.JsonRaw(record.GetString("publisher")),
+		References:        types.JsonRaw(record.GetString("references")),
+		Relations:         types.JsonRaw(record.GetString("relations")),
+		Subjects:          types.JsonRaw(record.GetString("subjects")),
+		Titles:            types.JsonRaw(record.GetString("titles")),
+		Url:               record.GetString("url"),
+		Version:           record.GetString("version"),
+		Created:           record.Created,
+		Updated:           record.Updated,
+		Revision:          record.GetInt("revision"),
+	}
+	work.Id = record.Id
+	return work
+}
+
+// hookWork returns the work of a model hook event, whether saved as Work or
+// as record with the admin UI and the records API
+func hookWork(e *core.ModelEvent) *Work {
+	switch m := e.Model.(type) {
+	case *Work:
+		return m
+	case *models.Record:
+		return workFromRecord(m)
+	}
+	return nil
+}
+
+// ValidateWorkHook returns a model hook that rejects invalid works before
+// they are created or updated in the reject mode. Their document is
+// quarantined in the violations collection, with the dao of the save, so
+// callers saving in a transaction commit it although the save failed.
+func ValidateWorkHook(mode ValidationMode, version string) func(e *core.ModelEvent) error {
+	return func(e *core.ModelEvent) error {
+		work := hookWork(e)
+		if work == nil || mode != Reject {
+			return nil
+		}
+		document, errs, err := ValidateWork(work, version)
+		if err != nil || len(errs) == 0 {
+			return err
+		}
+		if err := SaveViolation(e.Dao, work.Pid, version, document, errs, true); err != nil {
+			return err
+		}
+		return &InvalidWorkError{Pid: work.Pid, Version: version, Errors: errs}
+	}
+}
+
+// RecordViolationsHook returns a model hook that records the violations of
+// works after they are created or updated, and removes them when the work
+// validates again. Saves that fail, e.g. because of a conflicting revision,
+// leave the violations as they were. Works are only saved with violations
+// in the warn mode, in the reject mode they have validated before the save.
+func RecordViolationsHook(mode ValidationMode, version string) func(e *core.ModelEvent) error {
+	return func(e *core.ModelEvent) error {
+		work := hookWork(e)
+		if work == nil || mode == Off {
+			return nil
+		}
+		if mode == Reject {
+			return DeleteViolation(e.Dao, work.Pid)
+		}
+		document, errs, err := ValidateWork(work, version)
+		if err != nil {
+			return err
+		}
+		if len(errs) == 0 {
+			return DeleteViolation(e.Dao, work.Pid)
+		}
+		log.Printf("%s not valid against commonmeta v%s, saved with %d errors", work.Pid, version, len(errs))
+		return SaveViolation(e.Dao, work.Pid, version, document, errs, false)
+	}
+}
+
+// SaveViolation records the violations of a work, replacing earlier ones
+func SaveViolation(dao *daos.Dao, pid string, version string, document []byte, errs []ValidationError, rejected bool) error {
+	violation, err := FindViolationByPid(dao, pid)
+	if err != nil {
+		return err
+	}
+	if violation == nil {
+		violation = &Violation{Pid: pid}
+	}
+	violation.Version = version
+	violation.Errors = marshalSlice(errs)
+	violation.Document = types.JsonRaw(document)
+	violation.Rejected = rejected
+	return dao.Save(violation)
+}
+
+// DeleteViolation removes the violations of a work, if any
+func DeleteViolation(dao *daos.Dao, pid string) error {
+	violation, err := FindViolationByPid(dao, pid)
+	if err != nil || violation == nil {
+		return err
+	}
+	return dao.Delete(violation)
+}
+
+// find the violations of a work by pid
+func FindViolationByPid(dao *daos.Dao, pid string) (*Violation, error) {
+	violation := &Violation{}
+
+	err := dao.ModelQuery(&Violation{}).
+		// case insensitive match
+		AndWhere(dbx.NewExp("LOWER(pid)={:pid}", dbx.Params{
+			"pid": strings.ToLower(pid),
+		})).
+		Limit(1).
+		One(violation)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return violation, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestParseValidationMode(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		input   string
+		want    ValidationMode
+		wantErr bool
+	}
+	testCases := []testCase{
+		{input: "", want: Reject},
+		{input: "warn", want: Warn},
+		{input: "OFF", want: Off},
+		{input: "quarantine", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := ParseValidationMode(tc.input)
+		if tc.want != got || tc.wantErr != (err != nil) {
+			t.Errorf("Parse validation mode(%v): want %v %v, got %v %v", tc.input, tc.want, tc.wantErr, got, err)
+		}
+	}
+}
+
+func TestValidateWork(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		work *Work
+		want []string
+	}
+	testCases := []testCase{
+		{work: GetWorkFromCommonmeta(catalogData), want: []string{}},
+		{work: &Work{Pid: "https://doi.org/10.5555/12345678", Type: "Unknown", Titles: types.JsonRaw(`[{"language":"en"}]`)}, want: []string{"/titles/0", "/type"}},
+	}
+	for _, tc := range testCases {
+		document, errs, err := ValidateWork(tc.work, "0.15")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, e := range errs {
+			got = append(got, e.Pointer)
+		}
+		if strings.Join(tc.want, " ") != strings.Join(got, " ") {
+			t.Errorf("Validate work(%v): want %v, got %v", tc.work.Pid, tc.want, errs)
+		}
+		if !strings.Contains(string(document), tc.work.Pid) {
+			t.Errorf("Validate work(%v): want document, got %v", tc.work.Pid, string(document))
+		}
+	}
+}
+
+func TestValidateWorkHookOff(t *testing.T) {
+	t.Parallel()
+
+	// without validation the hooks don't touch the database, and in the warn
+	// mode the violations are only recorded after the save
+	e := &core.ModelEvent{}
+	e.Model = &Work{Pid: "https://doi.org/10.5555/12345678", Type: "Unknown"}
+	if err := ValidateWorkHook(Off, "0.15")(e); err != nil {
+		t.Errorf("Validate work hook(off): want no error, got %v", err)
+	}
+	if err := ValidateWorkHook(Warn, "0.15")(e); err != nil {
+		t.Errorf("Validate work hook(warn): want no error, got %v", err)
+	}
+	if err := RecordViolationsHook(Off, "0.15")(e); err != nil {
+		t.Errorf("Record violations hook(off): want no error, got %v", err)
+	}
+}
+
+func TestValidateWorkRecord(t *testing.T) {
+	t.Parallel()
+
+	// works saved with the admin UI and the records API are records
+	works := &models.Collection{Name: "works", Type: models.CollectionTypeBase, Schema: schema.NewSchema(
+		&schema.SchemaField{Name: "pid", Type: schema.FieldTypeText, Options: &schema.TextOptions{}},
+		&schema.SchemaField{Name: "type", Type: schema.FieldTypeText, Options: &schema.TextOptions{}},
+		&schema.SchemaField{Name: "url", Type: schema.FieldTypeUrl, Options: &schema.UrlOptions{}},
+		&schema.SchemaField{Name: "titles", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{}},
+		&schema.SchemaField{Name: "revision", Type: schema.FieldTypeNumber, Options: &schema.NumberOptions{}},
+	)}
+
+	type testCase struct {
+		data map[string]any
+		want []string
+	}
+	testCases := []testCase{
+		{data: map[string]any{"pid": "https://doi.org/10.5555/12345678", "type": "Dataset", "url": "https://example.org/1", "titles": `[{"title":"Example"}]`}, want: []string{}},
+		{data: map[string]any{"pid": "https://doi.org/10.5555/12345678", "type": "Unknown", "titles": `[{"language":"en"}]`}, want: []string{"/titles/0", "/type"}},
+	}
+	for _, tc := range testCases {
+		record := models.NewRecord(works)
+		record.Load(tc.data)
+		work := workFromRecord(record)
+		if work.Pid != tc.data["pid"] || string(work.Titles) != tc.data["titles"] {
+			t.Errorf("Work from record(%v): want %v, got %v %s", tc.data["pid"], tc.data, work.Pid, work.Titles)
+		}
+		_, errs, err := ValidateWork(work, "0.15")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, e := range errs {
+			got = append(got, e.Pointer)
+		}
+		if strings.Join(tc.want, " ") != strings.Join(got, " ") {
+			t.Errorf("Validate work record(%v): want %v, got %v", tc.data["type"], tc.want, errs)
+		}
+	}
+}
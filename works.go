@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+// WorksAPI is the write API of the works collection, which partner systems
+// use to push the commonmeta metadata of their works. Clients are
+// authenticated with RequireWriteAuth. Updates are conditional on the
+// revision ETag of the work, "rev-N", sent by the write API; the resolver
+// sends the revision N in the Work-Revision header.
+type WorksAPI struct {
+	Dao *daos.Dao
+	// Version of the commonmeta JSON Schema the works are validated against
+	Version string
+}
+
+// WorkExistsError is returned when a work is created with the pid of an
+// existing work, e.g. by two clients at the same time
+type WorkExistsError struct {
+	Pid string
+}
+
+func (e *WorkExistsError) Error() string {
+	return fmt.Sprintf("Work %s exists already", e.Pid)
+}
+
+// patchMediaTypes are the media types of the patch documents supported by PATCH
+var patchMediaTypes = []string{"application/merge-patch+json", "application/json-patch+json"}
+
+// Create creates a work from the commonmeta document in the request body,
+// unless a work with the same pid exists
+func (api *WorksAPI) Create(c echo.Context) error {
+	data, err := readDocument(c)
+	if data == nil {
+		return err
+	}
+	if data.ID != "" && !canWrite(c, data.ID) {
+		return forbidden(c, data.ID)
+	}
+	if gone, err := api.gone(c, data.ID); gone {
+		return err
+	}
+	existing, err := FindWorkByPid(api.Dao, data.ID)
+	if err != nil {
+		return err
+	} else if existing != nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": (&WorkExistsError{Pid: existing.Pid}).Error()})
+	}
+	return api.save(c, *data, nil)
+}
+
+// Replace replaces the work with the pid in the path by the commonmeta
+// document in the request body, creating the work if it doesn't exist.
+// Existing works are only replaced if If-Match holds their revision ETag.
+func (api *WorksAPI) Replace(c echo.Context) error {
+	pid, _ := pidFromPath(c.PathParam("str"))
+	if !canWrite(c, pid) {
+		return forbidden(c, pid)
+	}
+	data, err := readDocument(c)
+	if data == nil {
+		return err
+	}
+	if data.ID == "" {
+		data.ID = pid
+	} else if !strings.EqualFold(data.ID, pid) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("id %s doesn't match %s", data.ID, pid)})
+	}
+	if gone, err := api.gone(c, pid); gone {
+		return err
+	}
+	existing, err := FindWorkByPid(api.Dao, pid)
+	if err != nil {
+		return err
+	}
+	// works are created without precondition, unless If-Match is sent
+	if existing != nil || c.Request().Header.Get("If-Match") != "" {
+		if failed, err := preconditionFailed(c, existing); failed {
+			return err
+		}
+	}
+	return api.save(c, *data, existing)
+}
+
+// Patch updates the work with the pid in the path with a JSON Merge Patch
+// (RFC 7396) or JSON Patch (RFC 6902) of its commonmeta document, selected
+// with the Content-Type header. If-Match must hold the revision ETag.
+func (api *WorksAPI) Patch(c echo.Context) error {
+	pid, _ := pidFromPath(c.PathParam("str"))
+	if !canWrite(c, pid) {
+		return forbidden(c, pid)
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get("Content-Type"))
+	if mediaType != patchMediaTypes[0] && mediaType != patchMediaTypes[1] {
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]interface{}{
+			"error":     fmt.Sprintf("Content-Type %s not supported for patches", mediaType),
+			"available": patchMediaTypes,
+		})
+	}
+	if gone, err := api.gone(c, pid); gone {
+		return err
+	}
+	existing, err := FindWorkByPid(api.Dao, pid)
+	if err != nil {
+		return err
+	} else if existing == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Not found"})
+	}
+	if failed, err := preconditionFailed(c, existing); failed {
+		return err
+	}
+	patch, err := readBody(c)
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Payload too large"})
+	}
+
+	current, err := WriteWorkToCommonmeta(existing)
+	if err != nil {
+		log.Println("error:", err)
+	}
+	document, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	if mediaType == patchMediaTypes[0] {
+		document, err = MergePatch(document, patch)
+	} else {
+		document, err = JSONPatch(document, patch)
+	}
+	var perr *PatchError
+	if errors.As(err, &perr) {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": perr.Error()})
+	} else if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid patch: %s", err)})
+	}
+	var data commonmeta.Data
+	if err := json.Unmarshal(document, &data); err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": fmt.Sprintf("Patched document is not commonmeta: %s", err)})
+	}
+	if !strings.EqualFold(data.ID, existing.Pid) {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "The id of a work can't be changed"})
+	}
+	return api.save(c, data, existing)
+}
+
+// Delete withdraws the work with the pid in the path, deleting the work and
+// its violations and tombstoning its pid. The reason and the pid of a
+// successor replacing the work are taken from the query parameters.
+func (api *WorksAPI) Delete(c echo.Context) error {
+	pid, _ := pidFromPath(c.PathParam("str"))
+	if !canWrite(c, pid) {
+		return forbidden(c, pid)
+	}
+	if gone, err := api.gone(c, pid); gone {
+		return err
+	}
+	existing, err := FindWorkByPid(api.Dao, pid)
+	if err != nil {
+		return err
+	} else if existing == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Not found"})
+	}
+	reason := c.QueryParam("reason")
+	if reason == "" {
+		reason = "withdrawn"
+	}
+	successor := c.QueryParam("successor")
+	if successor != "" && !strings.HasPrefix(successor, "https://") && !strings.HasPrefix(successor, "http://") {
+		successor, _ = pidFromPath(successor)
+	}
+	err = api.Dao.RunInTransaction(func(txDao *daos.Dao) error {
+		if _, err := SaveTombstone(txDao, existing.Pid, reason, successor); err != nil {
+			return err
+		}
+		if err := txDao.Delete(existing); err != nil {
+			return err
+		}
+		return DeleteViolation(txDao, existing.Pid)
+	})
+	if err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// save validates and stores a work, replacing the existing work with the
+// same pid, and responds with its commonmeta document. Invalid works are
+// rejected whatever the validation mode of the model hooks.
+func (api *WorksAPI) save(c echo.Context, data commonmeta.Data, existing *Work) error {
+	work := GetWorkFromCommonmeta(data)
+	status := http.StatusCreated
+	if existing != nil {
+		work.Id = existing.Id
+		work.Pid = existing.Pid
+		work.Created = existing.Created
+		work.Revision = existing.Revision
+		work.MarkAsNotNew()
+		status = http.StatusOK
+	}
+	_, errs, err := ValidateWork(work, api.Version)
+	if err != nil {
+		return err
+	}
+	verr := &InvalidWorkError{Pid: work.Pid, Version: api.Version, Errors: errs}
+	if len(errs) == 0 {
+		// the revision is checked and saved in one transaction
+		err = api.Dao.RunInTransaction(func(txDao *daos.Dao) error {
+			if existing == nil {
+				// another client may have created the work since it was looked up
+				other, err := FindWorkByPid(txDao, work.Pid)
+				if err != nil {
+					return err
+				} else if other != nil {
+					return &WorkExistsError{Pid: other.Pid}
+				}
+			}
+			err := txDao.Save(work)
+			if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				return &WorkExistsError{Pid: work.Pid}
+			} else if errors.As(err, &verr) {
+				// keep the document quarantined by the validation hook
+				errs = verr.Errors
+				return nil
+			}
+			return err
+		})
+	}
+	if len(errs) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":  verr.Error(),
+			"errors": verr.Errors,
+		})
+	}
+	var cerr *RevisionConflictError
+	var eerr *WorkExistsError
+	var terr *TombstonedError
+	if errors.As(err, &cerr) {
+		return c.JSON(http.StatusPreconditionFailed, map[string]string{"error": cerr.Error()})
+	} else if errors.As(err, &eerr) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": eerr.Error()})
+	} else if errors.As(err, &terr) {
+		return c.JSON(http.StatusGone, tombstoneResponse(terr.Tombstone))
+	} else if err != nil {
+		return err
+	}
+
+	body, contentType := workRepresentation(work)
+	c.Response().Header().Set("ETag", RevisionETag(work))
+	c.Response().Header().Set(RevisionHeader, strconv.Itoa(work.Revision))
+	if status == http.StatusCreated {
+		c.Response().Header().Set("Location", c.Scheme()+"://"+c.Request().Host+"/"+pathFromPid(work.Pid))
+	}
+	return c.Blob(status, contentType, body)
+}
+
+// workRepresentation returns the commonmeta JSON of a stored work as served
+// by the resolver, with its content type
+func workRepresentation(work *Work) ([]byte, string) {
+	w, _ := Writers.ByName("commonmeta")
+	out, err := w.Write(&WriteRequest{Work: work})
+	if err != nil {
+		log.Println("error:", err)
+	}
+	return out, w.ResponseContentType("")
+}
+
+// preconditionFailed evaluates If-Match against the revision ETag of a work,
+// nil if it doesn't exist. It responds with 428 Precondition Required or 412
+// Precondition Failed and returns true if the precondition doesn't hold.
+func preconditionFailed(c echo.Context, work *Work) (bool, error) {
+	var etag string
+	if work != nil {
+		etag = RevisionETag(work)
+	}
+	switch IfMatch(c.Request(), etag) {
+	case http.StatusPreconditionRequired:
+		return true, c.JSON(http.StatusPreconditionRequired, map[string]string{"error": "If-Match with the revision ETag of the work is required"})
+	case http.StatusPreconditionFailed:
+		return true, c.JSON(http.StatusPreconditionFailed, map[string]string{"error": "If-Match doesn't hold the revision ETag of the work"})
+	}
+	return false, nil
+}
+
+// gone responds with 410 Gone and returns true if a pid is tombstoned. Works
+// with a tombstoned pid can't be written until an admin removes the tombstone.
+func (api *WorksAPI) gone(c echo.Context, pid string) (bool, error) {
+	tombstone, err := FindTombstoneByPid(api.Dao, pid)
+	if err != nil {
+		return true, err
+	} else if tombstone != nil {
+		return true, c.JSON(http.StatusGone, tombstoneResponse(tombstone))
+	}
+	return false, nil
+}
+
+// readDocument reads the commonmeta document of a work in the request body.
+// It responds with an error and returns nil if the body can't be read.
+func readDocument(c echo.Context) (*commonmeta.Data, error) {
+	body, err := readBody(c)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return nil, c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Payload too large"})
+	}
+	var data commonmeta.Data
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid JSON: %s", err)})
+	}
+	return &data, nil
+}
+
+// forbidden responds that the client may not write the work with a pid
+func forbidden(c echo.Context, pid string) error {
+	return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("Not allowed to write %s", pid)})
+}
+
+var doiPathRegexp = regexp.MustCompile(`10\.\d{4,9}/.+`)
+
+// pidFromPath returns the pid of a work from its path at the resolver, a
+// DOI such as 10.5555/12345678 or a URL without scheme. It reports whether
+// the pid is a DOI.
+func pidFromPath(str string) (string, bool) {
+	if doiPathRegexp.MatchString(str) {
+		return "https://doi.org/" + str, true
+	}
+	return "https://" + str, false
+}
+
+// pathFromPid returns the path of a work at the resolver
+func pathFromPid(pid string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(pid, "https://"), "doi.org/")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestWorksAPIWithoutAccess(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+		prefixes    []string
+		wantStatus  int
+		contains    string
+	}
+	testCases := []testCase{
+		{method: http.MethodPost, body: `{"id":"https://doi.org/10.5555/12345678","type":"Article"}`, prefixes: []string{"10.1234"}, wantStatus: http.StatusForbidden, contains: "Not allowed to write https://doi.org/10.5555/12345678"},
+		{method: http.MethodPost, body: `{"id":`, prefixes: []string{"*"}, wantStatus: http.StatusBadRequest, contains: "Invalid JSON"},
+		{method: http.MethodPut, path: "10.5555/12345678", body: `{}`, prefixes: []string{"https://blog.example.org"}, wantStatus: http.StatusForbidden},
+		{method: http.MethodPut, path: "10.5555/12345678", body: `{"id":"https://doi.org/10.5555/other"}`, prefixes: []string{"10.5555"}, wantStatus: http.StatusBadRequest, contains: "doesn't match"},
+		{method: http.MethodPatch, path: "blog.example.org/posts/1", contentType: "application/json-patch+json", body: `[]`, prefixes: []string{"10.5555"}, wantStatus: http.StatusForbidden},
+		{method: http.MethodPatch, path: "10.5555/12345678", contentType: "application/json", body: `{}`, prefixes: []string{"10.5555"}, wantStatus: http.StatusUnsupportedMediaType, contains: "application/merge-patch+json"},
+		{method: http.MethodDelete, path: "10.5555/12345678", prefixes: []string{}, wantStatus: http.StatusForbidden},
+	}
+	api := &WorksAPI{Version: LatestSchemaVersion()}
+	e := echo.New()
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, "/"+tc.path, strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPathParams(echo.PathParams{{Name: "str", Value: tc.path}})
+		c.Set(writePrefixesKey, tc.prefixes)
+		var err error
+		switch tc.method {
+		case http.MethodPost:
+			err = api.Create(c)
+		case http.MethodPut:
+			err = api.Replace(c)
+		case http.MethodPatch:
+			err = api.Patch(c)
+		case http.MethodDelete:
+			err = api.Delete(c)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantStatus != rec.Code {
+			t.Errorf("WorksAPI(%v %v): want status %v, got %v %v", tc.method, tc.path, tc.wantStatus, rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), tc.contains) {
+			t.Errorf("WorksAPI(%v %v): want %v, got %v", tc.method, tc.path, tc.contains, rec.Body.String())
+		}
+	}
+}
+
+func TestPidFromPath(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		path    string
+		want    string
+		wantDoi bool
+	}
+	testCases := []testCase{
+		{path: "10.5555/12345678", want: "https://doi.org/10.5555/12345678", wantDoi: true},
+		{path: "blog.example.org/posts/1", want: "https://blog.example.org/posts/1", wantDoi: false},
+	}
+	for _, tc := range testCases {
+		got, isDoi := pidFromPath(tc.path)
+		if tc.want != got || tc.wantDoi != isDoi {
+			t.Errorf("pidFromPath(%v): want %v %v, got %v %v", tc.path, tc.want, tc.wantDoi, got, isDoi)
+		}
+		if path := pathFromPid(got); path != tc.path {
+			t.Errorf("pathFromPid(%v): want %v, got %v", got, tc.path, path)
+		}
+	}
+}
+
+func TestRevisionETag(t *testing.T) {
+	t.Parallel()
+
+	stored := Work{
+		Pid:        "https://doi.org/10.5555/12345678",
+		Type:       "JournalArticle",
+		References: types.JsonRaw(`[{"id":"https://doi.org/10.5555/87654321"}]`),
+		Revision:   3,
+	}
+	stored.Id = "abc"
+	e := echo.New()
+	serve := func(name string, work Work) *httptest.ResponseRecorder {
+		w, _ := Writers.ByName(name)
+		rec := httptest.NewRecorder()
+		r := &WriteRequest{Work: &work, Data: commonmeta.Data{ID: work.Pid, Type: work.Type}, Params: map[string]string{}}
+		if err := ServeWriter(e.NewContext(httptest.NewRequest(http.MethodGet, "/10.5555/12345678", nil), rec), w, r); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	// the resolver serves the work with the metadata of its references, with
+	// an ETag per representation and the revision in its own header
+	resolved := stored
+	resolved.References = types.JsonRaw(`[{"id":"https://doi.org/10.5555/87654321","type":"JournalArticle","titles":[{"title":"Cited work"}]}]`)
+	commonmetaRec := serve("commonmeta", resolved)
+	bibtexRec := serve("bibtex", resolved)
+	etag := commonmetaRec.Header().Get("ETag")
+	if etag == "" || etag == bibtexRec.Header().Get("ETag") {
+		t.Errorf("ServeWriter: want an ETag per representation, got %v and %v", etag, bibtexRec.Header().Get("ETag"))
+	}
+	if got := commonmetaRec.Header().Get(RevisionHeader); got != "3" {
+		t.Errorf("ServeWriter: want %v 3, got %v", RevisionHeader, got)
+	}
+	enriched := resolved
+	enriched.References = types.JsonRaw(`[{"id":"https://doi.org/10.5555/87654321","type":"JournalArticle","titles":[{"title":"Cited work, corrected"}]}]`)
+	if got := serve("commonmeta", enriched).Header().Get("ETag"); got == etag {
+		t.Errorf("ServeWriter: want new ETag when a reference changes, got %v", got)
+	}
+
+	// updates are conditional on the revision ETag, not on the ETag of a representation
+	type testCase struct {
+		ifMatch  string
+		revision int
+		want     int
+	}
+	testCases := []testCase{
+		{ifMatch: `"rev-3"`, revision: 3, want: http.StatusOK},
+		{ifMatch: `"rev-3"`, revision: 4, want: http.StatusPreconditionFailed},
+		{ifMatch: `W/"rev-3"`, revision: 3, want: http.StatusPreconditionFailed},
+		{ifMatch: etag, revision: 3, want: http.StatusPreconditionFailed},
+		{revision: 3, want: http.StatusPreconditionRequired},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPut, "/10.5555/12345678", nil)
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		work := stored
+		work.Revision = tc.revision
+		failed, err := preconditionFailed(e.NewContext(req, rec), &work)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := http.StatusOK
+		if failed {
+			got = rec.Code
+		}
+		if tc.want != got {
+			t.Errorf("preconditionFailed(%v, revision %v): want %v, got %v", tc.ifMatch, tc.revision, tc.want, got)
+		}
+	}
+}
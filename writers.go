@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/front-matter/commonmeta/commonmeta"
+	"github.com/front-matter/commonmeta/crossrefxml"
+	"github.com/front-matter/commonmeta/csl"
+	"github.com/front-matter/commonmeta/datacite"
+	"github.com/front-matter/commonmeta/schemaorg"
+	"github.com/labstack/echo/v5"
+)
+
+// Writers is the registry of writers used by the resolver. Writers registered
+// in an init function of another file are available alongside the built-in
+// writers, in order of registration.
+var Writers = NewWriterRegistry()
+
+func init() {
+	for _, w := range builtinWriters {
+		Writers.MustRegister(w)
+	}
+}
+
+// builtinWriters are the writers registered by default. text/html comes first,
+// so that */* redirects to the resource.
+var builtinWriters = []Writer{
+	{
+		Name:        "html",
+		MediaType:   "text/html",
+		Description: "Redirect to the resource, or a landing page if the work has no URL or with the landing parameter",
+		Delivery:    Local,
+		Location: func(r *WriteRequest) (string, error) {
+			if _, ok := r.Params["landing"]; ok {
+				return "", nil
+			}
+			return r.Data.URL, nil
+		},
+		Write: WriteLandingPage,
+	},
+	{
+		Name:        "commonmeta",
+		MediaType:   "application/vnd.commonmeta+json",
+		Aliases:     []string{"application/json"},
+		Extension:   ".json",
+		Description: "Commonmeta JSON",
+		Delivery:    Local,
+		Signposting: true,
+		ContentType: echo.MIMEApplicationJSONCharsetUTF8,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			if r.Work != nil {
+				return json.Marshal(r.Work)
+			}
+			return json.Marshal(r.Data)
+		},
+	},
+	{
+		Name:        "datacite",
+		MediaType:   "application/vnd.datacite.datacite+json",
+		Description: "DataCite JSON",
+		Delivery:    Local,
+		Signposting: true,
+		ContentType: echo.MIMEApplicationJSONCharsetUTF8,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := datacite.Convert(r.Data)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(out)
+		},
+	},
+	{
+		Name:        "datacite-xml",
+		MediaType:   "application/vnd.datacite.datacite+xml",
+		Description: "DataCite XML 4.5, validated against the DataCite schema",
+		Delivery:    Local,
+		Signposting: true,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := WriteDataciteXML(r.Data)
+			var verr *DataciteValidationError
+			if errors.As(err, &verr) {
+				return nil, &WriteError{Status: http.StatusUnprocessableEntity, Message: verr.Error(), Fields: map[string]any{"missing": verr.Missing, "errors": verr.Errors}}
+			}
+			return out, err
+		},
+	},
+	{
+		Name:        "csl",
+		MediaType:   "application/vnd.citationstyles.csl+json",
+		Description: "Citation Style Language JSON",
+		Delivery:    Local,
+		Signposting: true,
+		ContentType: echo.MIMEApplicationJSONCharsetUTF8,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := csl.Convert(r.Data)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(out)
+		},
+	},
+	{
+		Name:        "crossref-xml",
+		MediaType:   "application/vnd.crossref.unixsd+xml",
+		Description: "Crossref UNIXSD XML",
+		Delivery:    Local,
+		Signposting: true,
+		ContentType: echo.MIMEApplicationXMLCharsetUTF8,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := crossrefxml.Convert(r.Data)
+			if err != nil {
+				return nil, err
+			}
+			b, err := xml.Marshal(out)
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte(xml.Header), b...), nil
+		},
+	},
+	{
+		Name:        "schemaorg",
+		MediaType:   "application/vnd.schemaorg.ld+json",
+		Description: "Schema.org JSON-LD",
+		Delivery:    Local,
+		Signposting: true,
+		ContentType: echo.MIMEApplicationJSONCharsetUTF8,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := schemaorg.Convert(r.Data)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(out)
+		},
+	},
+	rdfWriter("jsonld", "application/ld+json", ".jsonld", "RDF as JSON-LD"),
+	rdfWriter("turtle", "text/turtle", ".ttl", "RDF as Turtle"),
+	rdfWriter("rdfxml", "application/rdf+xml", ".rdf", "RDF as RDF/XML"),
+	rdfWriter("ntriples", "application/n-triples", ".nt", "RDF as N-Triples"),
+	{
+		Name:        "bibtex",
+		MediaType:   "application/x-bibtex",
+		Extension:   ".bib",
+		Description: "BibTeX",
+		Delivery:    Local,
+		Signposting: true,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := WriteBibtex(r.Data)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(out), nil
+		},
+	},
+	{
+		Name:        "ris",
+		MediaType:   "application/x-research-info-systems",
+		Extension:   ".ris",
+		Description: "RIS",
+		Delivery:    Local,
+		Signposting: true,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := WriteRIS(r.Data)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(out), nil
+		},
+	},
+	{
+		Name:        "citation",
+		MediaType:   "text/x-bibliography",
+		Extension:   ".txt",
+		Description: "Formatted citation, the style and locale parameters select a CSL style and locale",
+		Delivery:    Local,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := WriteCitation(r.Data, r.Params["style"], r.Params["locale"])
+			if err != nil {
+				return nil, &WriteError{Status: http.StatusBadRequest, Message: err.Error()}
+			}
+			return []byte(out), nil
+		},
+	},
+	{
+		Name:        "codemeta",
+		MediaType:   "application/vnd.codemeta.ld+json",
+		Description: "Codemeta JSON-LD",
+		Delivery:    Local,
+		Signposting: true,
+		Types:       []string{"Software"},
+		ContentType: echo.MIMEApplicationJSONCharsetUTF8,
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := WriteCodemeta(r.Data)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(out)
+		},
+	},
+	{
+		Name:        "cff",
+		MediaType:   "application/vnd.cff+yaml",
+		Extension:   ".cff",
+		Description: "Citation File Format",
+		Delivery:    Local,
+		Signposting: true,
+		Types:       []string{"Software"},
+		Filename:    "CITATION.cff",
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := WriteCFF(r.Data)
+			if err != nil {
+				return nil, &WriteError{Status: http.StatusUnprocessableEntity, Message: err.Error()}
+			}
+			return []byte(out), nil
+		},
+	},
+	{
+		Name:        "markdown",
+		MediaType:   "text/markdown",
+		Extension:   ".md",
+		Description: "Redirect to the Markdown version of the resource",
+		Delivery:    Redirect,
+		Location: func(r *WriteRequest) (string, error) {
+			if r.Files["text/markdown"] == "" {
+				return "", notAvailable("Markdown")
+			}
+			return r.Files["text/markdown"], nil
+		},
+	},
+	{
+		Name:        "jats",
+		MediaType:   "application/vnd.jats+xml",
+		Aliases:     []string{"application/xml"},
+		Extension:   ".xml",
+		Description: "JATS XML, redirects to the JATS version of the resource if available. The jats parameter selects redirect, element-citation, mixed-citation or front.",
+		Delivery:    Local,
+		Signposting: true,
+		Location: func(r *WriteRequest) (string, error) {
+			jatsUrl := r.Files["application/xml"]
+			if jatsUrl == "" {
+				jatsUrl = r.Files["application/vnd.jats+xml"]
+			}
+			mode := r.Params["jats"]
+			if mode == "redirect" && jatsUrl == "" {
+				return "", notAvailable("JATS XML")
+			}
+			if mode == "" || mode == "redirect" {
+				return jatsUrl, nil
+			}
+			return "", nil
+		},
+		Write: func(r *WriteRequest) ([]byte, error) {
+			mode := r.Params["jats"]
+			if mode == "" {
+				mode = "element-citation"
+			}
+			out, err := WriteJATS(r.Data, mode)
+			if err != nil {
+				return nil, &WriteError{Status: http.StatusBadRequest, Message: err.Error()}
+			}
+			return out, nil
+		},
+	},
+	{
+		Name:        "oai_dc",
+		MediaType:   "application/oai_dc+xml",
+		Description: "Dublin Core in oai_dc format",
+		Delivery:    Local,
+		Signposting: true,
+		Write:       dataWriter(WriteOAIDC),
+	},
+	{
+		Name:        "mods",
+		MediaType:   "application/mods+xml",
+		Description: "MODS 3.8",
+		Delivery:    Local,
+		Signposting: true,
+		Write:       dataWriter(WriteMODS),
+	},
+	{
+		Name:        "marcxml",
+		MediaType:   "application/marcxml+xml",
+		Description: "MARC 21 bibliographic record in MARCXML",
+		Delivery:    Local,
+		Signposting: true,
+		Write:       dataWriter(WriteMARCXML),
+	},
+	{
+		Name:        "pdf",
+		MediaType:   "application/pdf",
+		Extension:   ".pdf",
+		Description: "Redirect to the PDF version of the resource",
+		Delivery:    Redirect,
+		Location: func(r *WriteRequest) (string, error) {
+			if r.Files["application/pdf"] == "" {
+				return "", notAvailable("PDF")
+			}
+			return r.Files["application/pdf"], nil
+		},
+	},
+	{
+		Name:        "linkset",
+		MediaType:   LinksetMediaType,
+		Description: "FAIR Signposting links as linkset in JSON format (RFC 9264)",
+		Delivery:    Local,
+		Write:       WriteLinkset,
+		ContentType: LinksetMediaType,
+	},
+	{
+		Name:        "crossref-unixref",
+		MediaType:   "application/vnd.crossref.unixref+xml",
+		Description: "Crossref UNIXREF XML, via Crossref content negotiation",
+		Delivery:    Proxy,
+	},
+}
+
+// rdfWriter returns a writer for one of the RDFMediaTypes
+func rdfWriter(name string, mediaType string, extension string, description string) Writer {
+	return Writer{
+		Name:        name,
+		MediaType:   mediaType,
+		Extension:   extension,
+		Description: description,
+		Delivery:    Local,
+		Signposting: true,
+		ContentType: RDFMediaTypes[mediaType],
+		Write: func(r *WriteRequest) ([]byte, error) {
+			out, err := WriteRDF(r.Data, mediaType)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(out), nil
+		},
+	}
+}
+
+// dataWriter wraps a write function that only needs the metadata of the work
+func dataWriter(write func(data commonmeta.Data) ([]byte, error)) func(r *WriteRequest) ([]byte, error) {
+	return func(r *WriteRequest) ([]byte, error) {
+		return write(r.Data)
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// XSD is a compiled XML Schema. Only the subset of XML Schema used by the
+// bundled schemas is supported: element declarations with inline or named
+// types, all, sequence and choice model groups, simple content extensions,
+// mixed content, attributes, includes and simple type restrictions with
+// enumeration, pattern, length and range facets. Imports are not followed,
+// attributes in the xml namespace such as xml:lang are accepted anywhere.
+type XSD struct {
+	namespace    string
+	elements     map[string]*xmlNode
+	complexTypes map[string]*xmlNode
+	simpleTypes  map[string]*xmlNode
+}
+
+// XSDError is a validation error at an XPath-like location in the document.
+// Missing is set if a required element or attribute was not found.
+type XSDError struct {
+	Path    string
+	Message string
+	Missing bool
+}
+
+func (e XSDError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// xmlNode is a generic XML element, used for schemas and the documents validated
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []*xmlNode `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *xmlNode) child(name string) *xmlNode {
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			return c
+		}
+	}
+	return nil
+}
+
+const (
+	xsNS  = "http://www.w3.org/2001/XMLSchema"
+	xsiNS = "http://www.w3.org/2001/XMLSchema-instance"
+	xmlNS = "http://www.w3.org/XML/1998/namespace"
+)
+
+// LoadXSD compiles the schema name from fsys, following includes relative to it
+func LoadXSD(fsys fs.FS, name string) (*XSD, error) {
+	s := &XSD{
+		elements:     map[string]*xmlNode{},
+		complexTypes: map[string]*xmlNode{},
+		simpleTypes:  map[string]*xmlNode{},
+	}
+	if err := s.load(fsys, name, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *XSD) load(fsys fs.FS, name string, loaded map[string]bool) error {
+	if loaded[name] {
+		return nil
+	}
+	loaded[name] = true
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	var root xmlNode
+	if err := xml.Unmarshal(b, &root); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if s.namespace == "" {
+		s.namespace = root.attr("targetNamespace")
+	}
+	for _, n := range root.Nodes {
+		switch n.XMLName.Local {
+		case "include":
+			if err := s.load(fsys, path.Join(path.Dir(name), n.attr("schemaLocation")), loaded); err != nil {
+				return err
+			}
+		case "element":
+			s.elements[n.attr("name")] = n
+		case "complexType":
+			s.complexTypes[n.attr("name")] = n
+		case "simpleType":
+			s.simpleTypes[n.attr("name")] = n
+		}
+	}
+	return nil
+}
+
+// Validate validates a document against the schema. Returns an error if the
+// document is not well-formed XML.
+func (s *XSD) Validate(doc []byte) ([]XSDError, error) {
+	var root xmlNode
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	if err := d.Decode(&root); err != nil {
+		return nil, err
+	}
+	p := "/" + root.XMLName.Local
+	decl, ok := s.elements[root.XMLName.Local]
+	if !ok {
+		return []XSDError{{Path: p, Message: "no declaration for root element"}}, nil
+	}
+	return s.validateElement(decl, &root, p), nil
+}
+
+func (s *XSD) validateElement(decl *xmlNode, n *xmlNode, p string) []XSDError {
+	errs := make([]XSDError, 0)
+	if n.XMLName.Space != s.namespace {
+		errs = append(errs, XSDError{Path: p, Message: fmt.Sprintf("element is not in namespace %s", s.namespace)})
+	}
+	if t := decl.attr("type"); t != "" {
+		if ct, ok := s.complexTypes[t]; ok {
+			return append(errs, s.validateComplex(ct, n, p)...)
+		}
+		return append(errs, s.validateElementText(n, p, func() []XSDError {
+			return s.validateText(t, n.Text, p)
+		})...)
+	}
+	if ct := decl.child("complexType"); ct != nil {
+		return append(errs, s.validateComplex(ct, n, p)...)
+	}
+	if st := decl.child("simpleType"); st != nil {
+		return append(errs, s.validateElementText(n, p, func() []XSDError {
+			return s.validateSimple(st, n.Text, p)
+		})...)
+	}
+	return errs
+}
+
+// validateElementText validates an element with simple content, which can't
+// have attributes or child elements
+func (s *XSD) validateElementText(n *xmlNode, p string, validate func() []XSDError) []XSDError {
+	errs := s.validateAttributes(nil, n, p)
+	for _, c := range n.Nodes {
+		errs = append(errs, XSDError{Path: p + "/" + c.XMLName.Local, Message: "unexpected element"})
+	}
+	return append(errs, validate()...)
+}
+
+func (s *XSD) validateComplex(ct *xmlNode, n *xmlNode, p string) []XSDError {
+	if sc := ct.child("simpleContent"); sc != nil {
+		ext := sc.child("extension")
+		if ext == nil {
+			ext = sc.child("restriction")
+		}
+		if ext == nil {
+			return nil
+		}
+		errs := s.validateAttributes(ext.Nodes, n, p)
+		for _, c := range n.Nodes {
+			errs = append(errs, XSDError{Path: p + "/" + c.XMLName.Local, Message: "unexpected element"})
+		}
+		return append(errs, s.validateText(ext.attr("base"), n.Text, p)...)
+	}
+
+	errs := s.validateAttributes(ct.Nodes, n, p)
+	var group *xmlNode
+	for _, c := range ct.Nodes {
+		if name := c.XMLName.Local; name == "all" || name == "sequence" || name == "choice" {
+			group = c
+			break
+		}
+	}
+	pos := 0
+	if group != nil && group.XMLName.Local == "all" {
+		return append(errs, s.validateAll(group, n, p)...)
+	} else if group != nil {
+		var e []XSDError
+		pos, e = s.matchParticle(group, n.Nodes, 0, p)
+		errs = append(errs, e...)
+	}
+	for _, c := range n.Nodes[pos:] {
+		errs = append(errs, XSDError{Path: p + "/" + c.XMLName.Local, Message: "unexpected element"})
+	}
+	return errs
+}
+
+// validateAll validates the children of an element against an all model
+// group, where elements can appear in any order
+func (s *XSD) validateAll(group *xmlNode, n *xmlNode, p string) []XSDError {
+	errs := make([]XSDError, 0)
+	decls := map[string]*xmlNode{}
+	for _, d := range group.Nodes {
+		if d.XMLName.Local == "element" {
+			decls[d.attr("name")] = d
+		}
+	}
+	counts := map[string]int{}
+	for _, c := range n.Nodes {
+		name := c.XMLName.Local
+		decl, ok := decls[name]
+		if !ok {
+			errs = append(errs, XSDError{Path: p + "/" + name, Message: "unexpected element"})
+			continue
+		}
+		counts[name]++
+		if counts[name] > 1 {
+			errs = append(errs, XSDError{Path: p + "/" + name, Message: "element must not occur more than once"})
+		}
+		errs = append(errs, s.validateElement(decl, c, p+"/"+name)...)
+	}
+	for _, d := range group.Nodes {
+		name := d.attr("name")
+		if d.XMLName.Local == "element" && occurs(d, "minOccurs", 1) > 0 && counts[name] == 0 {
+			errs = append(errs, XSDError{Path: p + "/" + name, Message: "missing required element", Missing: true})
+		}
+	}
+	return errs
+}
+
+// matchParticle greedily matches children starting at pos against an element
+// declaration, sequence or choice, and returns the position after the match
+func (s *XSD) matchParticle(particle *xmlNode, children []*xmlNode, pos int, p string) (int, []XSDError) {
+	errs := make([]XSDError, 0)
+	min, max := occurs(particle, "minOccurs", 1), occurs(particle, "maxOccurs", 1)
+	switch particle.XMLName.Local {
+	case "element":
+		name := particle.attr("name")
+		count := 0
+		for pos < len(children) && children[pos].XMLName.Local == name && (max < 0 || count < max) {
+			errs = append(errs, s.validateElement(particle, children[pos], p+"/"+name)...)
+			pos++
+			count++
+		}
+		if count < min {
+			errs = append(errs, XSDError{Path: p + "/" + name, Message: "missing required element", Missing: true})
+		}
+	case "sequence":
+		for _, c := range particle.Nodes {
+			if c.XMLName.Space != xsNS {
+				continue
+			}
+			var e []XSDError
+			pos, e = s.matchParticle(c, children, pos, p)
+			errs = append(errs, e...)
+		}
+	case "choice":
+		names := make([]string, 0)
+		emptiable := false
+		for _, c := range particle.Nodes {
+			if c.XMLName.Local == "element" {
+				names = append(names, c.attr("name"))
+				emptiable = emptiable || occurs(c, "minOccurs", 1) == 0
+			}
+		}
+		count := 0
+		for max < 0 || count < max {
+			next := s.matchChoice(particle, children, pos)
+			if next == nil {
+				break
+			}
+			var e []XSDError
+			pos, e = s.matchParticle(next, children, pos, p)
+			errs = append(errs, e...)
+			count++
+		}
+		if count < min && !emptiable {
+			errs = append(errs, XSDError{Path: p, Message: fmt.Sprintf("missing one of %s", strings.Join(names, ", ")), Missing: true})
+		}
+	}
+	return pos, errs
+}
+
+// matchChoice returns the element declaration of a choice matching the
+// child at pos, nil if there is none
+func (s *XSD) matchChoice(choice *xmlNode, children []*xmlNode, pos int) *xmlNode {
+	if pos >= len(children) {
+		return nil
+	}
+	for _, c := range choice.Nodes {
+		if c.XMLName.Local == "element" && children[pos].XMLName.Local == c.attr("name") {
+			return c
+		}
+	}
+	return nil
+}
+
+// validateAttributes checks that required attributes are present, and that
+// all attributes are declared and have valid values. Attributes in the xml
+// and xsi namespaces are always allowed.
+func (s *XSD) validateAttributes(decls []*xmlNode, n *xmlNode, p string) []XSDError {
+	errs := make([]XSDError, 0)
+	declared := map[string]*xmlNode{}
+	for _, d := range decls {
+		if d.XMLName.Local == "attribute" && d.attr("name") != "" {
+			declared[d.attr("name")] = d
+		}
+	}
+	present := map[string]bool{}
+	for _, a := range n.Attrs {
+		if a.Name.Space == xmlNS || a.Name.Space == xsiNS || a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+			continue
+		}
+		present[a.Name.Local] = true
+		d, ok := declared[a.Name.Local]
+		if !ok {
+			errs = append(errs, XSDError{Path: p + "/@" + a.Name.Local, Message: "unexpected attribute"})
+			continue
+		}
+		errs = append(errs, s.validateText(d.attr("type"), a.Value, p+"/@"+a.Name.Local)...)
+	}
+	for _, d := range decls {
+		name := d.attr("name")
+		if d.XMLName.Local == "attribute" && d.attr("use") == "required" && !present[name] {
+			errs = append(errs, XSDError{Path: p + "/@" + name, Message: "missing required attribute", Missing: true})
+		}
+	}
+	return errs
+}
+
+// validateText validates a value against a built-in or named simple type
+func (s *XSD) validateText(t string, value string, p string) []XSDError {
+	if st, ok := s.simpleTypes[t]; ok {
+		return s.validateSimple(st, value, p)
+	}
+	value = strings.TrimSpace(value)
+	valid := true
+	switch strings.TrimPrefix(t, "xs:") {
+	case "float", "double", "decimal":
+		_, err := strconv.ParseFloat(value, 64)
+		valid = err == nil
+	case "integer", "int", "nonNegativeInteger", "positiveInteger":
+		_, err := strconv.Atoi(value)
+		valid = err == nil
+	case "gYear":
+		valid = yearRegexp.MatchString(value)
+	case "language":
+		valid = languageRegexp.MatchString(value)
+	}
+	if !valid {
+		return []XSDError{{Path: p, Message: fmt.Sprintf("%q is not a valid %s", value, t)}}
+	}
+	return nil
+}
+
+var languageRegexp = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+// validateSimple validates a value against a simple type restriction
+func (s *XSD) validateSimple(st *xmlNode, value string, p string) []XSDError {
+	r := st.child("restriction")
+	if r == nil {
+		return nil
+	}
+	errs := s.validateText(r.attr("base"), value, p)
+	if r.attr("base") != "xs:string" {
+		value = strings.Join(strings.Fields(value), " ")
+	}
+	enumeration := make([]string, 0)
+	for _, f := range r.Nodes {
+		v := f.attr("value")
+		switch f.XMLName.Local {
+		case "enumeration":
+			enumeration = append(enumeration, v)
+		case "pattern":
+			// XML Schema patterns are implicitly anchored
+			re, err := regexp.Compile("^(?:" + v + ")$")
+			if err == nil && !re.MatchString(value) {
+				errs = append(errs, XSDError{Path: p, Message: fmt.Sprintf("%q does not match pattern %s", value, v)})
+			}
+		case "length", "minLength", "maxLength":
+			length, _ := strconv.Atoi(v)
+			n := len([]rune(value))
+			switch {
+			case f.XMLName.Local == "length" && n != length:
+				errs = append(errs, XSDError{Path: p, Message: fmt.Sprintf("length of %q must be %d", value, length)})
+			case (f.XMLName.Local == "minLength" && n < length) || (f.XMLName.Local == "maxLength" && n > length):
+				errs = append(errs, XSDError{Path: p, Message: fmt.Sprintf("length of %q must be %s %d", value, strings.TrimSuffix(f.XMLName.Local, "Length"), length)})
+			}
+		case "minInclusive", "maxInclusive":
+			limit, _ := strconv.ParseFloat(v, 64)
+			x, err := strconv.ParseFloat(value, 64)
+			if err == nil && ((f.XMLName.Local == "minInclusive" && x < limit) || (f.XMLName.Local == "maxInclusive" && x > limit)) {
+				errs = append(errs, XSDError{Path: p, Message: fmt.Sprintf("%s is out of range", value)})
+			}
+		}
+	}
+	if len(enumeration) > 0 && !slices.Contains(enumeration, value) {
+		errs = append(errs, XSDError{Path: p, Message: fmt.Sprintf("%q is not one of %s", value, strings.Join(enumeration, ", "))})
+	}
+	return errs
+}
+
+// occurs returns the value of minOccurs or maxOccurs, -1 for unbounded
+func occurs(n *xmlNode, name string, fallback int) int {
+	v := n.attr(name)
+	if v == "" {
+		return fallback
+	}
+	if v == "unbounded" {
+		return -1
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback
+	}
+	return i
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestXSDValidate(t *testing.T) {
+	t.Parallel()
+
+	schema, err := dataciteXSD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	type testCase struct {
+		input string
+		want  []string
+	}
+	const header = `<resource xmlns="http://datacite.org/schema/kernel-4"><identifier identifierType="DOI">10.5555/12345678</identifier><creators><creator><creatorName>Josiah Carberry</creatorName></creator></creators><titles><title>Toward a Unified Theory</title></titles><publisher>Brown University</publisher>`
+	testCases := []testCase{
+		{input: header + `<publicationYear>2008</publicationYear><resourceType resourceTypeGeneral="JournalArticle"/></resource>`, want: []string{}},
+		{input: header + `<publicationYear>08</publicationYear><resourceType resourceTypeGeneral="JournalArticle"/></resource>`, want: []string{`/resource/publicationYear: "08" does not match pattern [\d]{4}`}},
+		{input: header + `<publicationYear>2008</publicationYear><resourceType resourceTypeGeneral="Article"/></resource>`, want: []string{`/resource/resourceType/@resourceTypeGeneral: "Article" is not one of Audiovisual, Book, BookChapter, Collection, ComputationalNotebook, ConferencePaper, ConferenceProceeding, DataPaper, Dataset, Dissertation, Event, Image, Instrument, InteractiveResource, Journal, JournalArticle, Model, OutputManagementPlan, PeerReview, PhysicalObject, Preprint, Report, Service, Software, Sound, Standard, StudyRegistration, Text, Workflow, Other`}},
+		{input: header + `<publicationYear>2008</publicationYear><resourceType/></resource>`, want: []string{`/resource/resourceType/@resourceTypeGeneral: missing required attribute`}},
+		{input: header + `<publicationYear>2008</publicationYear><resourceType resourceTypeGeneral="Text"/><dates><date dateType="Issued">2008</date><foo/></dates></resource>`, want: []string{`/resource/dates/foo: unexpected element`}},
+		{input: header + `<resourceType resourceTypeGeneral="Text"/><version>1.0</version><version>2.0</version></resource>`, want: []string{`/resource/version: element must not occur more than once`, `/resource/publicationYear: missing required element`}},
+		{input: header + `<publicationYear>2008</publicationYear><resourceType resourceTypeGeneral="Text"/><descriptions><description descriptionType="Abstract">Line 1<br/>Line 2</description></descriptions></resource>`, want: []string{}},
+		{input: header + `<publicationYear>2008</publicationYear><resourceType resourceTypeGeneral="Text"/><descriptions><description descriptionType="Abstract"><br>Line 1</br></description></descriptions></resource>`, want: []string{`/resource/descriptions/description/br: length of "Line 1" must be 0`}},
+		{input: header + `<publicationYear>2008</publicationYear><resourceType resourceTypeGeneral="Text"/><geoLocations><geoLocation><geoLocationBox><westBoundLongitude>1</westBoundLongitude><eastBoundLongitude>2</eastBoundLongitude><southBoundLatitude>1</southBoundLatitude><northBoundLatitude>2</northBoundLatitude></geoLocationBox><geoLocationPlace>Atlantic Ocean</geoLocationPlace></geoLocation></geoLocations></resource>`, want: []string{}},
+		{input: header + `<publicationYear>2008</publicationYear><resourceType resourceTypeGeneral="Text"/><geoLocations><geoLocation><geoLocationPolygon><polygonPoint><pointLongitude>1</pointLongitude><pointLatitude>1</pointLatitude></polygonPoint></geoLocationPolygon></geoLocation></geoLocations></resource>`, want: []string{`/resource/geoLocations/geoLocation/geoLocationPolygon/polygonPoint: missing required element`}},
+	}
+	for _, tc := range testCases {
+		errs, err := schema.Validate([]byte(tc.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, e := range errs {
+			got = append(got, e.Error())
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("Validate(%v): want %v, got %v", tc.input, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("Validate(%v): want %v, got %v", tc.input, tc.want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestXSDValidateDataciteExamples(t *testing.T) {
+	t.Parallel()
+
+	schema, err := dataciteXSD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// official kernel-4 example documents of the DataCite Metadata Schema,
+	// valid against the official schema. The bundled schema only rejects
+	// related items.
+	type testCase struct {
+		name string
+		want []string
+	}
+	testCases := []testCase{
+		{name: "datacite-example-full-v4.4.xml", want: []string{"/resource/relatedItems: unexpected element"}},
+		{name: "datacite-example-dissertation-v4.4.xml", want: []string{}},
+		{name: "datacite-example-ancientdates-v4.3.xml", want: []string{}},
+		{name: "datacite-example-affiliation.xml", want: []string{}},
+		{name: "datacite-example-complicated-v4.1.xml", want: []string{}},
+		{name: "datacite-example-geolocation.xml", want: []string{}},
+	}
+	for _, tc := range testCases {
+		doc, err := os.ReadFile(filepath.Join("testdata", "datacite", tc.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs, err := schema.Validate(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, e := range errs {
+			got = append(got, e.Error())
+		}
+		if strings.Join(tc.want, "; ") != strings.Join(got, "; ") {
+			t.Errorf("Validate(%v): want %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}